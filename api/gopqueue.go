@@ -2,45 +2,51 @@ package api
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
 	"sync"
 )
 
-// Item 儲存佇列元素和優先級
-type Item struct {
-	Value    string // 元素值
-	Priority int    // 優先級（數字越小優先級越高）
-	Index    int    // 元素索引（由 heap.Interface 管理）
+// Item 儲存佇列元素和優先級，Enqueue 返回的 *Item 可作為 Update 與 Remove 的句柄
+// 元素在佇列中時不要直接修改 Priority，請使用 Update
+type Item[T any] struct {
+	Value    T   // 元素值
+	Priority int // 優先級（數字越小優先級越高）
+	Index    int // 元素索引（由 heap.Interface 管理），不在佇列中時為 -1
+	seq      uint64
 }
 
-// PriorityQueue 實現一個佇列
-type PriorityQueue []*Item
+// PriorityQueue 實現一個佇列，優先級相同時先進先出
+type PriorityQueue[T any] []*Item[T]
 
 // Len 取得佇列長度
-func (pq PriorityQueue) Len() int { return len(pq) }
+func (pq PriorityQueue[T]) Len() int { return len(pq) }
 
-// Less 定義優先順序
-func (pq PriorityQueue) Less(i, j int) bool {
-	return pq[i].Priority < pq[j].Priority
+// Less 定義優先順序，優先級相同時按插入順序
+func (pq PriorityQueue[T]) Less(i, j int) bool {
+	if pq[i].Priority != pq[j].Priority {
+		return pq[i].Priority < pq[j].Priority
+	}
+	return pq[i].seq < pq[j].seq
 }
 
 // Swap 交換元素
-func (pq PriorityQueue) Swap(i, j int) {
+func (pq PriorityQueue[T]) Swap(i, j int) {
 	pq[i], pq[j] = pq[j], pq[i]
 	pq[i].Index = i
 	pq[j].Index = j
 }
 
 // Push 插入元素
-func (pq *PriorityQueue) Push(x interface{}) {
+func (pq *PriorityQueue[T]) Push(x interface{}) {
 	n := len(*pq)
-	item := x.(*Item)
+	item := x.(*Item[T])
 	item.Index = n
 	*pq = append(*pq, item)
 }
 
 // Pop 移除最高優先級元素
-func (pq *PriorityQueue) Pop() interface{} {
+func (pq *PriorityQueue[T]) Pop() interface{} {
 	old := *pq
 	n := len(old)
 	item := old[n-1]
@@ -51,48 +57,203 @@ func (pq *PriorityQueue) Pop() interface{} {
 }
 
 // ConcurrentPriorityQueue 支援併發操作的優先佇列
-type ConcurrentPriorityQueue struct {
-	queue PriorityQueue
-	lock  sync.Mutex
+// capacity 大於 0 時佇列有界，滿時 Enqueue 失敗、EnqueueWait 等待
+type ConcurrentPriorityQueue[T any] struct {
+	queue    PriorityQueue[T]
+	lock     sync.Mutex
+	capacity int
+	seq      uint64
+	changed  chan struct{} // 佇列變化時關閉並替換，用於喚醒等待者
+	waiters  int           // 正在等待 changed 的調用數
+}
+
+// NewConcurrentPriorityQueue 創建新的無界佇列
+func NewConcurrentPriorityQueue[T any]() *ConcurrentPriorityQueue[T] {
+	return NewBoundedPriorityQueue[T](0)
+}
+
+// NewBoundedPriorityQueue 創建最多容納 capacity 個元素的佇列，capacity 不大於 0 時無界
+func NewBoundedPriorityQueue[T any](capacity int) *ConcurrentPriorityQueue[T] {
+	return &ConcurrentPriorityQueue[T]{
+		queue:    make(PriorityQueue[T], 0),
+		capacity: max(capacity, 0),
+		changed:  make(chan struct{}),
+	}
+}
+
+// notify 喚醒所有等待者，調用時必須持有鎖
+func (cpq *ConcurrentPriorityQueue[T]) notify() {
+	if cpq.waiters == 0 {
+		return
+	}
+	close(cpq.changed)
+	cpq.changed = make(chan struct{})
+}
+
+// wait 釋放鎖直到佇列變化或 ctx 結束，返回時重新持有鎖
+func (cpq *ConcurrentPriorityQueue[T]) wait(ctx context.Context) error {
+	changed := cpq.changed
+	cpq.waiters++
+	cpq.lock.Unlock()
+
+	var err error
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	cpq.lock.Lock()
+	cpq.waiters--
+	return err
+}
+
+func (cpq *ConcurrentPriorityQueue[T]) full() bool {
+	return cpq.capacity > 0 && cpq.queue.Len() >= cpq.capacity
+}
+
+// push 插入元素，調用時必須持有鎖且佇列未滿
+func (cpq *ConcurrentPriorityQueue[T]) push(value T, priority int) *Item[T] {
+	cpq.seq++
+	item := &Item[T]{Value: value, Priority: priority, seq: cpq.seq}
+	heap.Push(&cpq.queue, item)
+	cpq.notify()
+	return item
 }
 
-// NewConcurrentPriorityQueue 創建新的佇列
-func NewConcurrentPriorityQueue() *ConcurrentPriorityQueue {
-	return &ConcurrentPriorityQueue{queue: make(PriorityQueue, 0)}
+// pop 移除最高優先級元素，調用時必須持有鎖且佇列不為空
+func (cpq *ConcurrentPriorityQueue[T]) pop() T {
+	item := heap.Pop(&cpq.queue).(*Item[T])
+	cpq.notify()
+	return item.Value
+}
+
+// Enqueue 插入元素並返回其句柄，佇列已滿時返回 false
+func (cpq *ConcurrentPriorityQueue[T]) Enqueue(value T, priority int) (*Item[T], bool) {
+	cpq.lock.Lock()
+	defer cpq.lock.Unlock()
+
+	if cpq.full() {
+		return nil, false
+	}
+	return cpq.push(value, priority), true
 }
 
-// Enqueue 插入元素
-func (cpq *ConcurrentPriorityQueue) Enqueue(value string, priority int) {
+// EnqueueWait 插入元素，佇列已滿時等待空位，ctx 結束時返回 ctx.Err()
+func (cpq *ConcurrentPriorityQueue[T]) EnqueueWait(ctx context.Context, value T, priority int) (*Item[T], error) {
 	cpq.lock.Lock()
 	defer cpq.lock.Unlock()
 
-	heap.Push(&cpq.queue, &Item{Value: value, Priority: priority})
+	for cpq.full() {
+		if err := cpq.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return cpq.push(value, priority), nil
 }
 
-// Dequeue 移除最高優先級元素
-func (cpq *ConcurrentPriorityQueue) Dequeue() (string, bool) {
+// Dequeue 移除最高優先級元素，佇列為空時返回 false
+func (cpq *ConcurrentPriorityQueue[T]) Dequeue() (T, bool) {
 	cpq.lock.Lock()
 	defer cpq.lock.Unlock()
 
 	if cpq.queue.Len() == 0 {
-		return "", false
+		var zero T
+		return zero, false
 	}
+	return cpq.pop(), true
+}
+
+// DequeueWait 移除最高優先級元素，佇列為空時等待，ctx 結束時返回 ctx.Err()
+func (cpq *ConcurrentPriorityQueue[T]) DequeueWait(ctx context.Context) (T, error) {
+	cpq.lock.Lock()
+	defer cpq.lock.Unlock()
 
-	item := heap.Pop(&cpq.queue).(*Item)
-	return item.Value, true
+	for cpq.queue.Len() == 0 {
+		if err := cpq.wait(ctx); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+	return cpq.pop(), nil
+}
+
+// Peek 返回最高優先級元素但不移除，佇列為空時返回 false
+func (cpq *ConcurrentPriorityQueue[T]) Peek() (T, bool) {
+	cpq.lock.Lock()
+	defer cpq.lock.Unlock()
+
+	if cpq.queue.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return cpq.queue[0].Value, true
+}
+
+// contains 判斷句柄是否仍在佇列中，調用時必須持有鎖
+func (cpq *ConcurrentPriorityQueue[T]) contains(item *Item[T]) bool {
+	return item != nil && item.Index >= 0 && item.Index < cpq.queue.Len() && cpq.queue[item.Index] == item
+}
+
+// Update 修改佇列中元素的優先級，元素已不在佇列中時返回 false
+// 修改後的元素在相同優先級的元素中排在最後
+func (cpq *ConcurrentPriorityQueue[T]) Update(item *Item[T], priority int) bool {
+	cpq.lock.Lock()
+	defer cpq.lock.Unlock()
+
+	if !cpq.contains(item) {
+		return false
+	}
+	cpq.seq++
+	item.Priority = priority
+	item.seq = cpq.seq
+	heap.Fix(&cpq.queue, item.Index)
+	cpq.notify()
+	return true
+}
+
+// Remove 從佇列中移除元素，元素已不在佇列中時返回 false
+func (cpq *ConcurrentPriorityQueue[T]) Remove(item *Item[T]) bool {
+	cpq.lock.Lock()
+	defer cpq.lock.Unlock()
+
+	if !cpq.contains(item) {
+		return false
+	}
+	heap.Remove(&cpq.queue, item.Index)
+	cpq.notify()
+	return true
+}
+
+// Drain 按優先順序移除並返回所有元素
+func (cpq *ConcurrentPriorityQueue[T]) Drain() []T {
+	cpq.lock.Lock()
+	defer cpq.lock.Unlock()
+
+	values := make([]T, 0, cpq.queue.Len())
+	for cpq.queue.Len() > 0 {
+		values = append(values, heap.Pop(&cpq.queue).(*Item[T]).Value)
+	}
+	cpq.notify()
+	return values
 }
 
 // Len 取得佇列長度
-func (cpq *ConcurrentPriorityQueue) Len() int {
+func (cpq *ConcurrentPriorityQueue[T]) Len() int {
 	cpq.lock.Lock()
 	defer cpq.lock.Unlock()
 
 	return cpq.queue.Len()
 }
 
+// Cap 取得佇列容量，無界佇列返回 0
+func (cpq *ConcurrentPriorityQueue[T]) Cap() int {
+	return cpq.capacity
+}
+
 // 主函數測試
 func main() {
-	pq := NewConcurrentPriorityQueue()
+	pq := NewConcurrentPriorityQueue[string]()
 
 	pq.Enqueue("task1", 3)
 	pq.Enqueue("task2", 1)
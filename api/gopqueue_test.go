@@ -1,14 +1,21 @@
 package api_test
 
 import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
 	"testing"
+	"time"
 
 	"example.com/m/api"
+	gopq "github.com/jupp0r/go-priority-queue"
+	"github.com/oleiade/lane"
 )
 
 func TestGopqueue(t *testing.T) {
 	// 測試 1: 正常情況
-	pq := api.NewConcurrentPriorityQueue()
+	pq := api.NewConcurrentPriorityQueue[string]()
 
 	pq.Enqueue("task1", 3)
 	pq.Enqueue("task2", 1)
@@ -43,3 +50,221 @@ func TestGopqueue(t *testing.T) {
 		t.Errorf("Queue length should be 0 for an empty queue, but got %d", pq.Len())
 	}
 }
+
+func TestGopqueueFIFOAndHandles(t *testing.T) {
+	pq := api.NewConcurrentPriorityQueue[int]()
+	for i := 1; i <= 4; i++ {
+		pq.Enqueue(i, 1)
+	}
+	low, _ := pq.Enqueue(5, 9)
+	removed, _ := pq.Enqueue(6, 1)
+
+	// 優先級相同時先進先出，Update 後排在相同優先級的最後
+	if !pq.Update(low, 0) {
+		t.Fatal("Update failed for an item in the queue")
+	}
+	if !pq.Remove(removed) {
+		t.Fatal("Remove failed for an item in the queue")
+	}
+	if value, ok := pq.Peek(); !ok || value != 5 {
+		t.Errorf("Peek: expected 5, got %d (%v)", value, ok)
+	}
+	if v, _ := pq.Dequeue(); v != 5 {
+		t.Errorf("Expected updated item 5 first, got %d", v)
+	}
+	if pq.Update(low, 3) || pq.Remove(low) || pq.Remove(removed) {
+		t.Error("Update and Remove should fail for items no longer in the queue")
+	}
+
+	drained := pq.Drain()
+	expected := []int{1, 2, 3, 4}
+	if len(drained) != len(expected) {
+		t.Fatalf("Drain: expected %v, got %v", expected, drained)
+	}
+	for i := range expected {
+		if drained[i] != expected[i] {
+			t.Fatalf("Drain: expected %v, got %v", expected, drained)
+		}
+	}
+	if pq.Len() != 0 {
+		t.Errorf("Queue should be empty after Drain, got %d items", pq.Len())
+	}
+}
+
+func TestGopqueueWait(t *testing.T) {
+	pq := api.NewBoundedPriorityQueue[string](1)
+	if pq.Cap() != 1 {
+		t.Errorf("Expected capacity 1, got %d", pq.Cap())
+	}
+
+	// 空佇列的 DequeueWait 在 ctx 超時後返回
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pq.DequeueWait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DequeueWait on an empty queue: expected DeadlineExceeded, got %v", err)
+	}
+
+	// 佇列已滿時 Enqueue 失敗，EnqueueWait 等待空位
+	if _, ok := pq.Enqueue("task1", 1); !ok {
+		t.Fatal("Enqueue failed on an empty queue")
+	}
+	if _, ok := pq.Enqueue("task2", 1); ok {
+		t.Error("Enqueue should fail on a full queue")
+	}
+	enqueued := make(chan error, 1)
+	go func() {
+		_, err := pq.EnqueueWait(context.Background(), "task2", 1)
+		enqueued <- err
+	}()
+	select {
+	case err := <-enqueued:
+		t.Fatalf("EnqueueWait returned %v while the queue was full", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if v, err := pq.DequeueWait(context.Background()); err != nil || v != "task1" {
+		t.Errorf("DequeueWait: expected task1, got %q (%v)", v, err)
+	}
+	if err := <-enqueued; err != nil {
+		t.Errorf("EnqueueWait failed: %v", err)
+	}
+
+	// DequeueWait 在元素到達時返回
+	pq.Dequeue()
+	dequeued := make(chan string, 1)
+	go func() {
+		v, _ := pq.DequeueWait(context.Background())
+		dequeued <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pq.Enqueue("task3", 1)
+	select {
+	case v := <-dequeued:
+		if v != "task3" {
+			t.Errorf("DequeueWait: expected task3, got %q", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DequeueWait did not return after Enqueue")
+	}
+}
+
+func TestGopqueueConcurrent(t *testing.T) {
+	const producers, perProducer = 8, 500
+	pq := api.NewBoundedPriorityQueue[int](16)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				if _, err := pq.EnqueueWait(ctx, p*perProducer+i, i%7); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(p)
+	}
+
+	seen := make([]bool, producers*perProducer)
+	var mu sync.Mutex
+	var consumers sync.WaitGroup
+	for c := 0; c < 4; c++ {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for {
+				v, err := pq.DequeueWait(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if v < 0 {
+					return
+				}
+				mu.Lock()
+				seen[v] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// 每個消費者收到一個結束標記，優先級最低以保證排在所有元素之後
+	for c := 0; c < 4; c++ {
+		pq.EnqueueWait(ctx, -1, 100)
+	}
+	consumers.Wait()
+	for v, ok := range seen {
+		if !ok {
+			t.Fatalf("Value %d was never dequeued", v)
+		}
+	}
+}
+
+// 三種實作插入並取出 1000 個隨機優先級的元素
+const benchmarkItems = 1000
+
+func benchmarkPriorities() []int {
+	rng := rand.New(rand.NewSource(1))
+	priorities := make([]int, benchmarkItems)
+	for i := range priorities {
+		priorities[i] = rng.Intn(benchmarkItems)
+	}
+	return priorities
+}
+
+func BenchmarkConcurrentPriorityQueue(b *testing.B) {
+	priorities := benchmarkPriorities()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pq := api.NewConcurrentPriorityQueue[int]()
+		for j, p := range priorities {
+			pq.Enqueue(j, p)
+		}
+		for pq.Len() > 0 {
+			pq.Dequeue()
+		}
+	}
+}
+
+func BenchmarkGoPriorityQueue(b *testing.B) {
+	priorities := benchmarkPriorities()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pq := gopq.New()
+		for j, p := range priorities {
+			pq.Insert(j, float64(p))
+		}
+		for pq.Len() > 0 {
+			pq.Pop()
+		}
+	}
+}
+
+func BenchmarkLane(b *testing.B) {
+	priorities := benchmarkPriorities()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pq := lane.NewPQueue(lane.MINPQ)
+		for j, p := range priorities {
+			pq.Push(j, p)
+		}
+		for !pq.Empty() {
+			pq.Pop()
+		}
+	}
+}
+
+// 多個 goroutine 同時使用 EnqueueWait 與 DequeueWait
+func BenchmarkConcurrentPriorityQueueParallel(b *testing.B) {
+	pq := api.NewBoundedPriorityQueue[int](1024)
+	ctx := context.Background()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			pq.EnqueueWait(ctx, i, i%100)
+			pq.DequeueWait(ctx)
+			i++
+		}
+	})
+}
@@ -0,0 +1,407 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrLeaseExpired 表示取出的任務已超過可見性超時並被重新投遞，不能再 Ack 或 Nack
+var ErrLeaseExpired = errors.New("redis queue: delivery lease expired")
+
+// Consumer 是記憶體佇列與 Redis 佇列共同的消費接口
+type Consumer[T any] interface {
+	DequeueWait(ctx context.Context) (T, error)
+}
+
+var (
+	_ Consumer[string] = (*ConcurrentPriorityQueue[string])(nil)
+	_ Consumer[string] = (*RedisQueue[string])(nil)
+)
+
+// RedisQueueOptions 配置 RedisQueue，零值使用默認值
+type RedisQueueOptions struct {
+	VisibilityTimeout time.Duration // 取出後未 Ack 的任務在此時間後重新投遞，默認 30 秒
+	MaxAttempts       int           // 投遞次數達到此值後仍失敗的任務進入死信佇列，默認 5
+	PollInterval      time.Duration // 佇列為空時 DequeueWait 的輪詢間隔，默認 100 毫秒
+}
+
+// Delivery 是一次任務投遞，處理完成後必須 Ack，失敗時 Nack
+type Delivery[T any] struct {
+	ID       string
+	Value    T
+	Priority int
+	Attempt  int // 第幾次投遞，從 1 開始
+
+	queue *RedisQueue[T]
+	lease string
+}
+
+// DeadLetter 是投遞次數用盡的任務
+type DeadLetter[T any] struct {
+	ID       string
+	Value    T
+	Priority int
+	Attempts int
+}
+
+// RedisQueueStats 各狀態的任務數
+type RedisQueueStats struct {
+	Ready      int64 // 等待取出
+	Delayed    int64 // 尚未到執行時間
+	Processing int64 // 已取出但未 Ack
+	Dead       int64 // 在死信佇列中
+}
+
+// RedisQueue 是以 Redis 有序集合持久化的優先佇列，語義與 ConcurrentPriorityQueue 相同：
+// 數字越小優先級越高，優先級相同時先進先出。任務在重啟後仍然存在，多個進程可共用同一佇列。
+//
+// 任務 ID 是 INCR 產生的零填充序號，ready 集合以優先級為分數，
+// 分數相同時 Redis 按成員字典序排列，即按入隊順序。
+// 取出任務由 Lua 腳本原子完成，至少投遞一次：未在可見性超時內 Ack 的任務會被重新投遞。
+type RedisQueue[T any] struct {
+	client redis.UniversalClient
+	opts   RedisQueueOptions
+	now    func() time.Time
+
+	seq, ready, delayed, processing, dead string
+	data, priority, attempts, lease       string
+}
+
+// NewRedisQueue 創建名為 name 的佇列，同名佇列共享任務
+func NewRedisQueue[T any](client redis.UniversalClient, name string, opts RedisQueueOptions) *RedisQueue[T] {
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = 30 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 100 * time.Millisecond
+	}
+	// 使用 hash tag 讓所有鍵落在同一個 Cluster slot，Lua 腳本才能同時操作
+	key := func(suffix string) string { return "{" + name + "}:" + suffix }
+	return &RedisQueue[T]{
+		client:     client,
+		opts:       opts,
+		now:        time.Now,
+		seq:        key("seq"),
+		ready:      key("ready"),
+		delayed:    key("delayed"),
+		processing: key("processing"),
+		dead:       key("dead"),
+		data:       key("data"),
+		priority:   key("priority"),
+		attempts:   key("attempts"),
+		lease:      key("lease"),
+	}
+}
+
+// enqueueScript 分配 ID 並寫入任務，執行時間未到的任務放入 delayed
+var enqueueScript = redis.NewScript(`
+local id = string.format('%020d', redis.call('INCR', KEYS[1]))
+redis.call('HSET', KEYS[2], id, ARGV[1])
+redis.call('HSET', KEYS[3], id, ARGV[2])
+if tonumber(ARGV[3]) > tonumber(ARGV[4]) then
+	redis.call('ZADD', KEYS[5], ARGV[3], id)
+else
+	redis.call('ZADD', KEYS[4], ARGV[2], id)
+end
+return id
+`)
+
+// claimScript 先把到期的延遲任務移入 ready，把超時未 Ack 的任務重新投遞或移入死信，
+// 再取出最高優先級的任務並設置租約
+var claimScript = redis.NewScript(`
+local ready, delayed, processing, dead = KEYS[1], KEYS[2], KEYS[3], KEYS[4]
+local data, priority, attempts, lease = KEYS[5], KEYS[6], KEYS[7], KEYS[8]
+local now = tonumber(ARGV[1])
+
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', delayed, '-inf', now, 'LIMIT', 0, 100)) do
+	redis.call('ZREM', delayed, id)
+	redis.call('ZADD', ready, redis.call('HGET', priority, id), id)
+end
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', processing, '-inf', now, 'LIMIT', 0, 100)) do
+	redis.call('ZREM', processing, id)
+	redis.call('HDEL', lease, id)
+	if tonumber(redis.call('HGET', attempts, id) or '0') >= tonumber(ARGV[3]) then
+		redis.call('ZADD', dead, now, id)
+	else
+		redis.call('ZADD', ready, redis.call('HGET', priority, id), id)
+	end
+end
+
+local ids = redis.call('ZRANGE', ready, 0, 0)
+if #ids == 0 then
+	return false
+end
+local id = ids[1]
+redis.call('ZREM', ready, id)
+redis.call('ZADD', processing, ARGV[2], id)
+redis.call('HSET', lease, id, ARGV[4])
+local n = redis.call('HINCRBY', attempts, id, 1)
+return {id, redis.call('HGET', data, id), redis.call('HGET', priority, id), n}
+`)
+
+// ackScript 在租約仍有效時刪除任務
+var ackScript = redis.NewScript(`
+if redis.call('HGET', KEYS[5], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+for i = 2, 5 do
+	redis.call('HDEL', KEYS[i], ARGV[1])
+end
+return 1
+`)
+
+// nackScript 在租約仍有效時歸還任務，投遞次數用盡時移入死信
+var nackScript = redis.NewScript(`
+local processing, ready, delayed, dead, priority, attempts, lease = unpack(KEYS)
+local id = ARGV[1]
+if redis.call('HGET', lease, id) ~= ARGV[2] then
+	return 0
+end
+redis.call('ZREM', processing, id)
+redis.call('HDEL', lease, id)
+if tonumber(redis.call('HGET', attempts, id) or '0') >= tonumber(ARGV[5]) then
+	redis.call('ZADD', dead, ARGV[3], id)
+elseif tonumber(ARGV[4]) > tonumber(ARGV[3]) then
+	redis.call('ZADD', delayed, ARGV[4], id)
+else
+	redis.call('ZADD', ready, redis.call('HGET', priority, id), id)
+end
+return 1
+`)
+
+// requeueScript 把死信任務放回 ready 並重置投遞次數
+var requeueScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HDEL', KEYS[4], ARGV[1])
+redis.call('ZADD', KEYS[2], redis.call('HGET', KEYS[3], ARGV[1]), ARGV[1])
+return 1
+`)
+
+// Enqueue 插入任務並返回其 ID
+func (q *RedisQueue[T]) Enqueue(ctx context.Context, value T, priority int) (string, error) {
+	return q.Schedule(ctx, value, priority, time.Time{})
+}
+
+// EnqueueIn 插入任務，delay 之後才能被取出
+func (q *RedisQueue[T]) EnqueueIn(ctx context.Context, value T, priority int, delay time.Duration) (string, error) {
+	return q.Schedule(ctx, value, priority, q.now().Add(delay))
+}
+
+// Schedule 插入任務，at 之後才能被取出，零值表示立即可取出
+func (q *RedisQueue[T]) Schedule(ctx context.Context, value T, priority int, at time.Time) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("redis queue: encode task: %w", err)
+	}
+	var runAt int64
+	if !at.IsZero() {
+		runAt = at.UnixMilli()
+	}
+	keys := []string{q.seq, q.data, q.priority, q.ready, q.delayed}
+	return enqueueScript.Run(ctx, q.client, keys, data, priority, runAt, q.now().UnixMilli()).Text()
+}
+
+// Claim 取出最高優先級的任務，佇列為空時返回 nil。
+// 任務在 VisibilityTimeout 內未 Ack 或 Nack 時會被重新投遞。
+func (q *RedisQueue[T]) Claim(ctx context.Context) (*Delivery[T], error) {
+	now := q.now()
+	lease := strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.FormatInt(rand.Int63(), 36)
+	keys := []string{q.ready, q.delayed, q.processing, q.dead, q.data, q.priority, q.attempts, q.lease}
+	args := []interface{}{now.UnixMilli(), now.Add(q.opts.VisibilityTimeout).UnixMilli(), q.opts.MaxAttempts, lease}
+	res, err := claimScript.Run(ctx, q.client, keys, args...).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 4 {
+		return nil, fmt.Errorf("redis queue: unexpected claim result %v", res)
+	}
+
+	d := &Delivery[T]{queue: q, lease: lease}
+	d.ID, _ = res[0].(string)
+	data, _ := res[1].(string)
+	priority, _ := res[2].(string)
+	attempt, _ := res[3].(int64)
+	d.Attempt = int(attempt)
+	if d.Priority, err = strconv.Atoi(priority); err != nil {
+		return nil, fmt.Errorf("redis queue: task %s: bad priority %q", d.ID, priority)
+	}
+	if err := json.Unmarshal([]byte(data), &d.Value); err != nil {
+		// 無法解碼的任務重試也不會成功，直接移入死信
+		q.deadLetter(ctx, d)
+		return nil, fmt.Errorf("redis queue: decode task %s: %w", d.ID, err)
+	}
+	return d, nil
+}
+
+func (q *RedisQueue[T]) deadLetter(ctx context.Context, d *Delivery[T]) {
+	keys := []string{q.processing, q.ready, q.delayed, q.dead, q.priority, q.attempts, q.lease}
+	nackScript.Run(ctx, q.client, keys, d.ID, d.lease, q.now().UnixMilli(), 0, 0)
+}
+
+// ClaimWait 取出最高優先級的任務，佇列為空時輪詢等待，ctx 結束時返回 ctx.Err()
+func (q *RedisQueue[T]) ClaimWait(ctx context.Context) (*Delivery[T], error) {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		d, err := q.Claim(ctx)
+		if d != nil || err != nil {
+			return d, err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// DequeueWait 取出並立即 Ack 最高優先級的任務，與 ConcurrentPriorityQueue.DequeueWait 相同。
+// 取出後處理失敗的任務不會重新投遞，需要至少一次語義時請使用 ClaimWait 或 Consume。
+func (q *RedisQueue[T]) DequeueWait(ctx context.Context) (T, error) {
+	var zero T
+	d, err := q.ClaimWait(ctx)
+	if err != nil {
+		return zero, err
+	}
+	if err := d.Ack(ctx); err != nil {
+		return zero, err
+	}
+	return d.Value, nil
+}
+
+// Dequeue 取出並立即 Ack 最高優先級的任務，佇列為空時返回 false
+func (q *RedisQueue[T]) Dequeue(ctx context.Context) (T, bool, error) {
+	var zero T
+	d, err := q.Claim(ctx)
+	if d == nil || err != nil {
+		return zero, false, err
+	}
+	if err := d.Ack(ctx); err != nil {
+		return zero, false, err
+	}
+	return d.Value, true, nil
+}
+
+// Consume 持續取出任務交給 handler，handler 成功時 Ack，失敗時 Nack 以便重試。
+// ctx 結束時返回 ctx.Err()，Redis 出錯時返回該錯誤。
+func (q *RedisQueue[T]) Consume(ctx context.Context, handler func(ctx context.Context, value T) error) error {
+	for {
+		d, err := q.ClaimWait(ctx)
+		if err != nil {
+			return err
+		}
+		if handler(ctx, d.Value) == nil {
+			err = d.Ack(ctx)
+		} else {
+			err = d.Nack(ctx, 0)
+		}
+		if err != nil && !errors.Is(err, ErrLeaseExpired) {
+			return err
+		}
+	}
+}
+
+// Ack 確認任務已完成並將其刪除，租約已過期時返回 ErrLeaseExpired
+func (d *Delivery[T]) Ack(ctx context.Context) error {
+	q := d.queue
+	keys := []string{q.processing, q.data, q.priority, q.attempts, q.lease}
+	return leaseResult(ackScript.Run(ctx, q.client, keys, d.ID, d.lease).Int())
+}
+
+// Nack 歸還任務，delay 之後可再次取出；投遞次數已用盡時任務進入死信佇列。
+// 租約已過期時返回 ErrLeaseExpired。
+func (d *Delivery[T]) Nack(ctx context.Context, delay time.Duration) error {
+	q := d.queue
+	now := q.now()
+	keys := []string{q.processing, q.ready, q.delayed, q.dead, q.priority, q.attempts, q.lease}
+	args := []interface{}{d.ID, d.lease, now.UnixMilli(), now.Add(delay).UnixMilli(), q.opts.MaxAttempts}
+	return leaseResult(nackScript.Run(ctx, q.client, keys, args...).Int())
+}
+
+func leaseResult(n int, err error) error {
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+// DeadLetters 按進入死信佇列的時間返回所有死信任務
+func (q *RedisQueue[T]) DeadLetters(ctx context.Context) ([]DeadLetter[T], error) {
+	ids, err := q.client.ZRange(ctx, q.dead, 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	pipe := q.client.Pipeline()
+	data := pipe.HMGet(ctx, q.data, ids...)
+	priorities := pipe.HMGet(ctx, q.priority, ids...)
+	attempts := pipe.HMGet(ctx, q.attempts, ids...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter[T], 0, len(ids))
+	for i, id := range ids {
+		letter := DeadLetter[T]{ID: id}
+		if s, ok := data.Val()[i].(string); ok {
+			// 無法解碼的任務保留零值，仍然列出以便排查
+			_ = json.Unmarshal([]byte(s), &letter.Value)
+		}
+		if s, ok := priorities.Val()[i].(string); ok {
+			letter.Priority, _ = strconv.Atoi(s)
+		}
+		if s, ok := attempts.Val()[i].(string); ok {
+			letter.Attempts, _ = strconv.Atoi(s)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// Requeue 把死信任務放回佇列並重置投遞次數，任務不在死信佇列中時返回 false
+func (q *RedisQueue[T]) Requeue(ctx context.Context, id string) (bool, error) {
+	keys := []string{q.dead, q.ready, q.priority, q.attempts}
+	n, err := requeueScript.Run(ctx, q.client, keys, id).Int()
+	return n == 1, err
+}
+
+// Len 取得等待取出的任務數，不包括延遲、處理中和死信任務
+func (q *RedisQueue[T]) Len(ctx context.Context) (int64, error) {
+	return q.client.ZCard(ctx, q.ready).Result()
+}
+
+// Stats 取得各狀態的任務數
+func (q *RedisQueue[T]) Stats(ctx context.Context) (RedisQueueStats, error) {
+	pipe := q.client.Pipeline()
+	ready := pipe.ZCard(ctx, q.ready)
+	delayed := pipe.ZCard(ctx, q.delayed)
+	processing := pipe.ZCard(ctx, q.processing)
+	dead := pipe.ZCard(ctx, q.dead)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return RedisQueueStats{}, err
+	}
+	return RedisQueueStats{
+		Ready:      ready.Val(),
+		Delayed:    delayed.Val(),
+		Processing: processing.Val(),
+		Dead:       dead.Val(),
+	}, nil
+}
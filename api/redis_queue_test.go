@@ -0,0 +1,304 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestRedisQueue 返回連接到 miniredis 的佇列和可撥動的時鐘
+func newTestRedisQueue(t *testing.T, opts RedisQueueOptions) (*RedisQueue[string], *redis.Client, *time.Time) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := NewRedisQueue[string](client, "jobs", opts)
+	q.now = func() time.Time { return now }
+	return q, client, &now
+}
+
+func mustClaim(t *testing.T, q *RedisQueue[string]) *Delivery[string] {
+	t.Helper()
+	d, err := q.Claim(context.Background())
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if d == nil {
+		t.Fatal("Claim returned no task")
+	}
+	return d
+}
+
+func assertEmpty(t *testing.T, q *RedisQueue[string]) {
+	t.Helper()
+	d, err := q.Claim(context.Background())
+	if err != nil || d != nil {
+		t.Fatalf("Claim = %v, %v, want no task", d, err)
+	}
+}
+
+func TestRedisQueuePriorityOrder(t *testing.T) {
+	q, client, _ := newTestRedisQueue(t, RedisQueueOptions{})
+	ctx := context.Background()
+	for _, task := range []struct {
+		value    string
+		priority int
+	}{{"a", 2}, {"b", 1}, {"c", 1}, {"d", 0}, {"e", -1}} {
+		if _, err := q.Enqueue(ctx, task.value, task.priority); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := q.Len(ctx); err != nil || n != 5 {
+		t.Errorf("Len = %d, %v, want 5", n, err)
+	}
+
+	// 另一個實例（如重啟後的進程）看到相同的任務
+	other := NewRedisQueue[string](client, "jobs", RedisQueueOptions{})
+	other.now = q.now
+	for _, want := range []string{"e", "d", "b", "c", "a"} {
+		d := mustClaim(t, other)
+		if d.Value != want || d.Attempt != 1 {
+			t.Errorf("Claim = %q attempt %d, want %q attempt 1", d.Value, d.Attempt, want)
+		}
+		if err := d.Ack(ctx); err != nil {
+			t.Errorf("Ack: %v", err)
+		}
+	}
+	assertEmpty(t, q)
+
+	// 同名以外的佇列互不影響
+	if _, err := NewRedisQueue[string](client, "other", RedisQueueOptions{}).Enqueue(ctx, "x", 0); err != nil {
+		t.Fatal(err)
+	}
+	assertEmpty(t, q)
+}
+
+func TestRedisQueueDelayed(t *testing.T) {
+	q, _, now := newTestRedisQueue(t, RedisQueueOptions{})
+	ctx := context.Background()
+
+	if _, err := q.EnqueueIn(ctx, "later", 0, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Schedule(ctx, "tomorrow", 0, now.Add(24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(ctx, "now", 5); err != nil {
+		t.Fatal(err)
+	}
+	d := mustClaim(t, q)
+	if d.Value != "now" {
+		t.Errorf("Claim = %q, want now", d.Value)
+	}
+	if err := d.Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertEmpty(t, q)
+
+	*now = now.Add(time.Minute)
+	if d := mustClaim(t, q); d.Value != "later" {
+		t.Errorf("Claim = %q, want later", d.Value)
+	}
+	assertEmpty(t, q)
+
+	stats, err := q.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (RedisQueueStats{Delayed: 1, Processing: 1}); stats != want {
+		t.Errorf("Stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestRedisQueueVisibilityTimeout(t *testing.T) {
+	q, _, now := newTestRedisQueue(t, RedisQueueOptions{VisibilityTimeout: time.Minute})
+	ctx := context.Background()
+	if _, err := q.Enqueue(ctx, "task", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	first := mustClaim(t, q)
+	*now = now.Add(59 * time.Second)
+	assertEmpty(t, q)
+
+	*now = now.Add(time.Second)
+	second := mustClaim(t, q)
+	if second.ID != first.ID || second.Attempt != 2 {
+		t.Errorf("redelivered %s attempt %d, want %s attempt 2", second.ID, second.Attempt, first.ID)
+	}
+	if err := first.Ack(ctx); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("Ack of expired delivery = %v, want ErrLeaseExpired", err)
+	}
+	if err := first.Nack(ctx, 0); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("Nack of expired delivery = %v, want ErrLeaseExpired", err)
+	}
+	if err := second.Ack(ctx); err != nil {
+		t.Errorf("Ack: %v", err)
+	}
+
+	*now = now.Add(time.Hour)
+	assertEmpty(t, q)
+	if stats, err := q.Stats(ctx); err != nil || stats != (RedisQueueStats{}) {
+		t.Errorf("Stats = %+v, %v, want all zero", stats, err)
+	}
+}
+
+func TestRedisQueueDeadLetter(t *testing.T) {
+	q, _, now := newTestRedisQueue(t, RedisQueueOptions{VisibilityTimeout: time.Minute, MaxAttempts: 3})
+	ctx := context.Background()
+	id, err := q.Enqueue(ctx, "poison", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次 Nack 延遲重試，第二次超時，第三次 Nack 後進入死信
+	if err := mustClaim(t, q).Nack(ctx, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	assertEmpty(t, q)
+	*now = now.Add(10 * time.Second)
+	mustClaim(t, q)
+	*now = now.Add(time.Minute)
+	d := mustClaim(t, q)
+	if d.Attempt != 3 {
+		t.Errorf("Attempt = %d, want 3", d.Attempt)
+	}
+	if err := d.Nack(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	assertEmpty(t, q)
+
+	letters, err := q.DeadLetters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DeadLetter[string]{{ID: id, Value: "poison", Priority: 7, Attempts: 3}}
+	if fmt.Sprint(letters) != fmt.Sprint(want) {
+		t.Errorf("DeadLetters = %+v, want %+v", letters, want)
+	}
+
+	if ok, err := q.Requeue(ctx, id); err != nil || !ok {
+		t.Fatalf("Requeue = %v, %v", ok, err)
+	}
+	if ok, _ := q.Requeue(ctx, id); ok {
+		t.Error("Requeue of a task not in the dead letter queue succeeded")
+	}
+	if d := mustClaim(t, q); d.Value != "poison" || d.Attempt != 1 {
+		t.Errorf("Claim after Requeue = %q attempt %d", d.Value, d.Attempt)
+	}
+
+	// 超時用盡投遞次數的任務同樣進入死信
+	q.opts.MaxAttempts = 1
+	*now = now.Add(time.Minute)
+	assertEmpty(t, q)
+	if stats, _ := q.Stats(ctx); stats != (RedisQueueStats{Dead: 1}) {
+		t.Errorf("Stats = %+v, want one dead task", stats)
+	}
+}
+
+func TestRedisQueueConsume(t *testing.T) {
+	q, _, _ := newTestRedisQueue(t, RedisQueueOptions{PollInterval: time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i, value := range []string{"a", "b", "c"} {
+		if _, err := q.Enqueue(ctx, value, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	failed := false
+	err := q.Consume(ctx, func(ctx context.Context, value string) error {
+		if value == "b" && !failed {
+			failed = true
+			return errors.New("try again")
+		}
+		got = append(got, value)
+		if len(got) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Consume = %v, want context.Canceled", err)
+	}
+	if fmt.Sprint(got) != "[a b c]" {
+		t.Errorf("consumed %v, want [a b c]", got)
+	}
+}
+
+func TestRedisQueueDequeueWait(t *testing.T) {
+	q, _, _ := newTestRedisQueue(t, RedisQueueOptions{PollInterval: time.Millisecond})
+	var consumer Consumer[string] = q
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := consumer.DequeueWait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DequeueWait on empty queue = %v, want context.DeadlineExceeded", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Enqueue(context.Background(), "task", 0)
+	}()
+	value, err := consumer.DequeueWait(context.Background())
+	if err != nil || value != "task" {
+		t.Errorf("DequeueWait = %q, %v, want task", value, err)
+	}
+	if n, _ := q.Len(context.Background()); n != 0 {
+		t.Errorf("Len = %d after DequeueWait, want 0", n)
+	}
+}
+
+func TestRedisQueueConcurrentClaims(t *testing.T) {
+	q, client, _ := newTestRedisQueue(t, RedisQueueOptions{})
+	ctx := context.Background()
+	const tasks, consumers = 200, 8
+	for i := 0; i < tasks; i++ {
+		if _, err := q.Enqueue(ctx, fmt.Sprint(i), i%5); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	var wg sync.WaitGroup
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			consumer := NewRedisQueue[string](client, "jobs", RedisQueueOptions{})
+			for {
+				value, ok, err := consumer.Dequeue(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !ok {
+					return
+				}
+				mu.Lock()
+				seen[value]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != tasks {
+		t.Errorf("consumed %d distinct tasks, want %d", len(seen), tasks)
+	}
+	for value, n := range seen {
+		if n != 1 {
+			t.Errorf("task %s consumed %d times", value, n)
+		}
+	}
+}
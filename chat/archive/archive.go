@@ -0,0 +1,195 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format 是歸檔文件的格式
+type Format string
+
+const (
+	FormatJSONL Format = "jsonl"
+	FormatCSV   Format = "csv"
+)
+
+// 記錄類型
+const (
+	KindRoom    = "room"    // 房間信息，位於歸檔開頭
+	KindMember  = "member"  // 房間成員
+	KindMessage = "message" // 聊天消息
+)
+
+// csvHeader 是 CSV 歸檔的列順序
+var csvHeader = []string{"kind", "id", "room", "sender", "content", "time"}
+
+// Record 是歸檔中的一條記錄
+// 房間記錄的時間為導出時間，成員記錄的時間為其首次發言時間
+// 目前聊天服務只保存消息與成員，編輯記錄與附件尚未建模，導出時不會出現
+type Record struct {
+	Kind    string    `json:"kind"`
+	ID      int       `json:"id,omitempty"`
+	Room    string    `json:"room,omitempty"`
+	Sender  string    `json:"sender,omitempty"` // 成員記錄中為用戶名
+	Content string    `json:"content,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// ParseFormat 解析格式名稱，空字符串默認為 JSON Lines
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "jsonl", "ndjson", "json":
+		return FormatJSONL, nil
+	case "csv":
+		return FormatCSV, nil
+	default:
+		return "", fmt.Errorf("unsupported archive format %q", name)
+	}
+}
+
+// ContentType 返回格式對應的 MIME 類型
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Writer 逐條寫入歸檔記錄
+type Writer interface {
+	Write(Record) error
+	Flush() error
+}
+
+// NewWriter 創建指定格式的 Writer
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+type jsonlWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(r Record) error {
+	return j.enc.Encode(r)
+}
+
+func (j *jsonlWriter) Flush() error {
+	return j.w.Flush()
+}
+
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (c *csvWriter) Write(r Record) error {
+	if !c.wroteHeader {
+		if err := c.w.Write(csvHeader); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+
+	var id, ts string
+	if r.ID != 0 {
+		id = strconv.Itoa(r.ID)
+	}
+	if !r.Time.IsZero() {
+		ts = r.Time.Format(time.RFC3339Nano)
+	}
+	return c.w.Write([]string{r.Kind, id, r.Room, r.Sender, r.Content, ts})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Reader 逐條讀取歸檔記錄，讀完時返回 io.EOF
+type Reader interface {
+	Next() (Record, error)
+}
+
+// NewReader 創建指定格式的 Reader
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case FormatJSONL:
+		return &jsonlReader{dec: json.NewDecoder(r)}, nil
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = len(csvHeader)
+		return &csvReader{r: cr}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+type jsonlReader struct {
+	dec  *json.Decoder
+	line int
+}
+
+func (j *jsonlReader) Next() (Record, error) {
+	var r Record
+	j.line++
+	if err := j.dec.Decode(&r); err != nil {
+		if err == io.EOF {
+			return r, io.EOF
+		}
+		return r, fmt.Errorf("record %d: %w", j.line, err)
+	}
+	return r, nil
+}
+
+type csvReader struct {
+	r          *csv.Reader
+	readHeader bool
+}
+
+func (c *csvReader) Next() (Record, error) {
+	if !c.readHeader {
+		header, err := c.r.Read()
+		if err != nil {
+			return Record{}, err
+		}
+		if strings.Join(header, ",") != strings.Join(csvHeader, ",") {
+			return Record{}, fmt.Errorf("unexpected csv header %v", header)
+		}
+		c.readHeader = true
+	}
+
+	fields, err := c.r.Read()
+	if err != nil {
+		return Record{}, err
+	}
+	line, _ := c.r.FieldPos(0)
+
+	r := Record{Kind: fields[0], Room: fields[2], Sender: fields[3], Content: fields[4]}
+	if fields[1] != "" {
+		if r.ID, err = strconv.Atoi(fields[1]); err != nil {
+			return r, fmt.Errorf("line %d: invalid id: %w", line, err)
+		}
+	}
+	if fields[5] != "" {
+		if r.Time, err = time.Parse(time.RFC3339Nano, fields[5]); err != nil {
+			return r, fmt.Errorf("line %d: invalid time: %w", line, err)
+		}
+	}
+	return r, nil
+}
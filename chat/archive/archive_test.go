@@ -0,0 +1,92 @@
+package archive_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/m/chat/archive"
+	"github.com/stretchr/testify/assert"
+)
+
+func sampleRecords() []archive.Record {
+	t0 := time.Date(2024, 10, 1, 8, 30, 0, 0, time.UTC)
+	return []archive.Record{
+		{Kind: archive.KindRoom, Room: "general", Time: t0.Add(time.Hour)},
+		{Kind: archive.KindMember, Room: "general", Sender: "alice", Time: t0},
+		{Kind: archive.KindMessage, ID: 7, Room: "general", Sender: "alice", Content: "hello, \"world\"", Time: t0},
+		{Kind: archive.KindMessage, ID: 9, Room: "general", Sender: "bob", Content: "multi\nline", Time: t0.Add(time.Minute)},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []archive.Format{archive.FormatJSONL, archive.FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := archive.NewWriter(&buf, format)
+			assert.NoError(t, err)
+			for _, r := range sampleRecords() {
+				assert.NoError(t, w.Write(r))
+			}
+			assert.NoError(t, w.Flush())
+
+			r, err := archive.NewReader(&buf, format)
+			assert.NoError(t, err)
+
+			var got []archive.Record
+			for {
+				record, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if !assert.NoError(t, err) {
+					return
+				}
+				got = append(got, record)
+			}
+
+			want := sampleRecords()
+			if assert.Len(t, got, len(want)) {
+				for i := range want {
+					assert.Equal(t, want[i].Kind, got[i].Kind)
+					assert.Equal(t, want[i].ID, got[i].ID)
+					assert.Equal(t, want[i].Sender, got[i].Sender)
+					assert.Equal(t, want[i].Content, got[i].Content)
+					assert.True(t, want[i].Time.Equal(got[i].Time))
+				}
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := archive.ParseFormat("")
+	assert.NoError(t, err)
+	assert.Equal(t, archive.FormatJSONL, format)
+
+	format, err = archive.ParseFormat("CSV")
+	assert.NoError(t, err)
+	assert.Equal(t, archive.FormatCSV, format)
+
+	_, err = archive.ParseFormat("mbox")
+	assert.Error(t, err)
+}
+
+func TestCSVReaderRejectsBadHeader(t *testing.T) {
+	r, err := archive.NewReader(strings.NewReader("a,b,c,d,e,f\n"), archive.FormatCSV)
+	assert.NoError(t, err)
+	_, err = r.Next()
+	assert.Error(t, err)
+}
+
+func TestJSONLReaderReportsBadRecord(t *testing.T) {
+	r, err := archive.NewReader(strings.NewReader(`{"kind":"message","id":1}`+"\n{oops\n"), archive.FormatJSONL)
+	assert.NoError(t, err)
+
+	_, err = r.Next()
+	assert.NoError(t, err)
+	_, err = r.Next()
+	assert.ErrorContains(t, err, "record 2")
+}
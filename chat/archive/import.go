@@ -0,0 +1,93 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ImportOptions 控制歸檔導入
+type ImportOptions struct {
+	// Room 為目標房間，為空時使用歸檔中記錄的房間
+	Room string
+}
+
+// ImportResult 是導入的統計結果
+type ImportResult struct {
+	Room     string
+	Messages int
+	Members  int
+	// IDMap 記錄歸檔中的消息 ID 與新插入的消息 ID 的對應關係
+	IDMap map[int]int
+}
+
+// Import 將歸檔重放到 chat_messages，所有消息在同一個事務中插入
+// 消息會獲得新的 ID，原 ID 與新 ID 的對應關係記錄在 IDMap 中
+func Import(ctx context.Context, db *pgxpool.Pool, r Reader, opts ImportOptions) (*ImportResult, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := importRecords(ctx, tx, r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return result, nil
+}
+
+func importRecords(ctx context.Context, tx pgx.Tx, r Reader, opts ImportOptions) (*ImportResult, error) {
+	result := &ImportResult{Room: opts.Room, IDMap: make(map[int]int)}
+
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		switch record.Kind {
+		case KindRoom:
+			if result.Room == "" {
+				result.Room = record.Room
+			}
+		case KindMember:
+			// 成員由消息的發送者隱式構成，這裡只做統計
+			result.Members++
+		case KindMessage:
+			room := result.Room
+			if room == "" {
+				room = record.Room
+			}
+			if room == "" {
+				return nil, fmt.Errorf("message %d has no room and no target room was given", record.ID)
+			}
+
+			var newID int
+			err := tx.QueryRow(ctx, "INSERT INTO chat_messages (room, sender, content, time) VALUES ($1, $2, $3, $4) RETURNING id",
+				room, record.Sender, record.Content, record.Time).Scan(&newID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to insert message %d: %w", record.ID, err)
+			}
+			if _, exists := result.IDMap[record.ID]; exists {
+				return nil, fmt.Errorf("duplicate message id %d in archive", record.ID)
+			}
+			result.IDMap[record.ID] = newID
+			result.Messages++
+		default:
+			return nil, fmt.Errorf("unknown record kind %q", record.Kind)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,464 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted 用於在輸出配置時替換密鑰
+const redacted = "[REDACTED]"
+
+// Current 是聊天服務器當前生效的配置，由 Load 設置
+// 未調用 Load 時，Init 會使用默認值與環境變量
+var Current *Config
+
+// Config 是聊天服務器的完整配置
+// 優先級從低到高為：默認值、配置文件（YAML 或 TOML）、環境變量、命令行參數
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Postgres  PostgresConfig  `yaml:"postgres" toml:"postgres"`
+	Redis     RedisConfig     `yaml:"redis" toml:"redis"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Retention RetentionConfig `yaml:"retention" toml:"retention"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+}
+
+type ServerConfig struct {
+	Addr        string   `yaml:"addr" toml:"addr"`               // HTTP 監聽地址
+	CORSOrigins []string `yaml:"corsOrigins" toml:"corsOrigins"` // 允許的跨域來源
+	BuildDir    string   `yaml:"buildDir" toml:"buildDir"`       // 前端 SPA 的 build 目錄
+	PublicDir   string   `yaml:"publicDir" toml:"publicDir"`     // css/js/resources 靜態文件目錄
+	// 收到退出信號後等待連接與消息寫入完成的最長時間
+	ShutdownTimeout Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout"`
+}
+
+type PostgresConfig struct {
+	Host         string `yaml:"host" toml:"host"`
+	Port         int    `yaml:"port" toml:"port"`
+	User         string `yaml:"user" toml:"user"`
+	Password     string `yaml:"password" toml:"password"`
+	PasswordFile string `yaml:"passwordFile" toml:"passwordFile"` // 設置時從文件讀取密碼
+	Database     string `yaml:"database" toml:"database"`
+	SSLMode      string `yaml:"sslMode" toml:"sslMode"`
+}
+
+type RedisConfig struct {
+	Host         string `yaml:"host" toml:"host"`
+	Port         int    `yaml:"port" toml:"port"`
+	Password     string `yaml:"password" toml:"password"`
+	PasswordFile string `yaml:"passwordFile" toml:"passwordFile"` // 設置時從文件讀取密碼
+	DB           int    `yaml:"db" toml:"db"`
+}
+
+type LogConfig struct {
+	File  string `yaml:"file" toml:"file"`   // 為空時輸出到 stderr
+	Level string `yaml:"level" toml:"level"` // logrus 日誌級別
+}
+
+type AuthConfig struct {
+	JWTSecret           string   `yaml:"jwtSecret" toml:"jwtSecret"`
+	JWTSecretFile       string   `yaml:"jwtSecretFile" toml:"jwtSecretFile"`
+	SessionSecret       string   `yaml:"sessionSecret" toml:"sessionSecret"`
+	SessionSecretFile   string   `yaml:"sessionSecretFile" toml:"sessionSecretFile"`
+	RegistrationKey     string   `yaml:"registrationKey" toml:"registrationKey"` // 前端加密註冊數據使用的 AES 密鑰
+	RegistrationKeyFile string   `yaml:"registrationKeyFile" toml:"registrationKeyFile"`
+	AdminUsers          []string `yaml:"adminUsers" toml:"adminUsers"` // 可以使用 /admin 接口的用戶
+}
+
+type RetentionConfig struct {
+	Interval  Duration `yaml:"interval" toml:"interval"`   // 歸檔任務的運行間隔
+	BatchSize int      `yaml:"batchSize" toml:"batchSize"` // 每個事務歸檔的最大消息數
+}
+
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter" toml:"exporter"`       // none、otlp、otlp-grpc、zipkin、jaeger 或 stdout
+	Endpoint    string `yaml:"endpoint" toml:"endpoint"`       // 導出器地址，為空時使用導出器的默認地址
+	ServiceName string `yaml:"serviceName" toml:"serviceName"` // 上報的服務名
+	// 包含錯誤或慢 span 的追蹤全部導出，其餘按 SampleRatio 採樣
+	SampleRatio   float64  `yaml:"sampleRatio" toml:"sampleRatio"`
+	SlowThreshold Duration `yaml:"slowThreshold" toml:"slowThreshold"`
+}
+
+// Duration 是可以用 "10m"、"1h" 等格式寫入配置文件的時間長度
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Default 返回默認配置，與之前硬編碼的值保持一致
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			CORSOrigins:     []string{"*"},
+			BuildDir:        "./chat/chat-app/build",
+			PublicDir:       "public",
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		Postgres: PostgresConfig{
+			Host:     "localhost",
+			Port:     5432,
+			User:     "postgres",
+			Password: "henry",
+			Database: "test",
+			SSLMode:  "disable",
+		},
+		Redis: RedisConfig{
+			Host: "localhost",
+			Port: 6379,
+		},
+		Log: LogConfig{
+			File:  "app.log",
+			Level: "info",
+		},
+		Auth: AuthConfig{
+			JWTSecret:       "your-secret-key",
+			SessionSecret:   "secret",
+			RegistrationKey: "your-secret-key1",
+		},
+		Retention: RetentionConfig{
+			Interval:  Duration(time.Hour),
+			BatchSize: 1000,
+		},
+		Tracing: TracingConfig{
+			Exporter:      "none",
+			ServiceName:   "chat-server",
+			SampleRatio:   0.1,
+			SlowThreshold: Duration(500 * time.Millisecond),
+		},
+	}
+}
+
+// DSN 返回 PostgreSQL 連接字符串
+func (p PostgresConfig) DSN() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(p.User, p.Password),
+		Host:     net.JoinHostPort(p.Host, strconv.Itoa(p.Port)),
+		Path:     "/" + p.Database,
+		RawQuery: "sslmode=" + url.QueryEscape(p.SSLMode),
+	}
+	return u.String()
+}
+
+// IsAdmin 判斷用戶是否在 auth.adminUsers 中
+func (a AuthConfig) IsAdmin(username string) bool {
+	for _, admin := range a.AdminUsers {
+		if username != "" && admin == username {
+			return true
+		}
+	}
+	return false
+}
+
+// Addr 返回 Redis 地址
+func (r RedisConfig) Addr() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+}
+
+// setting 描述一個可以通過環境變量與命令行參數覆蓋的配置項
+type setting struct {
+	flag   string
+	env    []string // 多個環境變量時，靠前的優先
+	usage  string
+	secret bool
+	target interface{}
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"chat-addr", []string{"CHAT_SERVER_ADDR"}, "Chat server listen address", false, &c.Server.Addr},
+		{"chat-cors-origins", []string{"CHAT_CORS_ORIGINS"}, "Comma separated list of allowed CORS origins", false, &c.Server.CORSOrigins},
+		{"chat-build-dir", []string{"CHAT_BUILD_DIR"}, "Directory of the chat SPA build", false, &c.Server.BuildDir},
+		{"chat-public-dir", []string{"CHAT_PUBLIC_DIR"}, "Directory of css/js/resources static files", false, &c.Server.PublicDir},
+		{"chat-shutdown-timeout", []string{"CHAT_SHUTDOWN_TIMEOUT"}, "Time to drain connections and writes on shutdown", false, &c.Server.ShutdownTimeout},
+		{"chat-postgres-host", []string{"CHAT_POSTGRES_HOST", "DATABASE_URL"}, "PostgreSQL host", false, &c.Postgres.Host},
+		{"chat-postgres-port", []string{"CHAT_POSTGRES_PORT"}, "PostgreSQL port", false, &c.Postgres.Port},
+		{"chat-postgres-user", []string{"CHAT_POSTGRES_USER"}, "PostgreSQL user", false, &c.Postgres.User},
+		{"chat-postgres-password", []string{"CHAT_POSTGRES_PASSWORD"}, "PostgreSQL password", true, &c.Postgres.Password},
+		{"chat-postgres-password-file", []string{"CHAT_POSTGRES_PASSWORD_FILE"}, "File containing the PostgreSQL password", false, &c.Postgres.PasswordFile},
+		{"chat-postgres-database", []string{"CHAT_POSTGRES_DATABASE"}, "PostgreSQL database", false, &c.Postgres.Database},
+		{"chat-postgres-sslmode", []string{"CHAT_POSTGRES_SSLMODE"}, "PostgreSQL sslmode", false, &c.Postgres.SSLMode},
+		{"chat-redis-host", []string{"CHAT_REDIS_HOST", "REDIS_URL"}, "Redis host", false, &c.Redis.Host},
+		{"chat-redis-port", []string{"CHAT_REDIS_PORT"}, "Redis port", false, &c.Redis.Port},
+		{"chat-redis-password", []string{"CHAT_REDIS_PASSWORD"}, "Redis password", true, &c.Redis.Password},
+		{"chat-redis-password-file", []string{"CHAT_REDIS_PASSWORD_FILE"}, "File containing the Redis password", false, &c.Redis.PasswordFile},
+		{"chat-redis-db", []string{"CHAT_REDIS_DB"}, "Redis database number", false, &c.Redis.DB},
+		{"chat-log-file", []string{"CHAT_LOG_FILE"}, "Log file, empty to log to stderr", false, &c.Log.File},
+		{"chat-log-level", []string{"CHAT_LOG_LEVEL"}, "Log level", false, &c.Log.Level},
+		{"chat-jwt-secret", []string{"CHAT_JWT_SECRET"}, "JWT signing secret", true, &c.Auth.JWTSecret},
+		{"chat-jwt-secret-file", []string{"CHAT_JWT_SECRET_FILE"}, "File containing the JWT signing secret", false, &c.Auth.JWTSecretFile},
+		{"chat-session-secret", []string{"CHAT_SESSION_SECRET"}, "Session cookie secret", true, &c.Auth.SessionSecret},
+		{"chat-session-secret-file", []string{"CHAT_SESSION_SECRET_FILE"}, "File containing the session cookie secret", false, &c.Auth.SessionSecretFile},
+		{"chat-registration-key", []string{"CHAT_REGISTRATION_KEY"}, "AES key used to decrypt registration data", true, &c.Auth.RegistrationKey},
+		{"chat-admin-users", []string{"CHAT_ADMIN_USERS"}, "Comma separated list of users allowed to use the admin endpoints", false, &c.Auth.AdminUsers},
+		{"chat-registration-key-file", []string{"CHAT_REGISTRATION_KEY_FILE"}, "File containing the registration AES key", false, &c.Auth.RegistrationKeyFile},
+		{"chat-retention-interval", []string{"CHAT_RETENTION_INTERVAL"}, "Interval between retention runs", false, &c.Retention.Interval},
+		{"chat-retention-batch-size", []string{"CHAT_RETENTION_BATCH_SIZE"}, "Messages archived per retention transaction", false, &c.Retention.BatchSize},
+		{"chat-tracing-exporter", []string{"CHAT_TRACING_EXPORTER"}, "Trace exporter: none, otlp, otlp-grpc, zipkin, jaeger or stdout", false, &c.Tracing.Exporter},
+		{"chat-tracing-endpoint", []string{"CHAT_TRACING_ENDPOINT"}, "Trace exporter endpoint, empty for the exporter default", false, &c.Tracing.Endpoint},
+		{"chat-tracing-service-name", []string{"CHAT_TRACING_SERVICE_NAME"}, "Service name reported with traces", false, &c.Tracing.ServiceName},
+		{"chat-tracing-sample-ratio", []string{"CHAT_TRACING_SAMPLE_RATIO"}, "Share of traces without errors or slow spans to export, 0 to 1", false, &c.Tracing.SampleRatio},
+		{"chat-tracing-slow-threshold", []string{"CHAT_TRACING_SLOW_THRESHOLD"}, "Export every trace with a span at least this slow, 0 to disable", false, &c.Tracing.SlowThreshold},
+	}
+}
+
+// set 將字符串值解析後寫入配置項
+func (s setting) set(value string) error {
+	switch target := s.target.(type) {
+	case *string:
+		*target = value
+	case *int:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid number %q", s.flag, value)
+		}
+		*target = v
+	case *float64:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s: invalid number %q", s.flag, value)
+		}
+		*target = v
+	case *[]string:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*target = items
+	case *Duration:
+		if err := target.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("%s: invalid duration %q", s.flag, value)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported setting type %T", s.flag, s.target)
+	}
+	return nil
+}
+
+// Flags 保存聊天服務器註冊的命令行參數
+type Flags struct {
+	ConfigFile  *string
+	PrintConfig *bool
+	fs          *flag.FlagSet
+	values      map[string]*string
+}
+
+// RegisterFlags 在 fs 上註冊 -config、-print-config 以及所有 -chat-* 參數
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{
+		ConfigFile:  fs.String("config", "", "Chat server config file (YAML or TOML), also read from CHAT_CONFIG"),
+		PrintConfig: fs.Bool("print-config", false, "Print the effective chat server config with secrets redacted and exit"),
+		fs:          fs,
+		values:      make(map[string]*string),
+	}
+	for _, s := range Default().settings() {
+		f.values[s.flag] = fs.String(s.flag, "", s.usage)
+	}
+	return f
+}
+
+// Load 按優先級加載配置並校驗，flags 可以為 nil
+func Load(flags *Flags) (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("CHAT_CONFIG")
+	if flags != nil && *flags.ConfigFile != "" {
+		path = *flags.ConfigFile
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if flags != nil {
+		if err := cfg.applyFlags(flags); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadSecretFiles(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) { // 空文件使用默認值
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(c); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	return nil
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	for _, s := range c.settings() {
+		for _, name := range s.env {
+			if value, ok := lookup(name); ok && value != "" {
+				if err := s.set(value); err != nil {
+					return fmt.Errorf("environment variable %s: %w", name, err)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) applyFlags(flags *Flags) error {
+	// 只有顯式傳入的參數才覆蓋，未傳入的參數不會把值重置為空
+	set := make(map[string]bool)
+	flags.fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for _, s := range c.settings() {
+		if set[s.flag] {
+			if err := s.set(*flags.values[s.flag]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadSecretFiles 從 *File 指定的文件讀取密鑰，文件優先於直接配置的值
+func (c *Config) loadSecretFiles() error {
+	secrets := []struct {
+		file   string
+		target *string
+	}{
+		{c.Postgres.PasswordFile, &c.Postgres.Password},
+		{c.Redis.PasswordFile, &c.Redis.Password},
+		{c.Auth.JWTSecretFile, &c.Auth.JWTSecret},
+		{c.Auth.SessionSecretFile, &c.Auth.SessionSecret},
+		{c.Auth.RegistrationKeyFile, &c.Auth.RegistrationKey},
+	}
+	for _, s := range secrets {
+		if s.file == "" {
+			continue
+		}
+		data, err := os.ReadFile(s.file)
+		if err != nil {
+			return fmt.Errorf("failed to read secret file: %w", err)
+		}
+		*s.target = strings.TrimRight(string(data), "\r\n")
+	}
+	return nil
+}
+
+// Validate 校驗配置，返回所有錯誤
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr is required")
+	check(len(c.Server.CORSOrigins) > 0, "server.corsOrigins must not be empty")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdownTimeout must be positive")
+	check(c.Postgres.Host != "", "postgres.host is required")
+	check(c.Postgres.Port > 0 && c.Postgres.Port < 65536, "postgres.port %d is out of range", c.Postgres.Port)
+	check(c.Postgres.User != "", "postgres.user is required")
+	check(c.Postgres.Database != "", "postgres.database is required")
+	switch c.Postgres.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		check(false, "postgres.sslMode %q is invalid", c.Postgres.SSLMode)
+	}
+	check(c.Redis.Host != "", "redis.host is required")
+	check(c.Redis.Port > 0 && c.Redis.Port < 65536, "redis.port %d is out of range", c.Redis.Port)
+	check(c.Redis.DB >= 0, "redis.db must not be negative")
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		check(false, "log.level %q is invalid", c.Log.Level)
+	}
+	check(c.Auth.JWTSecret != "", "auth.jwtSecret is required")
+	check(c.Auth.SessionSecret != "", "auth.sessionSecret is required")
+	switch len(c.Auth.RegistrationKey) {
+	case 16, 24, 32:
+	default:
+		check(false, "auth.registrationKey must be 16, 24 or 32 bytes")
+	}
+	check(c.Retention.Interval > 0, "retention.interval must be positive")
+	check(c.Retention.BatchSize > 0, "retention.batchSize must be positive")
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "otlp-grpc", "zipkin", "jaeger", "stdout":
+	default:
+		check(false, "tracing.exporter %q is invalid", c.Tracing.Exporter)
+	}
+	check(c.Tracing.ServiceName != "", "tracing.serviceName is required")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sampleRatio must be between 0 and 1")
+	check(c.Tracing.SlowThreshold >= 0, "tracing.slowThreshold must not be negative")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Redacted 返回隱藏了密鑰的配置副本
+func (c *Config) Redacted() *Config {
+	copied := *c
+	copied.Server.CORSOrigins = append([]string(nil), c.Server.CORSOrigins...)
+	for _, s := range copied.settings() {
+		if target, ok := s.target.(*string); ok && s.secret && *target != "" {
+			*target = redacted
+		}
+	}
+	return &copied
+}
+
+// YAML 將隱藏密鑰後的配置編碼為 YAML
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c.Redacted())
+}
@@ -1,18 +0,0 @@
-package config
-
-import (
-	"os"
-
-	"github.com/sirupsen/logrus"
-)
-
-func InitLogger() {
-	Log = logrus.New()
-	Log.SetFormatter(&logrus.JSONFormatter{})
-	file, err := os.OpenFile("app.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err == nil {
-		Log.Out = file
-	} else {
-		Log.Warn("Failed to log to file, using default stderr")
-	}
-}
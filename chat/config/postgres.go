@@ -3,9 +3,11 @@ package config
 import (
 	"context"
 	"fmt"
-	"os"
 	"time"
 
+	"example.com/m/chat/metrics"
+	"example.com/m/chat/telemetry"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,14 +19,50 @@ type ChatMessage struct {
 	Time    time.Time `json:"time"`    // Message sending time
 }
 
+type OutgoingWebhook struct {
+	ID        int       `json:"id"`        // Webhook ID
+	Room      string    `json:"room"`      // Room name
+	URL       string    `json:"url"`       // Delivery URL
+	Secret    string    `json:"-"`         // HMAC signing secret
+	CreatedBy string    `json:"createdBy"` // Creator username
+	Active    bool      `json:"active"`    // Whether deliveries are enabled
+	Time      time.Time `json:"time"`      // Creation time
+}
+
+type WebhookDelivery struct {
+	ID         int       `json:"id"`         // Delivery log ID
+	WebhookID  int       `json:"webhookId"`  // Outgoing webhook ID
+	DeliveryID string    `json:"deliveryId"` // Shared by all attempts of one delivery
+	MessageID  int       `json:"messageId"`  // Delivered message ID
+	Attempt    int       `json:"attempt"`    // Attempt number, starting at 1
+	StatusCode int       `json:"statusCode"` // Receiver HTTP status code
+	Error      string    `json:"error"`      // Transport error, if any
+	Success    bool      `json:"success"`    // Whether the attempt succeeded
+	DurationMs int       `json:"durationMs"` // Attempt duration
+	Time       time.Time `json:"time"`       // Attempt time
+}
+
+type Notification struct {
+	ID        int       `json:"id"`        // Notification ID
+	Username  string    `json:"username"`  // Mentioned user
+	Room      string    `json:"room"`      // Room name
+	Sender    string    `json:"sender"`    // Sender of the mentioning message
+	MessageID int       `json:"messageId"` // Mentioning message ID
+	Content   string    `json:"content"`   // Mentioning message content
+	Read      bool      `json:"read"`      // Whether the notification has been read
+	Time      time.Time `json:"time"`      // Notification creation time
+}
+
 func InitDB() (*pgxpool.Pool, error) {
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		databaseURL = "localhost"
+	url := current().Postgres.DSN()
+
+	poolConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PostgreSQL configuration: %w", err)
 	}
-	url := "postgres://postgres:henry@" + databaseURL + ":5432/test?sslmode=disable"
+	poolConfig.ConnConfig.Tracer = multitracer.New(telemetry.PgxTracer{}, metrics.PgxTracer{})
 
-	pool, err := pgxpool.New(context.Background(), url)
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
 	}
@@ -81,5 +119,143 @@ func CheckAndCreateTableChat(db *pgxpool.Pool) error {
 		return err
 	}
 
+	chatTableSQL = `
+		CREATE TABLE notifications (
+		id SERIAL PRIMARY KEY,
+		username VARCHAR(50) NOT NULL,
+		room VARCHAR(255),
+		sender VARCHAR(255),
+		message_id INT,
+		content TEXT,
+		is_read BOOLEAN NOT NULL DEFAULT FALSE,
+		time TIMESTAMPTZ DEFAULT NOW()
+	);
+		CREATE INDEX idx_notifications_username_unread ON notifications (username, is_read);`
+	if err := checkAndCreateTable(db, "notifications", chatTableSQL); err != nil {
+		return err
+	}
+
+	chatTableSQL = `
+		CREATE TABLE incoming_webhooks (
+		id SERIAL PRIMARY KEY,
+		room VARCHAR(255) NOT NULL,
+		token VARCHAR(64) UNIQUE NOT NULL,
+		bot_name VARCHAR(50) NOT NULL,
+		created_by VARCHAR(50),
+		time TIMESTAMPTZ DEFAULT NOW()
+	);`
+	if err := checkAndCreateTable(db, "incoming_webhooks", chatTableSQL); err != nil {
+		return err
+	}
+
+	chatTableSQL = `
+		CREATE TABLE outgoing_webhooks (
+		id SERIAL PRIMARY KEY,
+		room VARCHAR(255) NOT NULL,
+		url TEXT NOT NULL,
+		secret VARCHAR(64) NOT NULL,
+		created_by VARCHAR(50),
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		time TIMESTAMPTZ DEFAULT NOW()
+	);`
+	if err := checkAndCreateTable(db, "outgoing_webhooks", chatTableSQL); err != nil {
+		return err
+	}
+
+	chatTableSQL = `
+		CREATE TABLE webhook_deliveries (
+		id SERIAL PRIMARY KEY,
+		webhook_id INT NOT NULL,
+		delivery_id VARCHAR(36) NOT NULL,
+		message_id INT,
+		attempt INT NOT NULL,
+		status_code INT,
+		error TEXT,
+		success BOOLEAN NOT NULL,
+		duration_ms INT,
+		time TIMESTAMPTZ DEFAULT NOW()
+	);
+		CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, time);`
+	if err := checkAndCreateTable(db, "webhook_deliveries", chatTableSQL); err != nil {
+		return err
+	}
+
+	chatTableSQL = `
+		CREATE TABLE room_topics (
+		room VARCHAR(255) PRIMARY KEY,
+		topic TEXT NOT NULL,
+		set_by VARCHAR(50),
+		time TIMESTAMPTZ DEFAULT NOW()
+	);`
+	if err := checkAndCreateTable(db, "room_topics", chatTableSQL); err != nil {
+		return err
+	}
+
+	chatTableSQL = `
+		CREATE TABLE room_moderators (
+		room VARCHAR(255) NOT NULL,
+		username VARCHAR(50) NOT NULL,
+		time TIMESTAMPTZ DEFAULT NOW(),
+		PRIMARY KEY (room, username)
+	);`
+	if err := checkAndCreateTable(db, "room_moderators", chatTableSQL); err != nil {
+		return err
+	}
+
+	chatTableSQL = `
+		CREATE TABLE room_retention_policies (
+		room VARCHAR(255) PRIMARY KEY,
+		keep_days INT NOT NULL DEFAULT 0,
+		keep_messages INT NOT NULL DEFAULT 0,
+		updated_by VARCHAR(50),
+		updated_at TIMESTAMPTZ DEFAULT NOW()
+	);`
+	if err := checkAndCreateTable(db, "room_retention_policies", chatTableSQL); err != nil {
+		return err
+	}
+
+	chatTableSQL = `
+		CREATE TABLE chat_message_archives (
+		id SERIAL PRIMARY KEY,
+		room VARCHAR(255) NOT NULL,
+		first_message_id INT NOT NULL,
+		last_message_id INT NOT NULL,
+		first_time TIMESTAMPTZ NOT NULL,
+		last_time TIMESTAMPTZ NOT NULL,
+		row_count INT NOT NULL,
+		payload BYTEA NOT NULL,
+		archived_at TIMESTAMPTZ DEFAULT NOW()
+	);
+		CREATE INDEX idx_chat_message_archives_room ON chat_message_archives (room, first_time);`
+	if err := checkAndCreateTable(db, "chat_message_archives", chatTableSQL); err != nil {
+		return err
+	}
+
+	// room_members 记录在房间发过言的用户，用于解析 @room，避免扫描整个房间的历史
+	// 创建时从已有消息回填，之后由 chat_messages 的触发器维护
+	chatTableSQL = `
+		CREATE TABLE room_members (
+		room VARCHAR(255) NOT NULL,
+		username VARCHAR(255) NOT NULL,
+		joined_at TIMESTAMPTZ DEFAULT NOW(),
+		PRIMARY KEY (room, username)
+	);
+		INSERT INTO room_members (room, username, joined_at)
+		SELECT room, sender, MIN(time) FROM chat_messages
+		WHERE room IS NOT NULL AND sender IS NOT NULL GROUP BY room, sender;
+		CREATE OR REPLACE FUNCTION chat_add_room_member() RETURNS trigger AS $$
+		BEGIN
+			INSERT INTO room_members (room, username, joined_at) VALUES (NEW.room, NEW.sender, NEW.time)
+			ON CONFLICT (room, username) DO NOTHING;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+		CREATE TRIGGER chat_messages_room_member AFTER INSERT ON chat_messages
+		FOR EACH ROW WHEN (NEW.room IS NOT NULL AND NEW.sender IS NOT NULL)
+		EXECUTE FUNCTION chat_add_room_member();`
+	if err := checkAndCreateTable(db, "room_members", chatTableSQL); err != nil {
+		return err
+	}
+
 	return nil
 }
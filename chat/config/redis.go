@@ -2,23 +2,22 @@ package config
 
 import (
 	"fmt"
-	"os"
 
+	"example.com/m/chat/metrics"
+	"example.com/m/chat/telemetry"
 	"github.com/go-redis/redis/v8"
 )
 
 func InitRedis() (*redis.Client, error) {
-	redisURL := os.Getenv("REDIS_URL")
-	if redisURL == "" {
-		redisURL = "localhost"
-	}
-	url := redisURL + ":6379"
+	cfg := current().Redis
 
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     url,
-		Password: "",
-		DB:       0,
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
 	})
+	rdb.AddHook(telemetry.RedisHook{})
+	rdb.AddHook(metrics.RedisHook{})
 
 	_, err := rdb.Ping(Ctx).Result()
 	if err != nil {
@@ -8,11 +8,10 @@ import (
 	"sync"
 	"time"
 
-	"example.com/m/chat/metrics"
+	"example.com/m/chat/logging"
 	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/websocket"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -24,34 +23,14 @@ var (
 	Clients     = make(map[*websocket.Conn]string)
 	SessionTTL  = 10 * time.Minute
 	Mu          sync.Mutex
-	Logger      = logrus.New()
+	Logger      = logging.Logger
 	AuthKey     = "YOUR_GENERATED_AUTH_KEY"
 	SecretKey   = "YOUR_GENERATED_SECRET_KEY"
-	Log         *logrus.Logger
-
-	// Prometheus metrics
-	RegisterUserCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "user_registration_total",
-			Help: "Total number of user registrations",
-		},
-		[]string{"status"},
-	)
-
-	LoginCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "user_login_total",
-			Help: "Total number of user logins",
-		},
-		[]string{"status"},
-	)
-	MessageCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "chat_messages_total",
-		Help: "Total number of chat messages sent",
-	}, []string{"room"})
 )
 
 func Init() {
+	cfg := current()
+
 	var err error
 	// 初始化 Redis 客戶端
 	RedisClient, err = InitRedis()
@@ -63,20 +42,28 @@ func Init() {
 		log.Fatalf("Error checking/creating chat table: %v", err)
 	}
 
-	// 初始化 Prometheus 监控
-	metrics.InitMetrics()
-
-	// 註冊 Prometheus 指標
-	prometheus.MustRegister(RegisterUserCounter)
-	prometheus.MustRegister(LoginCounter)
-	prometheus.MustRegister(MessageCounter)
-
-	// 日誌配置
-	Logger.SetFormatter(&logrus.JSONFormatter{})
-	file, err := os.OpenFile("app.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	// 日誌配置，級別可以通過 /admin/log-level 在運行時修改
+	level, _ := logrus.ParseLevel(cfg.Log.Level) // 已在 Validate 中校驗
+	Logger.SetLevel(level)
+	if cfg.Log.File == "" {
+		return
+	}
+	file, err := os.OpenFile(cfg.Log.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err == nil {
 		Logger.Out = file
 	} else {
 		log.Fatal("Failed to log to file, using default stderr")
 	}
 }
+
+// current 返回當前配置，未加載時按默認值與環境變量加載
+func current() *Config {
+	if Current == nil {
+		cfg, err := Load(nil)
+		if err != nil {
+			log.Fatalf("Error loading chat config: %v", err)
+		}
+		Current = cfg
+	}
+	return Current
+}
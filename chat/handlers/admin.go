@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type logLevelRequest struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// 仅允许 auth.adminUsers 中的用户访问，需在 JWT 中间件之后使用
+func RequireAdmin(c *gin.Context) {
+	if !config.Current.Auth.IsAdmin(c.GetString("username")) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	c.Next()
+}
+
+// 获取当前日志级别
+func GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"level": config.Logger.GetLevel().String()})
+}
+
+// 在运行时修改日志级别，重启后恢复为 log.level 配置
+func SetLogLevel(c *gin.Context) {
+	var req logLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	level, err := logrus.ParseLevel(req.Level)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous := config.Logger.GetLevel()
+	config.Logger.SetLevel(level)
+	logging.FromGin(c).WithFields(logrus.Fields{"from": previous.String(), "to": level.String()}).Warn("Log level changed")
+
+	c.JSON(http.StatusOK, gin.H{"level": level.String()})
+}
+
+// 列出房间的管理员
+func ListRoomModerators(c *gin.Context) {
+	moderators, err := loadRoomModerators(c.Request.Context(), c.Param("room"))
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error fetching room moderators")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching room moderators"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"room": c.Param("room"), "moderators": moderators})
+}
+
+// 授予用户房间管理员权限，管理员可以使用 /mute、/unmute 并管理房间的 Webhook
+func AddRoomModerator(c *gin.Context) {
+	room, username := c.Param("room"), c.Param("username")
+
+	var exists bool
+	err := config.PgConn.QueryRow(c.Request.Context(), "SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)", username).Scan(&exists)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error checking user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking user"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	_, err = config.PgConn.Exec(c.Request.Context(),
+		"INSERT INTO room_moderators (room, username) VALUES ($1, $2) ON CONFLICT (room, username) DO NOTHING", room, username)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error adding room moderator")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding room moderator"})
+		return
+	}
+	logging.FromGin(c).WithFields(logrus.Fields{"room": room, "moderator": username}).Warn("Room moderator added")
+	if err := RefreshMetricRooms(c.Request.Context()); err != nil {
+		logging.FromGin(c).WithError(err).Warn("Error refreshing metric rooms")
+	}
+	c.JSON(http.StatusOK, gin.H{"room": room, "username": username})
+}
+
+// 撤销用户的房间管理员权限
+func RemoveRoomModerator(c *gin.Context) {
+	room, username := c.Param("room"), c.Param("username")
+	tag, err := config.PgConn.Exec(c.Request.Context(), "DELETE FROM room_moderators WHERE room = $1 AND username = $2", room, username)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error removing room moderator")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error removing room moderator"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not a moderator of this room"})
+		return
+	}
+	logging.FromGin(c).WithFields(logrus.Fields{"room": room, "moderator": username}).Warn("Room moderator removed")
+	if err := RefreshMetricRooms(c.Request.Context()); err != nil {
+		logging.FromGin(c).WithError(err).Warn("Error refreshing metric rooms")
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func loadRoomModerators(ctx context.Context, room string) ([]string, error) {
+	rows, err := config.PgConn.Query(ctx, "SELECT username FROM room_moderators WHERE room = $1 ORDER BY username", room)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moderators := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		moderators = append(moderators, username)
+	}
+	return moderators, rows.Err()
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.com/m/chat/config"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogLevelEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	current, level := config.Current, config.Logger.GetLevel()
+	t.Cleanup(func() {
+		config.Current = current
+		config.Logger.SetLevel(level)
+	})
+	config.Current = config.Default()
+	config.Current.Auth.AdminUsers = []string{"admin"}
+	config.Logger.SetLevel(logrus.InfoLevel)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("username", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	router.GET("/admin/log-level", RequireAdmin, GetLogLevel)
+	router.PUT("/admin/log-level", RequireAdmin, SetLogLevel)
+
+	request := func(method, user, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/admin/log-level", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", user)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodPut, "bob", `{"level":"debug"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, logrus.InfoLevel, config.Logger.GetLevel())
+
+	w = request(http.MethodPut, "admin", `{"level":"loud"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = request(http.MethodPut, "admin", `{"level":"debug"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, logrus.DebugLevel, config.Logger.GetLevel())
+
+	w = request(http.MethodGet, "admin", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"debug"}`, w.Body.String())
+}
+
+func TestRoomModeratorEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	current := config.Current
+	t.Cleanup(func() { config.Current = current })
+	config.Current = config.Default()
+	config.Current.Auth.AdminUsers = []string{"admin"}
+
+	const room, user = "moderator-test", "moderator-test-user"
+	_, err := config.PgConn.Exec(config.Ctx, "INSERT INTO users (username, password) VALUES ($1, 'x') ON CONFLICT (username) DO NOTHING", user)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		config.PgConn.Exec(config.Ctx, "DELETE FROM room_moderators WHERE room = $1", room)
+		config.PgConn.Exec(config.Ctx, "DELETE FROM users WHERE username = $1", user)
+	})
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("username", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	router.GET("/admin/rooms/:room/moderators", RequireAdmin, ListRoomModerators)
+	router.PUT("/admin/rooms/:room/moderators/:username", RequireAdmin, AddRoomModerator)
+	router.DELETE("/admin/rooms/:room/moderators/:username", RequireAdmin, RemoveRoomModerator)
+
+	request := func(method, path, user string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Test-User", user)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	moderatorPath := "/admin/rooms/" + room + "/moderators/" + user
+	mute := &CommandContext{Name: "mute", Room: room, Username: user, Args: []string{"nobody"}}
+
+	// 只有管理员可以授予权限，未授权前不能使用 /mute
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPut, moderatorPath, user).Code)
+	assert.ErrorIs(t, requireModerator(mute), ErrNotModerator)
+
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPut, "/admin/rooms/"+room+"/moderators/no-such-user", "admin").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodPut, moderatorPath, "admin").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodPut, moderatorPath, "admin").Code)
+	assert.NoError(t, requireModerator(mute))
+
+	w := request(http.MethodGet, "/admin/rooms/"+room+"/moderators", "admin")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"room":"`+room+`","moderators":["`+user+`"]}`, w.Body.String())
+
+	// 其他房间不受影响，auth.adminUsers 中的用户是所有房间的管理员
+	assert.ErrorIs(t, requireModerator(&CommandContext{Room: "other-room", Username: user}), ErrNotModerator)
+	assert.NoError(t, requireModerator(&CommandContext{Room: "other-room", Username: "admin"}))
+
+	assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, moderatorPath, "admin").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodDelete, moderatorPath, "admin").Code)
+	assert.ErrorIs(t, requireModerator(mute), ErrNotModerator)
+}
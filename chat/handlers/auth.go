@@ -6,8 +6,6 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +13,8 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
+	"example.com/m/chat/metrics"
 	"example.com/m/chat/middlewares"
 	"example.com/m/chat/utils"
 )
@@ -71,21 +71,19 @@ func unpad(src []byte) []byte {
 func RegisterUser(c *gin.Context) {
 	var requestData map[string]string
 	if err := c.ShouldBindJSON(&requestData); err != nil {
-		config.Logger.WithFields(logrus.Fields{
+		logging.FromGin(c).WithFields(logrus.Fields{
 			"error": err.Error(),
 		}).Error("Invalid input")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	fmt.Println(requestData["EncryptedData"])
-
 	// Decrypt the encrypted data
-	secretKey := "your-secret-key1"
+	secretKey := config.Current.Auth.RegistrationKey
 
 	decryptedData, err := decryptData(requestData["EncryptedData"], requestData["IV"], secretKey)
 	if err != nil {
-		config.Logger.WithFields(logrus.Fields{
+		logging.FromGin(c).WithFields(logrus.Fields{
 			"error": err.Error(),
 		}).Error("Decryption failed")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Decryption failed"})
@@ -96,7 +94,7 @@ func RegisterUser(c *gin.Context) {
 	var user User
 	err = json.Unmarshal(decryptedData, &user)
 	if err != nil {
-		config.Logger.WithFields(logrus.Fields{
+		logging.FromGin(c).WithFields(logrus.Fields{
 			"error": err.Error(),
 		}).Error("Failed to parse user data")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
@@ -107,25 +105,25 @@ func RegisterUser(c *gin.Context) {
 	var count int
 	err = config.PgConn.QueryRow(config.Ctx, "SELECT COUNT(*) FROM users WHERE username = $1", user.Username).Scan(&count)
 	if err != nil {
-		config.Logger.WithField("username", user.Username).Error("Error checking username")
+		logging.FromGin(c).WithField("username", user.Username).Error("Error checking username")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking username"})
 		return
 	}
 	if count > 0 {
-		config.Logger.WithField("username", user.Username).Error("Username already exists")
+		logging.FromGin(c).WithField("username", user.Username).Error("Username already exists")
 		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
 		return
 	}
 
 	// Log successful registration
-	config.Logger.WithFields(logrus.Fields{
+	logging.FromGin(c).WithFields(logrus.Fields{
 		"username": user.Username,
 	}).Info("User registered successfully")
 
 	// Hash the password
 	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		config.Logger.Error("Error hashing password")
+		logging.FromGin(c).Error("Error hashing password")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
 		return
 	}
@@ -133,13 +131,13 @@ func RegisterUser(c *gin.Context) {
 	// Insert the new user into the database
 	_, err = config.PgConn.Exec(config.Ctx, "INSERT INTO users (username, password, phone, email) VALUES ($1, $2, $3, $4)", user.Username, hash, user.Phone, user.Email)
 	if err != nil {
-		config.Logger.WithField("username", user.Username).Error("Error registering user")
+		logging.FromGin(c).WithField("username", user.Username).Error("Error registering user")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error registering user"})
 		return
 	}
 
-	config.Logger.WithField("username", user.Username).Info("User registered successfully")
-	config.RegisterUserCounter.WithLabelValues("success").Inc()
+	logging.FromGin(c).WithField("username", user.Username).Info("User registered successfully")
+	metrics.RegisterUserCounter.WithLabelValues("success").Inc()
 	c.JSON(http.StatusOK, gin.H{"status": "User registered"})
 }
 
@@ -147,7 +145,7 @@ func RegisterUser(c *gin.Context) {
 func LoginUser(c *gin.Context) {
 	var user User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		config.Logger.WithField("error", err.Error()).Error("Login failed")
+		logging.FromGin(c).WithField("error", err.Error()).Error("Login failed")
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -155,22 +153,22 @@ func LoginUser(c *gin.Context) {
 	var storedHash string
 	err := config.PgConn.QueryRow(config.Ctx, "SELECT password FROM users WHERE username=$1", user.Username).Scan(&storedHash)
 	if err != nil {
-		config.Logger.Error("Invalid username or password")
-		config.LoginCounter.WithLabelValues("failure").Inc()
+		logging.FromGin(c).Error("Invalid username or password")
+		metrics.LoginCounter.WithLabelValues("failure").Inc()
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
 	}
 
 	_, err = config.PgConn.Exec(config.Ctx, "UPDATE users SET time = NOW() WHERE username = $1", user.Username)
 	if err != nil {
-		config.Logger.WithField("error", err.Error()).Error("Failed to update time")
+		logging.FromGin(c).WithField("error", err.Error()).Error("Failed to update time")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update login time"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(user.Password)); err != nil {
-		config.Logger.Error("Invalid username or password")
-		config.LoginCounter.WithLabelValues("failure").Inc()
+		logging.FromGin(c).Error("Invalid username or password")
+		metrics.LoginCounter.WithLabelValues("failure").Inc()
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
 	}
@@ -178,12 +176,12 @@ func LoginUser(c *gin.Context) {
 	// 生成 JWT token
 	token, err := middlewares.GenerateJWT(user.Username)
 	if err != nil {
-		config.Logger.Error("Error generating token")
+		logging.FromGin(c).Error("Error generating token")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
 		return
 	}
 
-	config.LoginCounter.WithLabelValues("success").Inc()
+	metrics.LoginCounter.WithLabelValues("success").Inc()
 	c.JSON(http.StatusOK, gin.H{"token": token}) // 返回 token 给前端
 }
 
@@ -206,7 +204,7 @@ func LogoutUser(c *gin.Context) {
 
 	// 更新用户在线状态到 Redis
 	if err := utils.UpdateUserOnlineStatus(config.RedisClient, config.Ctx, username, false); err != nil {
-		log.Println("Error updating online status in Redis:", err)
+		logging.FromGin(c).WithError(err).Error("Error updating online status in Redis")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update status"})
 		return
 	}
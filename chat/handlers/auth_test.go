@@ -14,16 +14,13 @@ import (
 
 	"example.com/m/chat/config"
 	"example.com/m/chat/handlers"
-	"example.com/m/chat/metrics"
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
 // 初始化測試環境
 func init() {
 	config.Init()
-	metrics.InitMetrics()
 }
 
 // Encrypt function
@@ -115,12 +112,6 @@ func TestRegisterUser(t *testing.T) {
 }
 
 func TestRegisterUserDatabaseError(t *testing.T) {
-	// 每次測試前清空已註冊的指標
-	prometheus.Unregister(metrics.RegisterUserCounter)
-	prometheus.Unregister(metrics.LoginCounter)
-
-	// 重新初始化 Prometheus 指標
-	metrics.InitMetrics()
 	// 設置 gin 引擎
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
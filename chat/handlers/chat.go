@@ -1,11 +1,11 @@
 package handlers
 
 import (
-	"log"
 	"net/http"
 	"time"
 
 	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
 	"github.com/gin-gonic/gin"
 )
 
@@ -77,7 +77,7 @@ func GetLatestChatDate(c *gin.Context) {
 	// 查询数据库中最早的聊天记录日期
 	err := config.PgConn.QueryRow(config.Ctx, "SELECT MIN(time) FROM chat_messages WHERE room = $1", room).Scan(&earliestDate)
 	if err != nil {
-		config.Logger.Error("Error fetching earliest chat date:", err)
+		logging.FromGin(c).WithError(err).Error("Error fetching earliest chat date")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching earliest chat date"})
 		return
 	}
@@ -104,7 +104,7 @@ func GetLatestChatDate(c *gin.Context) {
 			ORDER BY time ASC
 		`, currentDate.Format("2006-01-02"), room)
 		if err != nil {
-			config.Logger.Error("Error fetching chat messages for date:", err)
+			logging.FromGin(c).WithError(err).Error("Error fetching chat messages for date")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching chat messages"})
 			return
 		}
@@ -115,7 +115,7 @@ func GetLatestChatDate(c *gin.Context) {
 		for rows.Next() {
 			var message config.ChatMessage
 			if err := rows.Scan(&message.ID, &message.Room, &message.Sender, &message.Content, &message.Time); err != nil { // 根据你的结构体字段调整
-				config.Logger.Error("Error scanning message:", err)
+				logging.FromGin(c).WithError(err).Error("Error scanning message")
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning message"})
 				return
 			}
@@ -164,7 +164,7 @@ func GetOnlineUsers(c *gin.Context) {
 	for _, key := range keys {
 		value, err := config.RedisClient.Get(config.Ctx, key).Result() // 獲取鍵的值
 		if err != nil {
-			log.Printf("Error fetching value for key %s: %v", key, err)
+			logging.FromGin(c).WithError(err).WithField("key", key).Error("Error fetching online status")
 			continue
 		}
 		if value == "online" { // 檢查值是否為 "online"
@@ -172,6 +172,6 @@ func GetOnlineUsers(c *gin.Context) {
 		}
 	}
 
-	log.Printf("Current online users: %v", onlineUsers)
+	logging.FromGin(c).WithField("onlineUsers", onlineUsers).Debug("Current online users")
 	c.JSON(http.StatusOK, gin.H{"onlineUsers": onlineUsers})
 }
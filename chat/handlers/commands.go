@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+)
+
+// SystemSender 是系统消息使用的发送者名称
+const SystemSender = "system"
+
+// 默认禁言时长
+const defaultMuteDuration = 10 * time.Minute
+
+// 每个用户每分钟最多发出的邀请数
+const maxInvitesPerMinute = 10
+
+var (
+	ErrCommandExists    = errors.New("command already registered")
+	ErrNotModerator     = errors.New("only room moderators can use this command")
+	ErrMissingCommand   = errors.New("command name is required")
+	ErrTooManyInvites   = errors.New("too many invitations, try again in a minute")
+	ErrMuted            = errors.New("you are muted in this room")
+	ErrMutesUnavailable = errors.New("mute status is unavailable, try again later")
+)
+
+// CommandContext 是执行斜杠命令时的上下文
+type CommandContext struct {
+	Context  context.Context // 携带连接日志字段的 context，可能为 nil
+	Conn     *websocket.Conn // 调用者的连接，可能为 nil
+	Username string          // 已认证的调用者
+	Room     string          // 命令所在的房间
+	Name     string          // 命令名称（不含 "/"）
+	Args     []string        // 以空白分隔的参数
+	Raw      string          // 命令名之后的原始文本
+	Time     time.Time       // 命令发送时间
+}
+
+// CommandResult 是命令执行的结果，各字段均为可选
+type CommandResult struct {
+	Message string // 以调用者身份发送到房间的消息
+	System  string // 以系统身份发送到房间的消息
+	Private string // 只回复给调用者的消息
+}
+
+// Command 描述一个斜杠命令
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	// Authorize 在执行前检查调用者权限，返回错误时拒绝执行
+	Authorize func(ctx *CommandContext) error
+	Execute   func(ctx *CommandContext) (*CommandResult, error)
+}
+
+// CommandRegistry 保存所有已注册的斜杠命令
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	commands map[string]Command
+}
+
+// NewCommandRegistry 创建空的命令注册表
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{commands: make(map[string]Command)}
+}
+
+// Register 注册命令，命令名不区分大小写
+func (r *CommandRegistry) Register(cmd Command) error {
+	name := strings.ToLower(strings.TrimPrefix(cmd.Name, "/"))
+	if name == "" {
+		return ErrMissingCommand
+	}
+	if cmd.Execute == nil {
+		return fmt.Errorf("command /%s has no Execute function", name)
+	}
+	cmd.Name = name
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.commands[name]; exists {
+		return fmt.Errorf("/%s: %w", name, ErrCommandExists)
+	}
+	r.commands[name] = cmd
+	return nil
+}
+
+// Lookup 按名称查找命令
+func (r *CommandRegistry) Lookup(name string) (Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.commands[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// Commands 返回按名称排序的所有命令
+func (r *CommandRegistry) Commands() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	commands := make([]Command, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		commands = append(commands, cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// Execute 检查权限并执行命令
+func (r *CommandRegistry) Execute(ctx *CommandContext) (*CommandResult, error) {
+	cmd, ok := r.Lookup(ctx.Name)
+	if !ok {
+		return nil, fmt.Errorf("unknown command /%s, try /help", ctx.Name)
+	}
+	if cmd.Authorize != nil {
+		if err := cmd.Authorize(ctx); err != nil {
+			return nil, err
+		}
+	}
+	result, err := cmd.Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = &CommandResult{}
+	}
+	return result, nil
+}
+
+// Commands 是聊天服务器使用的默认注册表
+var Commands = NewCommandRegistry()
+
+// RegisterCommand 向默认注册表添加自定义命令
+func RegisterCommand(cmd Command) error {
+	return Commands.Register(cmd)
+}
+
+func init() {
+	for _, cmd := range []Command{
+		{Name: "help", Usage: "/help", Description: "List available commands", Execute: helpCommand},
+		{Name: "me", Usage: "/me <action>", Description: "Send an action message", Authorize: requireNotMuted, Execute: meCommand},
+		{Name: "topic", Usage: "/topic [text]", Description: "Show or set the room topic", Authorize: requireTopicPermission, Execute: topicCommand},
+		{Name: "invite", Usage: "/invite <username>", Description: "Invite a user to the room", Authorize: requireNotMuted, Execute: inviteCommand},
+		{Name: "mute", Usage: "/mute <username> [minutes]", Description: "Mute a user in the room", Authorize: requireModerator, Execute: muteCommand},
+		{Name: "unmute", Usage: "/unmute <username>", Description: "Unmute a user in the room", Authorize: requireModerator, Execute: unmuteCommand},
+		{Name: "who", Usage: "/who", Description: "List online users", Execute: whoCommand},
+	} {
+		if err := RegisterCommand(cmd); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// context 返回命令的 context，未设置时返回 context.Background()
+func (ctx *CommandContext) context() context.Context {
+	if ctx.Context == nil {
+		return context.Background()
+	}
+	return ctx.Context
+}
+
+// 执行命令并处理结果
+func handleCommand(ctx *CommandContext) {
+	if ctx.Username == "" {
+		sendCommandResponse(ctx, "please authenticate before using commands", true)
+		return
+	}
+
+	result, err := Commands.Execute(ctx)
+	if err != nil {
+		sendCommandResponse(ctx, err.Error(), true)
+		return
+	}
+
+	if result.Message != "" {
+		message := config.ChatMessage{Room: ctx.Room, Sender: ctx.Username, Content: result.Message, Time: ctx.Time}
+		if _, err := processChatMessage(ctx.context(), message); err != nil {
+			logging.FromContext(ctx.context()).WithError(err).Error("Error saving command message")
+		}
+	}
+	if result.System != "" {
+		message := config.ChatMessage{Room: ctx.Room, Sender: SystemSender, Content: result.System, Time: time.Now()}
+		if _, err := processChatMessage(ctx.context(), message); err != nil {
+			logging.FromContext(ctx.context()).WithError(err).Error("Error saving system message")
+		}
+	}
+	if result.Private != "" {
+		sendCommandResponse(ctx, result.Private, false)
+	}
+}
+
+func sendCommandResponse(ctx *CommandContext, content string, isError bool) {
+	if ctx.Conn == nil {
+		return
+	}
+	err := writeJSON(ctx.Conn, gin.H{
+		"type":    "commandResponse",
+		"command": ctx.Name,
+		"room":    ctx.Room,
+		"content": content,
+		"error":   isError,
+	})
+	if err != nil {
+		logging.FromContext(ctx.context()).WithError(err).Error("Error sending command response")
+	}
+}
+
+// 检查用户是否为房间管理员，auth.adminUsers 中的用户是所有房间的管理员
+func isRoomModerator(ctx context.Context, room, username string) (bool, error) {
+	if config.Current != nil && config.Current.Auth.IsAdmin(username) {
+		return true, nil
+	}
+	var exists bool
+	err := config.PgConn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM room_moderators WHERE room = $1 AND username = $2)", room, username).Scan(&exists)
+	return exists, err
+}
+
+func requireModerator(ctx *CommandContext) error {
+	ok, err := isRoomModerator(ctx.context(), ctx.Room, ctx.Username)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotModerator
+	}
+	return nil
+}
+
+func requireNotMuted(ctx *CommandContext) error {
+	muted, err := isMuted(ctx.context(), ctx.Room, ctx.Username)
+	if err != nil {
+		return err
+	}
+	if muted {
+		return ErrMuted
+	}
+	return nil
+}
+
+// 任何人都可以查看房间主题，设置主题需要未被禁言的房间管理员
+func requireTopicPermission(ctx *CommandContext) error {
+	if ctx.Raw == "" {
+		return nil
+	}
+	if err := requireNotMuted(ctx); err != nil {
+		return err
+	}
+	return requireModerator(ctx)
+}
+
+func muteKey(room, username string) string {
+	return "mute:" + room + ":" + username
+}
+
+// 检查用户是否在房间内被禁言
+// 禁言保存在 Redis 中，Redis 不可用时返回 ErrMutesUnavailable，调用方应拒绝消息而不是放行
+func isMuted(ctx context.Context, room, username string) (bool, error) {
+	if config.RedisClient == nil {
+		return false, ErrMutesUnavailable
+	}
+	exists, err := config.RedisClient.Exists(ctx, muteKey(room, username)).Result()
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Error checking mute status")
+		return false, ErrMutesUnavailable
+	}
+	return exists == 1, nil
+}
+
+func helpCommand(ctx *CommandContext) (*CommandResult, error) {
+	var lines []string
+	for _, cmd := range Commands.Commands() {
+		lines = append(lines, fmt.Sprintf("%s - %s", cmd.Usage, cmd.Description))
+	}
+	return &CommandResult{Private: strings.Join(lines, "\n")}, nil
+}
+
+func meCommand(ctx *CommandContext) (*CommandResult, error) {
+	if ctx.Raw == "" {
+		return nil, errors.New("usage: /me <action>")
+	}
+	return &CommandResult{Message: fmt.Sprintf("* %s %s", ctx.Username, ctx.Raw)}, nil
+}
+
+func topicCommand(ctx *CommandContext) (*CommandResult, error) {
+	if ctx.Raw == "" {
+		var topic string
+		err := config.PgConn.QueryRow(ctx.context(), "SELECT topic FROM room_topics WHERE room = $1", ctx.Room).Scan(&topic)
+		if err != nil || topic == "" {
+			return &CommandResult{Private: "No topic is set for " + ctx.Room}, nil
+		}
+		return &CommandResult{Private: "Topic: " + topic}, nil
+	}
+
+	_, err := config.PgConn.Exec(ctx.context(), `
+		INSERT INTO room_topics (room, topic, set_by, time) VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (room) DO UPDATE SET topic = EXCLUDED.topic, set_by = EXCLUDED.set_by, time = EXCLUDED.time`,
+		ctx.Room, ctx.Raw, ctx.Username)
+	if err != nil {
+		return nil, fmt.Errorf("could not set topic: %w", err)
+	}
+	return &CommandResult{System: fmt.Sprintf("%s set the topic to: %s", ctx.Username, ctx.Raw)}, nil
+}
+
+func inviteCommand(ctx *CommandContext) (*CommandResult, error) {
+	if len(ctx.Args) != 1 {
+		return nil, errors.New("usage: /invite <username>")
+	}
+	invitee := strings.TrimPrefix(ctx.Args[0], "@")
+
+	var exists bool
+	err := config.PgConn.QueryRow(ctx.context(), "SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)", invitee).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("user %s does not exist", invitee)
+	}
+	if err := limitInvites(ctx); err != nil {
+		return nil, err
+	}
+
+	// 邀请以通知的形式送达，离线用户上线后也能看到
+	invitation := config.ChatMessage{
+		Room:    ctx.Room,
+		Sender:  ctx.Username,
+		Content: fmt.Sprintf("%s invited you to %s", ctx.Username, ctx.Room),
+		Time:    ctx.Time,
+	}
+
+	// 被邀请者还没有读过的相同邀请不再重复发送
+	err = config.PgConn.QueryRow(ctx.context(),
+		"SELECT EXISTS (SELECT 1 FROM notifications WHERE username = $1 AND room = $2 AND sender = $3 AND content = $4 AND is_read = FALSE)",
+		invitee, invitation.Room, invitation.Sender, invitation.Content).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return &CommandResult{Private: fmt.Sprintf("%s has already been invited to %s", invitee, ctx.Room)}, nil
+	}
+	notification, err := saveNotificationToDB(ctx.context(), invitee, invitation)
+	if err != nil {
+		return nil, fmt.Errorf("could not invite %s: %w", invitee, err)
+	}
+	sendToUser(invitee, notificationPayload(notification))
+
+	return &CommandResult{Private: fmt.Sprintf("Invited %s to %s", invitee, ctx.Room)}, nil
+}
+
+// limitInvites 按调用者计数，每分钟最多 maxInvitesPerMinute 次邀请
+// 计数保存在 Redis 中，对所有实例生效；Redis 不可用时拒绝邀请
+func limitInvites(ctx *CommandContext) error {
+	if config.RedisClient == nil {
+		return errors.New("invitations are unavailable")
+	}
+	key := "invites:" + ctx.Username
+	count, err := config.RedisClient.Incr(ctx.context(), key).Result()
+	if err != nil {
+		return fmt.Errorf("could not check invitation limit: %w", err)
+	}
+	if count == 1 {
+		if err := config.RedisClient.Expire(ctx.context(), key, time.Minute).Err(); err != nil {
+			return fmt.Errorf("could not check invitation limit: %w", err)
+		}
+	}
+	if count > maxInvitesPerMinute {
+		return ErrTooManyInvites
+	}
+	return nil
+}
+
+func muteCommand(ctx *CommandContext) (*CommandResult, error) {
+	if len(ctx.Args) < 1 || len(ctx.Args) > 2 {
+		return nil, errors.New("usage: /mute <username> [minutes]")
+	}
+	target := strings.TrimPrefix(ctx.Args[0], "@")
+	duration := defaultMuteDuration
+	if len(ctx.Args) == 2 {
+		minutes, err := strconv.Atoi(ctx.Args[1])
+		if err != nil || minutes <= 0 {
+			return nil, errors.New("minutes must be a positive number")
+		}
+		duration = time.Duration(minutes) * time.Minute
+	}
+	if config.RedisClient == nil {
+		return nil, ErrMutesUnavailable
+	}
+
+	if err := config.RedisClient.Set(ctx.context(), muteKey(ctx.Room, target), ctx.Username, duration).Err(); err != nil {
+		return nil, fmt.Errorf("could not mute %s: %w", target, err)
+	}
+	return &CommandResult{System: fmt.Sprintf("%s was muted by %s for %s", target, ctx.Username, duration)}, nil
+}
+
+func unmuteCommand(ctx *CommandContext) (*CommandResult, error) {
+	if len(ctx.Args) != 1 {
+		return nil, errors.New("usage: /unmute <username>")
+	}
+	target := strings.TrimPrefix(ctx.Args[0], "@")
+	if config.RedisClient == nil {
+		return nil, ErrMutesUnavailable
+	}
+
+	err := config.RedisClient.Del(ctx.context(), muteKey(ctx.Room, target)).Err()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("could not unmute %s: %w", target, err)
+	}
+	return &CommandResult{System: fmt.Sprintf("%s was unmuted by %s", target, ctx.Username)}, nil
+}
+
+func whoCommand(ctx *CommandContext) (*CommandResult, error) {
+	seen := make(map[string]bool)
+	var users []string
+	for _, username := range snapshotClients() {
+		if username != "" && !seen[username] {
+			seen[username] = true
+			users = append(users, username)
+		}
+	}
+	sort.Strings(users)
+
+	if len(users) == 0 {
+		return &CommandResult{Private: "No users online"}, nil
+	}
+	return &CommandResult{Private: fmt.Sprintf("Online (%d): %s", len(users), strings.Join(users, ", "))}, nil
+}
@@ -0,0 +1,159 @@
+package handlers_test
+
+import (
+	"errors"
+	"testing"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandRegistry(t *testing.T) {
+	registry := handlers.NewCommandRegistry()
+
+	// 注册自定义命令
+	err := registry.Register(handlers.Command{
+		Name:  "/Echo",
+		Usage: "/echo <text>",
+		Execute: func(ctx *handlers.CommandContext) (*handlers.CommandResult, error) {
+			return &handlers.CommandResult{Private: ctx.Raw}, nil
+		},
+	})
+	assert.NoError(t, err)
+
+	// 重复注册應失敗
+	err = registry.Register(handlers.Command{
+		Name:    "echo",
+		Execute: func(ctx *handlers.CommandContext) (*handlers.CommandResult, error) { return nil, nil },
+	})
+	assert.ErrorIs(t, err, handlers.ErrCommandExists)
+
+	// 缺少 Execute 應失敗
+	assert.Error(t, registry.Register(handlers.Command{Name: "noop"}))
+
+	result, err := registry.Execute(&handlers.CommandContext{Name: "echo", Raw: "hi there"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi there", result.Private)
+
+	// 未知命令
+	_, err = registry.Execute(&handlers.CommandContext{Name: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestCommandRegistryAuthorize(t *testing.T) {
+	registry := handlers.NewCommandRegistry()
+	denied := errors.New("denied")
+	executed := false
+
+	err := registry.Register(handlers.Command{
+		Name: "secret",
+		Authorize: func(ctx *handlers.CommandContext) error {
+			if ctx.Username != "admin" {
+				return denied
+			}
+			return nil
+		},
+		Execute: func(ctx *handlers.CommandContext) (*handlers.CommandResult, error) {
+			executed = true
+			return nil, nil
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = registry.Execute(&handlers.CommandContext{Name: "secret", Username: "bob"})
+	assert.ErrorIs(t, err, denied)
+	assert.False(t, executed)
+
+	result, err := registry.Execute(&handlers.CommandContext{Name: "secret", Username: "admin"})
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.True(t, executed)
+}
+
+func TestBuiltinCommands(t *testing.T) {
+	for _, name := range []string{"help", "me", "topic", "invite", "mute", "who"} {
+		_, ok := handlers.Commands.Lookup(name)
+		assert.True(t, ok, name)
+	}
+
+	result, err := handlers.Commands.Execute(&handlers.CommandContext{Name: "who", Username: "testuser"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.Private)
+}
+
+func TestTopicAndInvitePermissions(t *testing.T) {
+	current := config.Current
+	t.Cleanup(func() { config.Current = current })
+	config.Current = config.Default()
+	config.Current.Auth.AdminUsers = []string{"admin"}
+
+	const room, user, invitee = "command-test", "command-test-user", "command-test-invitee"
+	for _, username := range []string{user, invitee} {
+		_, err := config.PgConn.Exec(config.Ctx, "INSERT INTO users (username, password) VALUES ($1, 'x') ON CONFLICT (username) DO NOTHING", username)
+		require.NoError(t, err)
+	}
+	config.RedisClient.Del(config.Ctx, "invites:"+user)
+	t.Cleanup(func() {
+		config.RedisClient.Del(config.Ctx, "mute:"+room+":"+user, "invites:"+user)
+		config.PgConn.Exec(config.Ctx, "DELETE FROM notifications WHERE username = $1", invitee)
+		config.PgConn.Exec(config.Ctx, "DELETE FROM users WHERE username = ANY($1)", []string{user, invitee})
+	})
+	run := func(username, name string, args ...string) (*handlers.CommandResult, error) {
+		raw := ""
+		if len(args) > 0 {
+			raw = args[0]
+		}
+		return handlers.Commands.Execute(&handlers.CommandContext{Name: name, Room: room, Username: username, Args: args, Raw: raw})
+	}
+
+	// 任何人都可以查看主题，只有房间管理员可以设置主题
+	_, err := run(user, "topic")
+	assert.NoError(t, err)
+	_, err = run(user, "topic", "spam")
+	assert.ErrorIs(t, err, handlers.ErrNotModerator)
+
+	// 重复的邀请只保存一次通知
+	for i := 0; i < 2; i++ {
+		_, err = run(user, "invite", invitee)
+		require.NoError(t, err)
+	}
+	var count int
+	require.NoError(t, config.PgConn.QueryRow(config.Ctx, "SELECT COUNT(*) FROM notifications WHERE username = $1 AND room = $2", invitee, room).Scan(&count))
+	assert.Equal(t, 1, count)
+
+	// 每分钟的邀请次数有上限
+	for i := 2; i < 10; i++ {
+		_, err = run(user, "invite", invitee)
+		require.NoError(t, err)
+	}
+	_, err = run(user, "invite", invitee)
+	assert.ErrorIs(t, err, handlers.ErrTooManyInvites)
+
+	// 被禁言的用户不能设置主题，也不能邀请
+	_, err = run("admin", "mute", user)
+	require.NoError(t, err)
+	_, err = run(user, "topic", "spam")
+	assert.ErrorIs(t, err, handlers.ErrMuted)
+	_, err = run(user, "invite", invitee)
+	assert.ErrorIs(t, err, handlers.ErrMuted)
+}
+
+// Redis 不可用时禁言不能被绕过，也不能设置或解除禁言
+func TestMutesWithoutRedis(t *testing.T) {
+	current, client := config.Current, config.RedisClient
+	t.Cleanup(func() { config.Current, config.RedisClient = current, client })
+	config.Current = config.Default()
+	config.Current.Auth.AdminUsers = []string{"admin"}
+	config.RedisClient = nil
+
+	for _, ctx := range []*handlers.CommandContext{
+		{Name: "me", Room: "general", Username: "testuser", Args: []string{"waves"}, Raw: "waves"},
+		{Name: "mute", Room: "general", Username: "admin", Args: []string{"testuser"}, Raw: "testuser"},
+		{Name: "unmute", Room: "general", Username: "admin", Args: []string{"testuser"}, Raw: "testuser"},
+	} {
+		_, err := handlers.Commands.Execute(ctx)
+		assert.ErrorIs(t, err, handlers.ErrMutesUnavailable, ctx.Name)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"example.com/m/chat/archive"
+	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// 每写入多少条消息刷新一次响应
+const exportFlushEvery = 500
+
+// 以 JSON Lines 或 CSV 流式导出房间的完整历史，仅房间管理员可用
+// 格式通过 ?format=jsonl|csv 指定，默认为 jsonl
+func ExportRoom(c *gin.Context) {
+	room := c.Param("room")
+	format, err := archive.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !requireRoomModerator(c, room) {
+		return
+	}
+	ctx := c.Request.Context()
+
+	// 先查询成员，出错时还能返回 JSON 错误
+	members, err := config.PgConn.Query(ctx, "SELECT sender, MIN(time) FROM chat_messages WHERE room = $1 GROUP BY sender ORDER BY MIN(time)", room)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error fetching room members")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching room members"})
+		return
+	}
+	defer members.Close()
+
+	filename := fmt.Sprintf("%s-%s.%s", room, time.Now().Format("20060102"), format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w, _ := archive.NewWriter(c.Writer, format)
+	if err := w.Write(archive.Record{Kind: archive.KindRoom, Room: room, Time: time.Now()}); err != nil {
+		logging.FromGin(c).WithError(err).Error("Error writing export")
+		return
+	}
+
+	for members.Next() {
+		var member archive.Record
+		if err := members.Scan(&member.Sender, &member.Time); err != nil {
+			logging.FromGin(c).WithError(err).Error("Error scanning room member")
+			return
+		}
+		member.Kind, member.Room = archive.KindMember, room
+		if err := w.Write(member); err != nil {
+			logging.FromGin(c).WithError(err).Error("Error writing export")
+			return
+		}
+	}
+	members.Close()
+
+	rows, err := config.PgConn.Query(ctx, "SELECT id, sender, content, time FROM chat_messages WHERE room = $1 ORDER BY time ASC, id ASC", room)
+	if err != nil {
+		// 响应头已发送，只能记录错误并中断
+		logging.FromGin(c).WithError(err).Error("Error fetching messages for export")
+		return
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var message archive.Record
+		if err := rows.Scan(&message.ID, &message.Sender, &message.Content, &message.Time); err != nil {
+			logging.FromGin(c).WithError(err).Error("Error scanning message for export")
+			return
+		}
+		message.Kind, message.Room = archive.KindMessage, room
+		if err := w.Write(message); err != nil {
+			logging.FromGin(c).WithError(err).Error("Error writing export")
+			return
+		}
+
+		count++
+		if count%exportFlushEvery == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		logging.FromGin(c).WithError(err).Error("Error flushing export")
+	}
+}
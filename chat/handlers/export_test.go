@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/m/chat/archive"
+	"example.com/m/chat/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportRoom(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	current := config.Current
+	t.Cleanup(func() { config.Current = current })
+	config.Current = config.Default()
+	config.Current.Auth.AdminUsers = []string{"admin"}
+
+	const room = "export-test"
+	_, err := config.PgConn.Exec(config.Ctx, "INSERT INTO chat_messages (room, sender, content, time) VALUES ($1, 'alice', 'hello', $2)", room, time.Now())
+	require.NoError(t, err)
+	t.Cleanup(func() { config.PgConn.Exec(config.Ctx, "DELETE FROM chat_messages WHERE room = $1", room) })
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("username", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	router.GET("/rooms/:room/export", ExportRoom)
+	request := func(user string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/rooms/"+room+"/export", nil)
+		req.Header.Set("X-Test-User", user)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 房间成员不能导出历史，只有管理员可以
+	assert.Equal(t, http.StatusForbidden, request("alice").Code)
+
+	w := request("admin")
+	require.Equal(t, http.StatusOK, w.Code)
+	var kinds []string
+	for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		var record archive.Record
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		kinds = append(kinds, record.Kind)
+	}
+	assert.Equal(t, []string{archive.KindRoom, archive.KindMember, archive.KindMessage}, kinds)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
+	"example.com/m/chat/metrics"
+	"example.com/m/chat/telemetry"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FanoutChannel 是多个聊天服务器实例之间转发消息的 Redis 频道
+const FanoutChannel = "chat:fanout"
+
+// instanceID 标识当前实例，用于忽略自己发布的消息
+var instanceID = uuid.NewString()
+
+// fanoutEnvelope 是发布到 Redis 的消息，Trace 携带发布方的追踪上下文
+type fanoutEnvelope struct {
+	Origin  string             `json:"origin"`
+	Trace   map[string]string  `json:"trace,omitempty"`
+	Message config.ChatMessage `json:"message"`
+}
+
+// publishFanout 将消息发布给其他实例，失败只记录日志，本实例的推送不受影响
+func publishFanout(ctx context.Context, message config.ChatMessage) {
+	if config.RedisClient == nil {
+		return
+	}
+
+	payload, err := json.Marshal(fanoutEnvelope{
+		Origin:  instanceID,
+		Trace:   telemetry.Inject(ctx),
+		Message: message,
+	})
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Error encoding fan-out message")
+		return
+	}
+
+	if err := config.RedisClient.Publish(ctx, FanoutChannel, payload).Err(); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Error publishing fan-out message")
+		metrics.MessageDroppedCounter.WithLabelValues("fanout_publish").Inc()
+	}
+}
+
+// RunFanout 订阅 FanoutChannel，将其他实例发布的消息推送给本实例的连接，ctx 取消后返回
+func RunFanout(ctx context.Context) {
+	pubsub := config.RedisClient.Subscribe(ctx, FanoutChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			handleFanout(ctx, msg.Payload)
+		}
+	}
+}
+
+func handleFanout(ctx context.Context, payload string) {
+	var envelope fanoutEnvelope
+	if err := json.Unmarshal([]byte(payload), &envelope); err != nil {
+		config.Logger.WithError(err).Warn("Ignoring malformed fan-out message")
+		metrics.MessageDroppedCounter.WithLabelValues("fanout_decode").Inc()
+		return
+	}
+	if envelope.Origin == instanceID {
+		return
+	}
+
+	ctx = telemetry.Extract(ctx, envelope.Trace)
+	_, span := telemetry.Tracer().Start(ctx, "chat.fanout receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination", FanoutChannel),
+			attribute.String("chat.room", envelope.Message.Room),
+			attribute.Int("chat.message_id", envelope.Message.ID),
+		),
+	)
+	defer span.End()
+
+	BroadcastMessageToRoom(envelope.Message.Room, envelope.Message)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthCheckTimeout 是每个依赖检查的超时时间
+var HealthCheckTimeout = 2 * time.Second
+
+// 存活检查：只检查进程内的消息中心，依赖故障时不应重启进程
+func Livez(c *gin.Context) {
+	report := utils.RunHealthChecks(c.Request.Context(), HealthCheckTimeout, map[string]utils.HealthCheck{
+		"hub": checkHub,
+	})
+	respondHealth(c, report, report.Healthy(), gin.H{})
+}
+
+// 就绪检查：检查 PostgreSQL、Redis 与消息中心，关闭过程中始终返回未就绪
+func Readyz(c *gin.Context) {
+	report := utils.RunHealthChecks(c.Request.Context(), HealthCheckTimeout, map[string]utils.HealthCheck{
+		"postgres": checkPostgres,
+		"redis":    checkRedis,
+		"hub":      checkHub,
+	})
+	shuttingDown := ShuttingDown()
+	respondHealth(c, report, report.Healthy() && !shuttingDown, gin.H{"shuttingDown": shuttingDown})
+}
+
+func respondHealth(c *gin.Context, report utils.HealthReport, ok bool, body gin.H) {
+	status := http.StatusOK
+	body["status"] = utils.HealthStatusOK
+	if !ok {
+		status = http.StatusServiceUnavailable
+		body["status"] = utils.HealthStatusFail
+	}
+	body["checks"] = report.Checks
+	c.JSON(status, body)
+}
+
+func checkPostgres(ctx context.Context) error {
+	if config.PgConn == nil {
+		return errors.New("not connected")
+	}
+	return config.PgConn.Ping(ctx)
+}
+
+func checkRedis(ctx context.Context) error {
+	if config.RedisClient == nil {
+		return errors.New("not connected")
+	}
+	return config.RedisClient.Ping(ctx).Err()
+}
+
+// checkHub 确认连接列表的锁没有被长时间占用，广播卡住时检查会超时
+func checkHub(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		config.Mu.Lock()
+		config.Mu.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("client registry is locked")
+	}
+}
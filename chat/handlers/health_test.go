@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"example.com/m/chat/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type healthResponse struct {
+	Status       string `json:"status"`
+	ShuttingDown bool   `json:"shuttingDown"`
+	Checks       map[string]struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"checks"`
+}
+
+func getHealth(t *testing.T, path string) (int, healthResponse) {
+	router := gin.New()
+	router.GET("/livez", Livez)
+	router.GET("/readyz", Readyz)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var body healthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("could not decode %s response: %v", path, err)
+	}
+	return w.Code, body
+}
+
+func TestLivez(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	code, body := getHealth(t, "/livez")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body.Checks["hub"].Status)
+
+	// 連接列表的鎖被長時間佔用時存活檢查失敗
+	timeout := HealthCheckTimeout
+	HealthCheckTimeout = 50 * time.Millisecond
+	defer func() { HealthCheckTimeout = timeout }()
+
+	config.Mu.Lock()
+	code, body = getHealth(t, "/livez")
+	config.Mu.Unlock()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "fail", body.Checks["hub"].Status)
+}
+
+func TestReadyzDuringShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Cleanup(resetShutdown)
+
+	shutdownMu.Lock()
+	shuttingDown = true
+	shutdownMu.Unlock()
+
+	code, body := getHealth(t, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "fail", body.Status)
+	assert.True(t, body.ShuttingDown)
+	assert.Contains(t, body.Checks, "postgres")
+	assert.Contains(t, body.Checks, "redis")
+	assert.Equal(t, "ok", body.Checks["hub"].Status)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/m/chat/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// @room 通知房间成员表中的注册用户，不包括发送者、机器人与其他房间的用户
+func TestResolveRoomMention(t *testing.T) {
+	ctx := context.Background()
+	const room = "mention-test"
+	users := []string{"mention-alice", "mention-bob", "mention-carol"}
+	for _, username := range users {
+		_, err := config.PgConn.Exec(ctx, "INSERT INTO users (username, password) VALUES ($1, 'x') ON CONFLICT (username) DO NOTHING", username)
+		require.NoError(t, err)
+	}
+	t.Cleanup(func() {
+		config.PgConn.Exec(ctx, "DELETE FROM chat_messages WHERE room = ANY($1)", []string{room, room + "-other"})
+		config.PgConn.Exec(ctx, "DELETE FROM room_members WHERE room = ANY($1)", []string{room, room + "-other"})
+		config.PgConn.Exec(ctx, "DELETE FROM users WHERE username = ANY($1)", users)
+	})
+
+	for _, m := range []config.ChatMessage{
+		{Room: room, Sender: "mention-alice", Content: "hi"},
+		{Room: room, Sender: "mention-bob", Content: "hello"},
+		{Room: room, Sender: "mention-bob", Content: "again"},
+		{Room: room, Sender: "ci-bot", Content: "build passed"},
+		{Room: room + "-other", Sender: "mention-carol", Content: "elsewhere"},
+	} {
+		m.Time = time.Now()
+		_, err := saveMessageToDB(ctx, m)
+		require.NoError(t, err)
+	}
+
+	// 新消息由触发器加入 room_members
+	var members []string
+	rows, err := config.PgConn.Query(ctx, "SELECT username FROM room_members WHERE room = $1 ORDER BY username", room)
+	require.NoError(t, err)
+	for rows.Next() {
+		var username string
+		require.NoError(t, rows.Scan(&username))
+		members = append(members, username)
+	}
+	rows.Close()
+	assert.Equal(t, []string{"ci-bot", "mention-alice", "mention-bob"}, members)
+
+	targets, err := resolveMentionTargets(ctx, config.ChatMessage{Room: room, Sender: "mention-alice", Content: "@room standup"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mention-bob"}, targets)
+}
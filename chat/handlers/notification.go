@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
+	"example.com/m/chat/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+type markReadRequest struct {
+	IDs []int `json:"ids"` // 為空時標記全部為已讀
+}
+
+// 处理消息中的 @username / @room 提及，保存通知并推送给在线用户
+func notifyMentions(ctx context.Context, message config.ChatMessage) {
+	targets, err := resolveMentionTargets(ctx, message)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Error resolving mention targets")
+		return
+	}
+
+	for _, username := range targets {
+		notification, err := saveNotificationToDB(ctx, username, message)
+		if err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("mentioned", username).Error("Error saving notification to DB")
+			continue
+		}
+		sendToUser(username, notificationPayload(notification))
+	}
+}
+
+// 将提及解析为需要通知的用户列表，不包含发送者本人
+func resolveMentionTargets(ctx context.Context, message config.ChatMessage) ([]string, error) {
+	usernames, room := utils.ParseMentions(message.Content)
+	if len(usernames) == 0 && !room {
+		return nil, nil
+	}
+
+	var targets []string
+	seen := map[string]bool{message.Sender: true}
+	add := func(username string) {
+		if !seen[username] {
+			seen[username] = true
+			targets = append(targets, username)
+		}
+	}
+
+	// 只通知真实存在的用户
+	if len(usernames) > 0 {
+		rows, err := config.PgConn.Query(ctx, "SELECT username FROM users WHERE username = ANY($1)", usernames)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var username string
+			if err := rows.Scan(&username); err != nil {
+				return nil, err
+			}
+			add(username)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// @room 通知所有在该房间发过言的注册用户，成员由 room_members 维护，不扫描房间的历史消息
+	if room {
+		rows, err := config.PgConn.Query(ctx,
+			"SELECT m.username FROM room_members m JOIN users u ON u.username = m.username WHERE m.room = $1", message.Room)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var username string
+			if err := rows.Scan(&username); err != nil {
+				return nil, err
+			}
+			add(username)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return targets, nil
+}
+
+func saveNotificationToDB(ctx context.Context, username string, message config.ChatMessage) (config.Notification, error) {
+	notification := config.Notification{
+		Username:  username,
+		Room:      message.Room,
+		Sender:    message.Sender,
+		MessageID: message.ID,
+		Content:   message.Content,
+	}
+	err := config.PgConn.QueryRow(ctx,
+		"INSERT INTO notifications (username, room, sender, message_id, content) VALUES ($1, $2, $3, $4, $5) RETURNING id, time",
+		username, message.Room, message.Sender, message.ID, message.Content).Scan(&notification.ID, &notification.Time)
+	return notification, err
+}
+
+func loadUnreadNotifications(ctx context.Context, username string) ([]config.Notification, error) {
+	return queryNotifications(ctx, username, true)
+}
+
+func queryNotifications(ctx context.Context, username string, unreadOnly bool) ([]config.Notification, error) {
+	query := "SELECT id, username, room, sender, message_id, content, is_read, time FROM notifications WHERE username = $1"
+	if unreadOnly {
+		query += " AND is_read = FALSE"
+	}
+	query += " ORDER BY time DESC LIMIT 100"
+
+	rows, err := config.PgConn.Query(ctx, query, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []config.Notification{}
+	for rows.Next() {
+		var n config.Notification
+		if err := rows.Scan(&n.ID, &n.Username, &n.Room, &n.Sender, &n.MessageID, &n.Content, &n.Read, &n.Time); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func notificationPayload(n config.Notification) gin.H {
+	return gin.H{
+		"type":      "notification",
+		"id":        n.ID,
+		"room":      n.Room,
+		"sender":    n.Sender,
+		"messageId": n.MessageID,
+		"content":   n.Content,
+		"read":      n.Read,
+		"time":      n.Time,
+	}
+}
+
+// 推送消息到指定用户的所有在线连接
+func sendToUser(username string, payload gin.H) {
+	config.Mu.Lock()
+	defer config.Mu.Unlock()
+
+	for client, name := range config.Clients {
+		if name != username {
+			continue
+		}
+		if err := client.WriteJSON(payload); err != nil {
+			config.Logger.WithError(err).WithField("username", username).Error("Error sending message to user")
+		}
+	}
+}
+
+// 用户重新连接时推送离线期间收到的未读通知
+func pushUnreadNotifications(ctx context.Context, conn *websocket.Conn, username string) {
+	notifications, err := loadUnreadNotifications(ctx, username)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Error loading unread notifications")
+		return
+	}
+
+	// 按时间先后推送
+	for i := len(notifications) - 1; i >= 0; i-- {
+		if err := writeJSON(conn, notificationPayload(notifications[i])); err != nil {
+			logging.FromContext(ctx).WithError(err).Error("Error pushing unread notification")
+			return
+		}
+	}
+}
+
+// 获取当前用户的通知列表，?unread=true 时只返回未读通知
+func GetNotifications(c *gin.Context) {
+	username := c.GetString("username")
+	unreadOnly := c.Query("unread") == "true"
+
+	notifications, err := queryNotifications(c.Request.Context(), username, unreadOnly)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error fetching notifications")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching notifications"})
+		return
+	}
+
+	var unread int
+	err = config.PgConn.QueryRow(c.Request.Context(), "SELECT COUNT(*) FROM notifications WHERE username = $1 AND is_read = FALSE", username).Scan(&unread)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error counting unread notifications")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting unread notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notifications": notifications, "unread": unread})
+}
+
+// 将指定通知（或全部通知）标记为已读
+func MarkNotificationsRead(c *gin.Context) {
+	username := c.GetString("username")
+
+	var req markReadRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+
+	query := "UPDATE notifications SET is_read = TRUE WHERE username = $1 AND is_read = FALSE"
+	args := []interface{}{username}
+	if len(req.IDs) > 0 {
+		query += " AND id = ANY($2)"
+		args = append(args, req.IDs)
+	}
+
+	tag, err := config.PgConn.Exec(c.Request.Context(), query, args...)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error marking notifications as read")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error marking notifications as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Success", "updated": tag.RowsAffected()})
+}
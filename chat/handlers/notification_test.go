@@ -0,0 +1,227 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/handlers"
+	"example.com/m/chat/middlewares"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 模擬 JWT 中間件寫入的用戶名
+func withUsername(username string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("username", username)
+		c.Next()
+	}
+}
+
+// 為用戶寫入一條通知，返回通知 ID，測試結束時刪除該用戶的所有通知
+func insertNotification(t *testing.T, username, content string, read bool) int {
+	t.Helper()
+	var id int
+	err := config.PgConn.QueryRow(config.Ctx,
+		"INSERT INTO notifications (username, room, sender, message_id, content, is_read) VALUES ($1, 'general', 'alice', 0, $2, $3) RETURNING id",
+		username, content, read).Scan(&id)
+	require.NoError(t, err)
+	t.Cleanup(func() { config.PgConn.Exec(config.Ctx, "DELETE FROM notifications WHERE username = $1", username) })
+	return id
+}
+
+func isRead(t *testing.T, id int) bool {
+	t.Helper()
+	var read bool
+	require.NoError(t, config.PgConn.QueryRow(config.Ctx, "SELECT is_read FROM notifications WHERE id = $1", id).Scan(&read))
+	return read
+}
+
+type notificationsResponse struct {
+	Notifications []config.Notification `json:"notifications"`
+	Unread        int                   `json:"unread"`
+}
+
+func TestGetNotifications(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := fmt.Sprintf("notify-get-%d", time.Now().UnixNano())
+	unread := insertNotification(t, user, "@"+user+" unread", false)
+	read := insertNotification(t, user, "@"+user+" read", true)
+	insertNotification(t, user+"-other", "not yours", false)
+
+	router := gin.Default()
+	router.Use(withUsername(user))
+	router.GET("/notifications", handlers.GetNotifications)
+
+	get := func(url string) notificationsResponse {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var body notificationsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	// 返回當前用戶的全部通知，不包含其他用戶的通知
+	body := get("/notifications")
+	assert.Equal(t, 1, body.Unread)
+	ids := []int{}
+	for _, n := range body.Notifications {
+		assert.Equal(t, user, n.Username)
+		ids = append(ids, n.ID)
+	}
+	assert.ElementsMatch(t, []int{unread, read}, ids)
+
+	// 只返回未讀通知
+	body = get("/notifications?unread=true")
+	assert.Equal(t, 1, body.Unread)
+	require.Len(t, body.Notifications, 1)
+	assert.Equal(t, unread, body.Notifications[0].ID)
+	assert.Equal(t, "@"+user+" unread", body.Notifications[0].Content)
+	assert.False(t, body.Notifications[0].Read)
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := fmt.Sprintf("notify-read-%d", time.Now().UnixNano())
+	other := user + "-other"
+	first := insertNotification(t, user, "first", false)
+	second := insertNotification(t, user, "second", false)
+	third := insertNotification(t, user, "third", false)
+	foreign := insertNotification(t, other, "foreign", false)
+
+	router := gin.Default()
+	router.Use(withUsername(user))
+	router.POST("/notifications/read", handlers.MarkNotificationsRead)
+
+	post := func(body string) (int, map[string]interface{}) {
+		req, err := http.NewRequest(http.MethodPost, "/notifications/read", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var responseBody map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responseBody))
+		return w.Code, responseBody
+	}
+
+	// 標記指定通知為已讀，其他用戶的通知 ID 不受影響
+	code, body := post(fmt.Sprintf(`{"ids":[%d,%d]}`, first, foreign))
+	assert.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 1, body["updated"])
+	assert.True(t, isRead(t, first))
+	assert.False(t, isRead(t, second))
+	assert.False(t, isRead(t, third))
+	assert.False(t, isRead(t, foreign))
+
+	// 空請求標記當前用戶的全部通知為已讀
+	code, body = post("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 2, body["updated"])
+	assert.True(t, isRead(t, second))
+	assert.True(t, isRead(t, third))
+	assert.False(t, isRead(t, foreign))
+
+	// 無效的請求內容
+	code, _ = post("{invalid")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+// 連接並以指定用戶身份完成認證
+func dialAuthenticated(t *testing.T, wsURL, username string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	token, err := middlewares.GenerateJWT(username)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteJSON(map[string]string{"type": "auth", "token": token}))
+	return conn
+}
+
+// 讀取下一條指定類型的消息，跳過在線狀態等其他推送
+func readFrame(t *testing.T, conn *websocket.Conn, frameType string) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var frame map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&frame))
+		if frame["type"] == frameType {
+			return frame
+		}
+	}
+}
+
+func TestMentionNotificationDelivered(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	suffix := time.Now().UnixNano()
+	sender := fmt.Sprintf("mention-sender-%d", suffix)
+	mentioned := fmt.Sprintf("mention-target-%d", suffix)
+	room := fmt.Sprintf("mention-room-%d", suffix)
+	for _, username := range []string{sender, mentioned} {
+		_, err := config.PgConn.Exec(config.Ctx, "INSERT INTO users (username, password) VALUES ($1, 'x') ON CONFLICT (username) DO NOTHING", username)
+		require.NoError(t, err)
+	}
+	t.Cleanup(func() {
+		config.PgConn.Exec(config.Ctx, "DELETE FROM notifications WHERE username = $1", mentioned)
+		config.PgConn.Exec(config.Ctx, "DELETE FROM room_members WHERE room = $1", room)
+		config.PgConn.Exec(config.Ctx, "DELETE FROM chat_messages WHERE room = $1", room)
+		config.PgConn.Exec(config.Ctx, "DELETE FROM users WHERE username = ANY($1)", []string{sender, mentioned})
+	})
+
+	router := gin.New()
+	router.GET("/ws", handlers.HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	target := dialAuthenticated(t, wsURL, mentioned)
+	readFrame(t, target, "userStatus")
+	conn := dialAuthenticated(t, wsURL, sender)
+	readFrame(t, conn, "userStatus")
+
+	content := "hi @" + mentioned
+	require.NoError(t, conn.WriteJSON(map[string]string{
+		"type":    "message",
+		"room":    room,
+		"content": content,
+		"time":    time.Now().Format(time.RFC3339),
+	}))
+
+	// 被提及的在線用戶收到通知推送
+	frame := readFrame(t, target, "notification")
+	assert.Equal(t, room, frame["room"])
+	assert.Equal(t, sender, frame["sender"])
+	assert.Equal(t, content, frame["content"])
+	assert.Equal(t, false, frame["read"])
+
+	// 通知已保存且關聯到發出的消息
+	var id, messageID int
+	var storedSender string
+	err := config.PgConn.QueryRow(config.Ctx,
+		"SELECT n.id, n.sender, n.message_id FROM notifications n JOIN chat_messages m ON m.id = n.message_id WHERE n.username = $1 AND n.room = $2",
+		mentioned, room).Scan(&id, &storedSender, &messageID)
+	require.NoError(t, err)
+	assert.EqualValues(t, id, frame["id"])
+	assert.EqualValues(t, messageID, frame["messageId"])
+	assert.Equal(t, sender, storedSender)
+
+	// 發送者本人不會收到通知
+	var count int
+	require.NoError(t, config.PgConn.QueryRow(config.Ctx, "SELECT COUNT(*) FROM notifications WHERE username = $1", sender).Scan(&count))
+	assert.Zero(t, count)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
+	"example.com/m/chat/retention"
+	"github.com/gin-gonic/gin"
+)
+
+type retentionRequest struct {
+	KeepDays     int `json:"keepDays"`
+	KeepMessages int `json:"keepMessages"`
+}
+
+// 获取房间的消息保留策略
+func GetRetentionPolicy(c *gin.Context) {
+	policy, err := retention.GetPolicy(c.Request.Context(), config.PgConn, c.Param("room"))
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error fetching retention policy")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching retention policy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policy": policy, "forever": policy.Forever()})
+}
+
+// 设置房间的消息保留策略，仅房间管理员可用
+// keepDays 与 keepMessages 都为 0 时表示永久保留
+func SetRetentionPolicy(c *gin.Context) {
+	room := c.Param("room")
+	username := c.GetString("username")
+
+	var req retentionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if !requireRoomModerator(c, room) {
+		return
+	}
+
+	policy := retention.Policy{Room: room, KeepDays: req.KeepDays, KeepMessages: req.KeepMessages, UpdatedBy: username}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := retention.SetPolicy(c.Request.Context(), config.PgConn, policy); err != nil {
+		logging.FromGin(c).WithError(err).Error("Error saving retention policy")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving retention policy"})
+		return
+	}
+	if err := RefreshMetricRooms(c.Request.Context()); err != nil {
+		logging.FromGin(c).WithError(err).Warn("Error refreshing metric rooms")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policy": policy, "forever": policy.Forever()})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/metrics"
+)
+
+// metricRoomsInterval 是刷新指标 room 标签的间隔
+const metricRoomsInterval = time.Minute
+
+// RefreshMetricRooms 从数据库加载可以作为指标 room 标签的房间
+// 只有管理员登记过的房间（有房间管理员或保留策略）才单独统计，客户端随意填写的房间名不会产生新的时间序列
+func RefreshMetricRooms(ctx context.Context) error {
+	rows, err := config.PgConn.Query(ctx, `
+		SELECT room FROM room_moderators
+		UNION
+		SELECT room FROM room_retention_policies
+		ORDER BY room`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var rooms []string
+	for rows.Next() {
+		var room string
+		if err := rows.Scan(&room); err != nil {
+			return err
+		}
+		rooms = append(rooms, room)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	metrics.SetRooms(rooms)
+	return nil
+}
+
+// RunMetricRooms 定期刷新指标 room 标签，直到 ctx 结束
+func RunMetricRooms(ctx context.Context) {
+	ticker := time.NewTicker(metricRoomsInterval)
+	defer ticker.Stop()
+	for {
+		if err := RefreshMetricRooms(ctx); err != nil && ctx.Err() == nil {
+			config.Logger.WithError(err).Warn("Error refreshing metric rooms")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
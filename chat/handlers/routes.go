@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
 	"strings"
 
 	"github.com/gin-contrib/cors"
@@ -12,21 +13,31 @@ import (
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
+	"example.com/m/chat/metrics"
 	"example.com/m/chat/middlewares"
 )
 
 func SetupRoutes(r *gin.Engine) {
+	cfg := config.Current
+
+	// 为每个请求创建追踪 span，沿用上游传来的 traceparent
+	r.Use(middlewares.MiddlewareTracing())
+	// 按路由统计请求数、状态码与耗时
+	r.Use(metrics.Middleware())
+	// 为每个请求分配 X-Request-ID，并把带有 request_id 的日志器放入请求 context
+	r.Use(logging.RequestID())
 
 	// STEP 1：讓所有 SPA 中的檔案可以在正確的路徑被找到
-	r.Use(static.Serve("/", static.LocalFile("./chat/chat-app/build", true)))
+	r.Use(static.Serve("/", static.LocalFile(cfg.Server.BuildDir, true)))
 
 	// STEP 2： serve 靜態檔案
-	r.Static("/css", "public/css/")
-	r.Static("/js", "public/js/")
-	r.Static("/resources", "public/resources/")
-	store := cookie.NewStore([]byte("secret"))
+	r.Static("/css", filepath.Join(cfg.Server.PublicDir, "css"))
+	r.Static("/js", filepath.Join(cfg.Server.PublicDir, "js"))
+	r.Static("/resources", filepath.Join(cfg.Server.PublicDir, "resources"))
+	store := cookie.NewStore([]byte(cfg.Auth.SessionSecret))
 	r.Use(sessions.Sessions("mysession", store))
 
 	// CSRF 保護
@@ -34,9 +45,10 @@ func SetupRoutes(r *gin.Engine) {
 
 	// 添加 CORS 支持
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},                                             // 替换为你的前端地址
-		AllowMethods:     []string{"POST", "GET", "OPTIONS"},                        // 确保允许 OPTIONS 方法
-		AllowHeaders:     []string{"Content-Type", "X-CSRF-Token", "Authorization"}, // 添加您需要的自定义头
+		AllowOrigins:     cfg.Server.CORSOrigins,                                                             // 通过 server.corsOrigins 配置前端地址
+		AllowMethods:     []string{"POST", "GET", "PUT", "DELETE", "OPTIONS"},                                // 确保允许 OPTIONS 方法
+		AllowHeaders:     []string{"Content-Type", "X-CSRF-Token", "Authorization", logging.RequestIDHeader}, // 添加您需要的自定义头
+		ExposeHeaders:    []string{logging.RequestIDHeader},
 		AllowCredentials: true,
 	}))
 
@@ -49,12 +61,15 @@ func SetupRoutes(r *gin.Engine) {
 	})
 
 	// 路由设置
-	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/livez", Livez)
+	r.GET("/readyz", Readyz)
 	r.POST("/register", RegisterUser)
 	r.POST("/login", LoginUser)
 	r.POST("/logout", LogoutUser)
 
 	r.GET("/ws", HandleWebSocket)
+	r.POST("/hooks/:token", ReceiveIncomingWebhook)
 
 	// 使用 JWT 中间件保护以下路由
 	protected := r.Group("/")
@@ -71,10 +86,30 @@ func SetupRoutes(r *gin.Engine) {
 		protected.GET("/online-users", GetOnlineUsers)
 		protected.GET("/chat-history", GetChatHistory)
 		protected.GET("/latest-chat-date", GetLatestChatDate)
+		protected.GET("/notifications", GetNotifications)
+		protected.POST("/notifications/read", MarkNotificationsRead)
+		protected.POST("/rooms/:room/hooks", CreateIncomingWebhook)
+		protected.GET("/rooms/:room/webhooks", ListOutgoingWebhooks)
+		protected.POST("/rooms/:room/webhooks", CreateOutgoingWebhook)
+		protected.GET("/webhooks/:id/deliveries", GetWebhookDeliveries)
+		protected.GET("/rooms/:room/export", ExportRoom)
+		protected.GET("/rooms/:room/retention", GetRetentionPolicy)
+		protected.PUT("/rooms/:room/retention", SetRetentionPolicy)
+	}
+
+	// 管理接口，仅 auth.adminUsers 中的用户可用
+	admin := r.Group("/admin")
+	admin.Use(middlewares.MiddlewareJWT(), RequireAdmin)
+	{
+		admin.GET("/log-level", GetLogLevel)
+		admin.PUT("/log-level", SetLogLevel)
+		admin.GET("/rooms/:room/moderators", ListRoomModerators)
+		admin.PUT("/rooms/:room/moderators/:username", AddRoomModerator)
+		admin.DELETE("/rooms/:room/moderators/:username", RemoveRoomModerator)
 	}
 
 	r.NoRoute(func(ctx *gin.Context) {
-		file, _ := ioutil.ReadFile("./chat/chat-app/build/index.html")
+		file, _ := ioutil.ReadFile(filepath.Join(cfg.Server.BuildDir, "index.html"))
 		etag := fmt.Sprintf("%x", md5.Sum(file)) //nolint:gosec
 
 		ctx.Header("ETag", etag)
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/metrics"
+	"example.com/m/chat/utils"
+	"github.com/gorilla/websocket"
+)
+
+// ErrShuttingDown 表示服务器正在关闭，不再接受新的消息
+var ErrShuttingDown = errors.New("server is shutting down")
+
+var (
+	shutdownMu   sync.Mutex
+	shuttingDown bool // 不再接受新的 WebSocket 连接
+	writesClosed bool // 不再接受新的消息写入
+	// inFlight 跟踪正在处理的消息写入与出站 Webhook 投递
+	inFlight sync.WaitGroup
+	// connections 跟踪仍在运行的 WebSocket 连接处理函数，sockets 包含所有已升级的连接（含未认证的连接）
+	connections sync.WaitGroup
+	sockets     = make(map[*websocket.Conn]struct{})
+	// deliveries 在服务器运行期间有效，Shutdown 停止接受消息后取消它，
+	// 尚未完成的出站 Webhook 投递不再重试，并记录为 abandoned
+	deliveries, cancelDeliveries = context.WithCancel(context.Background())
+)
+
+// beginWrite 登记一次消息写入，连接全部关闭后返回 false
+// 关闭过程中已连接的客户端发送的消息仍会被保存
+func beginWrite() bool {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	if writesClosed {
+		return false
+	}
+	inFlight.Add(1)
+	return true
+}
+
+// beginConnection 登记一个 WebSocket 连接，服务器关闭后返回 false
+func beginConnection(conn *websocket.Conn) bool {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	if shuttingDown {
+		return false
+	}
+	sockets[conn] = struct{}{}
+	connections.Add(1)
+	metrics.WebSocketConnections.Inc()
+	return true
+}
+
+// endConnection 在连接处理函数退出时调用
+func endConnection(conn *websocket.Conn) {
+	shutdownMu.Lock()
+	delete(sockets, conn)
+	shutdownMu.Unlock()
+	metrics.WebSocketConnections.Dec()
+	connections.Done()
+}
+
+// deliveryContext 返回出站 Webhook 投递使用的服务器生命周期 context
+func deliveryContext() context.Context {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	return deliveries
+}
+
+// ShuttingDown 表示服务器是否已开始关闭
+func ShuttingDown() bool {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	return shuttingDown
+}
+
+// Shutdown 关闭所有 WebSocket 连接并等待进行中的写入完成
+//  1. 停止接受新的连接
+//  2. 向所有客户端发送 "going away" 关闭帧，客户端会重新连接到其他实例
+//  3. 等待连接处理函数退出，期间收到的消息仍会被保存
+//  4. 停止接受新的消息，取消未完成的 Webhook 投递，等待进行中的消息写入与投递记录完成
+//  5. 在 Redis 中将本实例的所有用户标记为离线
+//
+// ctx 超时后强制关闭剩余的连接
+func Shutdown(ctx context.Context) error {
+	shutdownMu.Lock()
+	shuttingDown = true
+	conns := make([]*websocket.Conn, 0, len(sockets))
+	for conn := range sockets {
+		conns = append(conns, conn)
+	}
+	shutdownMu.Unlock()
+
+	clients := snapshotClients()
+	deadline := time.Now().Add(time.Second)
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for _, conn := range conns {
+		if err := conn.WriteControl(websocket.CloseMessage, closeMsg, deadline); err != nil {
+			config.Logger.WithError(err).Warn("Error sending close frame")
+		}
+	}
+
+	// 客户端回应关闭帧后连接处理函数会退出，超时则强制关闭
+	if err := waitGroup(ctx, &connections); err != nil {
+		config.Logger.WithError(err).Warn("Timed out waiting for WebSocket connections, closing them")
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	shutdownMu.Lock()
+	writesClosed = true
+	cancelDeliveries()
+	shutdownMu.Unlock()
+
+	err := waitGroup(ctx, &inFlight)
+	if err != nil {
+		config.Logger.WithError(err).Warn("Timed out draining in-flight messages")
+	}
+
+	config.Mu.Lock()
+	for conn := range config.Clients {
+		delete(config.Clients, conn)
+	}
+	config.Mu.Unlock()
+
+	// 连接处理函数退出时已经更新过状态，这里再确保所有用户都被标记为离线
+	for _, username := range clients {
+		if username == "" {
+			continue
+		}
+		if err := utils.UpdateUserOnlineStatus(config.RedisClient, config.Ctx, username, false); err != nil {
+			config.Logger.WithError(err).WithField("username", username).Error("Error updating online status in Redis")
+		}
+	}
+
+	return err
+}
+
+// waitGroup 等待 wg 完成或 ctx 结束
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// snapshotClients 返回当前连接的副本，避免在持有锁时进行网络写入
+func snapshotClients() map[*websocket.Conn]string {
+	config.Mu.Lock()
+	defer config.Mu.Unlock()
+
+	clients := make(map[*websocket.Conn]string, len(config.Clients))
+	for conn, username := range config.Clients {
+		clients[conn] = username
+	}
+	return clients
+}
+
+// clientUsername 返回连接对应的用户名，未认证时为空
+func clientUsername(conn *websocket.Conn) string {
+	config.Mu.Lock()
+	defer config.Mu.Unlock()
+	return config.Clients[conn]
+}
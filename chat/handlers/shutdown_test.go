@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// resetShutdown 恢復關閉狀態，避免影響其他測試
+func resetShutdown() {
+	shutdownMu.Lock()
+	shuttingDown = false
+	writesClosed = false
+	deliveries, cancelDeliveries = context.WithCancel(context.Background())
+	shutdownMu.Unlock()
+}
+
+func TestShutdownSendsGoingAway(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Cleanup(resetShutdown)
+
+	router := gin.New()
+	router.GET("/ws", HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer conn.Close()
+
+	// 等待連接被登記
+	assert.Eventually(t, func() bool {
+		shutdownMu.Lock()
+		defer shutdownMu.Unlock()
+		return len(sockets) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- Shutdown(ctx) }()
+
+	// 客戶端收到 going away 關閉帧，讀取時回應關閉帧讓服務端退出
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error: %v", err)
+	assert.NoError(t, <-done)
+
+	// 關閉後拒絕新的連接與消息寫入
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Error(t, err)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	assert.False(t, beginWrite())
+}
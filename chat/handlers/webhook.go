@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
+	"example.com/m/chat/metrics"
+	"example.com/m/chat/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// 出站 Webhook 使用的 HTTP 客戶端與重試策略，測試時可替換
+	// 默認客戶端拒絕連接內網、回環與鏈路本地地址
+	WebhookClient      = utils.NewWebhookClient(10 * time.Second)
+	WebhookRetryPolicy = utils.DefaultRetryPolicy
+
+	// 同时进行的出站 Webhook 投递数上限，已满时新的投递被丢弃并记录
+	webhookSlots = make(chan struct{}, maxConcurrentDeliveries)
+
+	errDeliveryDropped   = errors.New("dropped: too many deliveries in progress")
+	errDeliveryAbandoned = errors.New("abandoned: server shutting down")
+)
+
+const maxConcurrentDeliveries = 64
+
+type createIncomingWebhookRequest struct {
+	BotName string `json:"botName" binding:"required"`
+}
+
+type createOutgoingWebhookRequest struct {
+	URL string `json:"url" binding:"required"`
+}
+
+// 消息总是以创建 Webhook 时配置的机器人名称发送
+type incomingWebhookRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// 出站 Webhook 的 JSON 內容
+type webhookEvent struct {
+	Event   string             `json:"event"`
+	Room    string             `json:"room"`
+	Message config.ChatMessage `json:"message"`
+	SentAt  time.Time          `json:"sentAt"`
+}
+
+// 生成隨機令牌
+func generateToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// requireRoomModerator 在当前用户不是房间管理员时返回错误响应，返回 false 表示请求已结束
+func requireRoomModerator(c *gin.Context, room string) bool {
+	ok, err := isRoomModerator(c.Request.Context(), room, c.GetString("username"))
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error checking moderator")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking permissions"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": ErrNotModerator.Error()})
+		return false
+	}
+	return true
+}
+
+// 为房间创建入站 Webhook，外部系统通过 POST /hooks/:token 以机器人身份发消息，仅房间管理员可用
+func CreateIncomingWebhook(c *gin.Context) {
+	room := c.Param("room")
+	var req createIncomingWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if !requireRoomModerator(c, room) {
+		return
+	}
+
+	// 机器人名称不能与用户重名，否则 Webhook 可以冒充该用户发消息
+	var taken bool
+	err := config.PgConn.QueryRow(c.Request.Context(), "SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)", req.BotName).Scan(&taken)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error checking bot name")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking bot name"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bot name is already used by a user"})
+		return
+	}
+
+	token, err := generateToken(24)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error generating webhook token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating webhook token"})
+		return
+	}
+
+	var id int
+	err = config.PgConn.QueryRow(c.Request.Context(),
+		"INSERT INTO incoming_webhooks (room, token, bot_name, created_by) VALUES ($1, $2, $3, $4) RETURNING id",
+		room, token, req.BotName, c.GetString("username")).Scan(&id)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error creating incoming webhook")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating incoming webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": id, "room": room, "botName": req.BotName, "token": token, "url": "/hooks/" + token})
+}
+
+// 接收入站 Webhook 消息，经由与 WebSocket 相同的流程保存并广播
+func ReceiveIncomingWebhook(c *gin.Context) {
+	token := c.Param("token")
+	var req incomingWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	var room, botName string
+	err := config.PgConn.QueryRow(c.Request.Context(), "SELECT room, bot_name FROM incoming_webhooks WHERE token = $1", token).Scan(&room, &botName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown webhook"})
+		return
+	}
+
+	message := config.ChatMessage{
+		Room:    room,
+		Sender:  botName,
+		Content: req.Content,
+		Time:    time.Now(),
+	}
+	message, err = processChatMessage(c.Request.Context(), message)
+	if errors.Is(err, ErrShuttingDown) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error processing webhook message")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving message"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"status": "Success", "message": message})
+}
+
+// 为房间注册出站 Webhook，新消息会以签名 JSON 投递到该 URL，仅房间管理员可用
+// 只允许 http/https 的公网地址
+func CreateOutgoingWebhook(c *gin.Context) {
+	room := c.Param("room")
+	var req createOutgoingWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := utils.ValidateWebhookURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook URL: " + err.Error()})
+		return
+	}
+	if !requireRoomModerator(c, room) {
+		return
+	}
+
+	secret, err := generateToken(32)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error generating webhook secret")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating webhook secret"})
+		return
+	}
+
+	var id int
+	err = config.PgConn.QueryRow(c.Request.Context(),
+		"INSERT INTO outgoing_webhooks (room, url, secret, created_by) VALUES ($1, $2, $3, $4) RETURNING id",
+		room, req.URL, secret, c.GetString("username")).Scan(&id)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error creating outgoing webhook")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating outgoing webhook"})
+		return
+	}
+
+	// 密钥只在创建时返回一次
+	c.JSON(http.StatusCreated, gin.H{"id": id, "room": room, "url": req.URL, "secret": secret})
+}
+
+// 列出房间的出站 Webhook（不包含密钥），仅房间管理员可用
+func ListOutgoingWebhooks(c *gin.Context) {
+	if !requireRoomModerator(c, c.Param("room")) {
+		return
+	}
+	webhooks, err := loadOutgoingWebhooks(c.Request.Context(), c.Param("room"), false)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error fetching outgoing webhooks")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching outgoing webhooks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+}
+
+// 获取出站 Webhook 的投递日志，仅 Webhook 的创建者与房间管理员可用
+func GetWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook id"})
+		return
+	}
+
+	var room, createdBy string
+	err = config.PgConn.QueryRow(c.Request.Context(), "SELECT room, COALESCE(created_by, '') FROM outgoing_webhooks WHERE id = $1", id).Scan(&room, &createdBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown webhook"})
+		return
+	}
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error fetching webhook")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching webhook"})
+		return
+	}
+	if createdBy != c.GetString("username") && !requireRoomModerator(c, room) {
+		return
+	}
+
+	rows, err := config.PgConn.Query(c.Request.Context(), `
+		SELECT id, webhook_id, delivery_id, COALESCE(message_id, 0), attempt, COALESCE(status_code, 0), COALESCE(error, ''), success, COALESCE(duration_ms, 0), time
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY time DESC
+		LIMIT 100`, id)
+	if err != nil {
+		logging.FromGin(c).WithError(err).Error("Error fetching webhook deliveries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching webhook deliveries"})
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []config.WebhookDelivery{}
+	for rows.Next() {
+		var d config.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.DeliveryID, &d.MessageID, &d.Attempt, &d.StatusCode, &d.Error, &d.Success, &d.DurationMs, &d.Time); err != nil {
+			logging.FromGin(c).WithError(err).Error("Error scanning webhook delivery")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning webhook delivery"})
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+func loadOutgoingWebhooks(ctx context.Context, room string, activeOnly bool) ([]config.OutgoingWebhook, error) {
+	query := "SELECT id, room, url, secret, COALESCE(created_by, ''), active, time FROM outgoing_webhooks WHERE room = $1"
+	if activeOnly {
+		query += " AND active = TRUE"
+	}
+
+	rows, err := config.PgConn.Query(ctx, query, room)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []config.OutgoingWebhook{}
+	for rows.Next() {
+		var w config.OutgoingWebhook
+		if err := rows.Scan(&w.ID, &w.Room, &w.URL, &w.Secret, &w.CreatedBy, &w.Active, &w.Time); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// 将新消息异步投递到房间的所有出站 Webhook
+func dispatchOutgoingWebhooks(ctx context.Context, message config.ChatMessage) {
+	logger := logging.FromContext(ctx)
+	webhooks, err := loadOutgoingWebhooks(ctx, message.Room, true)
+	if err != nil {
+		logger.WithError(err).Error("Error loading outgoing webhooks")
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(webhookEvent{Event: "message.created", Room: message.Room, Message: message, SentAt: time.Now()})
+	if err != nil {
+		logger.WithError(err).Error("Error encoding webhook payload")
+		return
+	}
+
+	// 投递在 processChatMessage 登记的写入期间开始，关闭服务器时会等待投递记录完成
+	for _, webhook := range webhooks {
+		select {
+		case webhookSlots <- struct{}{}:
+		default:
+			// 目标持续超时时不为每条消息堆积 goroutine，丢弃的投递同样记录在投递日志中
+			metrics.MessageDroppedCounter.WithLabelValues("webhook_overflow").Inc()
+			deliveryID := uuid.NewString()
+			logger.WithFields(logrus.Fields{"webhook_id": webhook.ID, "delivery_id": deliveryID}).Warn("Dropping webhook delivery")
+			if err := saveWebhookDelivery(context.WithoutCancel(ctx), webhook.ID, deliveryID, message.ID, utils.DeliveryAttempt{Attempt: 1, Err: errDeliveryDropped}); err != nil {
+				logger.WithError(err).Error("Error saving webhook delivery")
+			}
+			continue
+		}
+		inFlight.Add(1)
+		go func(webhook config.OutgoingWebhook) {
+			defer inFlight.Done()
+			defer func() { <-webhookSlots }()
+			deliverOutgoingWebhook(ctx, webhook, message.ID, body)
+		}(webhook)
+	}
+}
+
+// 投递在后台进行，不随请求或连接的 context 取消，只沿用其日志字段与追踪上下文
+// 服务器关闭时投递被取消，并追加一条 abandoned 记录
+func deliverOutgoingWebhook(ctx context.Context, webhook config.OutgoingWebhook, messageID int, body []byte) {
+	deliveryID := uuid.NewString()
+	logger := logging.FromContext(ctx).WithFields(logrus.Fields{"webhook_id": webhook.ID, "delivery_id": deliveryID})
+	headers := map[string]string{
+		"X-Chat-Event":    "message.created",
+		"X-Chat-Delivery": deliveryID,
+	}
+
+	// 投递记录在取消后仍要写入
+	saveCtx := context.WithoutCancel(ctx)
+	ctx, cancel := context.WithCancel(saveCtx)
+	defer cancel()
+	stop := context.AfterFunc(deliveryContext(), cancel)
+	defer stop()
+
+	attempts := 0
+	err := utils.DeliverWebhook(ctx, WebhookClient, webhook.URL, webhook.Secret, headers, body, WebhookRetryPolicy,
+		func(attempt utils.DeliveryAttempt) {
+			attempts = attempt.Attempt
+			if err := saveWebhookDelivery(saveCtx, webhook.ID, deliveryID, messageID, attempt); err != nil {
+				logger.WithError(err).Error("Error saving webhook delivery")
+			}
+		})
+	if err != nil && ctx.Err() != nil {
+		logger.WithError(err).Warn("Webhook delivery abandoned")
+		abandoned := utils.DeliveryAttempt{Attempt: attempts + 1, Err: errDeliveryAbandoned}
+		if err := saveWebhookDelivery(saveCtx, webhook.ID, deliveryID, messageID, abandoned); err != nil {
+			logger.WithError(err).Error("Error saving webhook delivery")
+		}
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Webhook delivery failed")
+	}
+}
+
+func saveWebhookDelivery(ctx context.Context, webhookID int, deliveryID string, messageID int, attempt utils.DeliveryAttempt) error {
+	var errText string
+	if attempt.Err != nil {
+		errText = attempt.Err.Error()
+	}
+	_, err := config.PgConn.Exec(ctx,
+		"INSERT INTO webhook_deliveries (webhook_id, delivery_id, message_id, attempt, status_code, error, success, duration_ms) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		webhookID, deliveryID, messageID, attempt.Attempt, attempt.StatusCode, errText, attempt.Success(), attempt.Duration.Milliseconds())
+	return err
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 服务器关闭时正在重试的投递立即结束，并在投递日志中留下 abandoned 记录
+func TestWebhookDeliveryAbandonedOnShutdown(t *testing.T) {
+	called := make(chan struct{}, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+	}))
+	defer receiver.Close()
+
+	client, policy := WebhookClient, WebhookRetryPolicy
+	t.Cleanup(func() {
+		WebhookClient, WebhookRetryPolicy = client, policy
+		resetShutdown()
+	})
+	WebhookClient = receiver.Client()
+	WebhookRetryPolicy = utils.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+
+	const webhookID = -1
+	config.PgConn.Exec(config.Ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = $1", webhookID)
+	t.Cleanup(func() {
+		config.PgConn.Exec(config.Ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = $1", webhookID)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		deliverOutgoingWebhook(context.Background(), config.OutgoingWebhook{ID: webhookID, URL: receiver.URL, Secret: "secret"}, 1, []byte(`{}`))
+		close(done)
+	}()
+	<-called
+	cancelDeliveries()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery was not cancelled")
+	}
+
+	rows, err := config.PgConn.Query(config.Ctx, "SELECT attempt, success, error FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY attempt", webhookID)
+	require.NoError(t, err)
+	defer rows.Close()
+	var attempts []int
+	var errs []string
+	for rows.Next() {
+		var attempt int
+		var success bool
+		var errText string
+		require.NoError(t, rows.Scan(&attempt, &success, &errText))
+		assert.False(t, success)
+		attempts = append(attempts, attempt)
+		errs = append(errs, errText)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int{1, 2}, attempts)
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, errDeliveryAbandoned.Error(), errs[1])
+	}
+}
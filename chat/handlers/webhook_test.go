@@ -0,0 +1,105 @@
+package handlers_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/m/chat/config"
+	"example.com/m/chat/handlers"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiveIncomingWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.POST("/hooks/:token", handlers.ReceiveIncomingWebhook)
+
+	// 缺少 content
+	req, _ := http.NewRequest(http.MethodPost, "/hooks/unknown", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 未知的令牌
+	req, _ = http.NewRequest(http.MethodPost, "/hooks/unknown", bytes.NewBufferString(`{"content":"build passed"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateOutgoingWebhookInvalidURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(withUsername("testuser"))
+	router.POST("/rooms/:room/webhooks", handlers.CreateOutgoingWebhook)
+
+	for _, body := range []string{
+		`{}`, `{"url":"ftp://example.com"}`, `{"url":"not a url"}`,
+		// 不能让服务器向内网、回环或链路本地地址发请求
+		`{"url":"http://127.0.0.1:6379/"}`, `{"url":"http://localhost/hook"}`,
+		`{"url":"http://169.254.169.254/latest/meta-data"}`, `{"url":"http://10.0.0.1/hook"}`,
+	} {
+		req, _ := http.NewRequest(http.MethodPost, "/rooms/general/webhooks", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestWebhookPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	current := config.Current
+	t.Cleanup(func() { config.Current = current })
+	cfg := *current
+	cfg.Auth.AdminUsers = []string{"webhook-admin"}
+	config.Current = &cfg
+
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("username", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	router.POST("/rooms/:room/hooks", handlers.CreateIncomingWebhook)
+	router.GET("/rooms/:room/webhooks", handlers.ListOutgoingWebhooks)
+	router.POST("/rooms/:room/webhooks", handlers.CreateOutgoingWebhook)
+	router.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
+
+	request := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 普通用户不能为房间创建 Webhook，也不能查看房间的出站 Webhook
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/rooms/general/hooks", "testuser", `{"botName":"ci"}`).Code)
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/rooms/general/webhooks", "testuser", `{"url":"https://example.com/hook"}`).Code)
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/rooms/general/webhooks", "testuser", "").Code)
+
+	// 机器人名称不能冒充已有用户
+	_, err := config.PgConn.Exec(config.Ctx, "INSERT INTO users (username, password) VALUES ('webhook-victim', 'x') ON CONFLICT (username) DO NOTHING")
+	assert.NoError(t, err)
+	t.Cleanup(func() { config.PgConn.Exec(config.Ctx, "DELETE FROM users WHERE username = 'webhook-victim'") })
+	assert.Equal(t, http.StatusConflict, request(http.MethodPost, "/rooms/general/hooks", "webhook-admin", `{"botName":"webhook-victim"}`).Code)
+
+	// 投递日志只对创建者和房间管理员可见
+	var id int
+	err = config.PgConn.QueryRow(config.Ctx,
+		"INSERT INTO outgoing_webhooks (room, url, secret, created_by) VALUES ('general', 'https://example.com/hook', 'secret', 'webhook-owner') RETURNING id").Scan(&id)
+	assert.NoError(t, err)
+	t.Cleanup(func() { config.PgConn.Exec(config.Ctx, "DELETE FROM outgoing_webhooks WHERE id = $1", id) })
+	deliveries := fmt.Sprintf("/webhooks/%d/deliveries", id)
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, deliveries, "testuser", "").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, deliveries, "webhook-owner", "").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, deliveries, "webhook-admin", "").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/webhooks/0/deliveries", "webhook-admin", "").Code)
+}
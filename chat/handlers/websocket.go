@@ -1,120 +1,267 @@
 package handlers
 
 import (
-	"log"
+	"context"
+	"net/http"
+	"strings"
 	"time"
 
 	"example.com/m/chat/config"
+	"example.com/m/chat/logging"
 	"example.com/m/chat/metrics"
 	"example.com/m/chat/middlewares"
+	"example.com/m/chat/telemetry"
 	"example.com/m/chat/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // 处理 WebSocket 连接时更新在线用户状态
 func HandleWebSocket(c *gin.Context) {
+	// 服务器关闭期间拒绝新的连接，客户端会连接到其他实例
+	if ShuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrShuttingDown.Error()})
+		return
+	}
+
+	// 每个连接分配一个 ID，连接上的所有日志都带有 conn_id
+	ctx := logging.WithFields(c.Request.Context(), logrus.Fields{"conn_id": logging.NewID()})
+	logger := logging.FromContext(ctx)
 
 	// 升级 HTTP 连接到 WebSocket
 	conn, err := config.Upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Println("Failed to upgrade connection:", err)
+		logger.WithError(err).Warn("Failed to upgrade connection")
 		return
 	}
 	defer conn.Close()
 
+	if !beginConnection(conn) {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ErrShuttingDown.Error()))
+		return
+	}
+	defer endConnection(conn)
+
 	// 等待接收身份验证消息
+	session := &wsSession{conn: conn, ctx: ctx, logger: logger}
 	for {
 		var msg map[string]string
 		err := conn.ReadJSON(&msg)
 		if err != nil {
-			log.Println("Error reading JSON:", err)
+			session.logger.WithError(err).Debug("Error reading JSON")
+			break
+		}
+		if stop := session.handleFrame(msg); stop {
 			break
 		}
+	}
+	logger = session.logger
+	session.leaveRooms()
+
+	// 处理用户断开连接
+	config.Mu.Lock()
+	username := config.Clients[conn]
+	delete(config.Clients, conn)
+	config.Mu.Unlock()
 
-		// 处理身份验证消息
-		if msg["type"] == "auth" {
-			tokenString := msg["token"]
-			log.Printf("Received token: %s", tokenString)
+	// 未认证的连接没有在线状态需要更新
+	if username == "" {
+		return
+	}
+	logger.Info("User disconnected")
 
-			claims, err := middlewares.ParseToken(tokenString)
+	// 更新用户在线状态到 Redis
+	if err := utils.UpdateUserOnlineStatus(config.RedisClient, config.Ctx, username, false); err != nil {
+		logger.WithError(err).Error("Error updating online status in Redis")
+	}
 
-			if err == nil {
-				username := claims.Username
-				config.Clients[conn] = username // 将用户添加到连接列表
-				log.Printf("User %s connected", username)
-				BroadcastUserStatus(username, true) // 广播用户上线状态
-
-				// 更新用户在线状态到 Redis
-				if err := utils.UpdateUserOnlineStatus(config.RedisClient, config.Ctx, username, true); err != nil {
-					log.Println("Error updating online status in Redis:", err)
-				}
-			} else {
-				log.Println("Could not parse claims")
-				break
+	// 广播用户下线消息
+	BroadcastUserStatus(username, false)
+}
+
+// wsSession 保存一个 WebSocket 连接的状态，认证后 ctx 与 logger 带有 username
+type wsSession struct {
+	conn   *websocket.Conn
+	ctx    context.Context
+	logger *logrus.Entry
+	rooms  map[string]string // 连接发过消息的房间及其 room 标签，用于按房间统计活跃连接
+}
+
+// enterRoom 记录连接在房间中活跃，每个房间只计数一次
+func (s *wsSession) enterRoom(room string) {
+	if _, ok := s.rooms[room]; ok || room == "" {
+		return
+	}
+	if s.rooms == nil {
+		s.rooms = make(map[string]string)
+	}
+	// 记下进入时的标签，登记的房间变化后仍能减少同一个时间序列
+	label := metrics.RoomLabel(room)
+	s.rooms[room] = label
+	metrics.WebSocketRoomConnections.WithLabelValues(label).Inc()
+}
+
+// leaveRooms 在连接断开时减少各房间的活跃连接数
+func (s *wsSession) leaveRooms() {
+	for _, label := range s.rooms {
+		metrics.WebSocketRoomConnections.WithLabelValues(label).Dec()
+	}
+	s.rooms = nil
+}
+
+// handleFrame 处理一条客户端消息，返回 true 时关闭连接
+// 每条消息是一个独立的 trace，通过 link 关联到建立连接的请求
+func (s *wsSession) handleFrame(msg map[string]string) (stop bool) {
+	ctx, span := telemetry.Tracer().Start(s.ctx, "websocket "+msg["type"],
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(s.ctx)),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("chat.frame.type", msg["type"]),
+			attribute.String("chat.room", msg["room"]),
+		),
+	)
+	defer span.End()
+	conn, logger := s.conn, s.logger
+
+	// 处理身份验证消息
+	if msg["type"] == "auth" {
+		claims, err := middlewares.ParseToken(msg["token"])
+
+		if err == nil {
+			username := claims.Username
+			config.Mu.Lock()
+			config.Clients[conn] = username // 将用户添加到连接列表
+			config.Mu.Unlock()
+
+			s.ctx = logging.WithFields(s.ctx, logrus.Fields{"username": username})
+			s.logger = logging.FromContext(s.ctx)
+			ctx = logging.WithFields(ctx, logrus.Fields{"username": username})
+			logger = s.logger
+			logger.Info("User connected")
+			span.SetAttributes(semconv.EnduserIDKey.String(username))
+			BroadcastUserStatus(username, true) // 广播用户上线状态
+
+			// 更新用户在线状态到 Redis
+			if err := utils.UpdateUserOnlineStatus(config.RedisClient, ctx, username, true); err != nil {
+				logger.WithError(err).Error("Error updating online status in Redis")
 			}
+
+			// 推送离线期间收到的提及通知
+			pushUnreadNotifications(ctx, conn, username)
+		} else {
+			logger.WithError(err).Warn("Could not parse claims")
+			span.SetStatus(codes.Error, "invalid token")
+			return true
 		}
+	}
 
-		// 处理聊天消息
-		if msg["type"] == "message" {
-			room := msg["room"]
-			sender := msg["sender"]
-			content := msg["content"]
-			timeStr := msg["time"]
-
-			msgTime, err := time.Parse(time.RFC3339, timeStr)
-			if err != nil {
-				log.Println("Invalid message time:", err)
-				continue
-			}
+	// 处理聊天消息
+	if msg["type"] == "message" {
+		room := msg["room"]
+		sender := msg["sender"]
+		content := msg["content"]
+		timeStr := msg["time"]
 
-			message := config.ChatMessage{
-				Room:    room,
-				Sender:  sender,
-				Content: content,
-				Time:    msgTime,
-			}
+		msgTime, err := time.Parse(time.RFC3339, timeStr)
+		if err != nil {
+			logger.WithError(err).Warn("Invalid message time")
+			span.SetStatus(codes.Error, "invalid message time")
+			return false
+		}
 
-			if err := saveMessageToDB(message); err != nil {
-				log.Println("Error saving message to DB:", err)
-				continue
-			}
+		// 消息总是以已认证的用户身份发送，客户端填写的 sender 只用于校验
+		sender, err = utils.MessageSender(clientUsername(conn), sender)
+		if err != nil {
+			logger.WithError(err).Warn("Rejected chat message")
+			span.SetStatus(codes.Error, err.Error())
+			writeJSON(conn, gin.H{"type": "commandResponse", "room": room, "content": err.Error(), "error": true})
+			return false
+		}
 
-			BroadcastMessageToRoom(room, message)
+		// 处理斜杠命令，命令以已认证的用户身份执行
+		if name, args, raw, ok := utils.ParseCommand(content); ok {
+			span.SetAttributes(attribute.String("chat.command", name))
+			handleCommand(&CommandContext{
+				Context:  ctx,
+				Conn:     conn,
+				Username: sender,
+				Room:     room,
+				Name:     name,
+				Args:     args,
+				Raw:      raw,
+				Time:     msgTime,
+			})
+			return false
+		}
+		// "//text" 发送以 "/" 开头的普通消息
+		if strings.HasPrefix(content, "//") {
+			content = content[1:]
 		}
 
-		// 处理登出消息
-		if msg["type"] == "logout" {
-			username := config.Clients[conn]
-			log.Printf("User %s logging out", username)
+		s.enterRoom(room)
 
-			// 更新用户在线状态到 Redis
-			if err := utils.UpdateUserOnlineStatus(config.RedisClient, config.Ctx, username, false); err != nil {
-				log.Println("Error updating online status in Redis:", err)
+		if muted, err := isMuted(ctx, room, sender); err != nil || muted {
+			if err == nil {
+				err = ErrMuted
 			}
+			writeJSON(conn, gin.H{"type": "commandResponse", "room": room, "content": err.Error(), "error": true})
+			return false
+		}
 
-			// 广播用户下线消息
-			BroadcastUserStatus(username, false)
-			break // 退出循环以关闭连接
+		message := config.ChatMessage{
+			Room:    room,
+			Sender:  sender,
+			Content: content,
+			Time:    msgTime,
+		}
+
+		if _, err := processChatMessage(ctx, message); err != nil {
+			logger.WithError(err).Error("Error saving message to DB")
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return false
 		}
 	}
 
-	// 处理用户断开连接
-	username := config.Clients[conn]
-	delete(config.Clients, conn)
-	log.Printf("User %s disconnected", username)
+	// 处理登出消息
+	if msg["type"] == "logout" {
+		username := clientUsername(conn)
+		logger.Info("User logging out")
 
-	// 更新用户在线状态到 Redis
-	if err := utils.UpdateUserOnlineStatus(config.RedisClient, config.Ctx, username, false); err != nil {
-		log.Println("Error updating online status in Redis:", err)
+		// 更新用户在线状态到 Redis
+		if err := utils.UpdateUserOnlineStatus(config.RedisClient, ctx, username, false); err != nil {
+			logger.WithError(err).Error("Error updating online status in Redis")
+		}
+
+		// 广播用户下线消息
+		BroadcastUserStatus(username, false)
+		return true // 关闭连接
 	}
+	return false
+}
 
-	// 广播用户下线消息
-	BroadcastUserStatus(username, false)
+// writeJSON 向单个连接写入一条消息
+// 广播与其他 goroutine 在持有 config.Mu 时写入同一连接，gorilla/websocket 不允许并发写入，
+// 所以单个连接的写入也必须持有 config.Mu，调用时不能已经持有该锁
+func writeJSON(conn *websocket.Conn, v interface{}) error {
+	config.Mu.Lock()
+	defer config.Mu.Unlock()
+	return conn.WriteJSON(v)
 }
 
 // 广播消息到房间
 func BroadcastMessageToRoom(room string, message config.ChatMessage) {
+	defer observeBroadcast("message", time.Now())
+
+	config.Mu.Lock()
+	defer config.Mu.Unlock()
+
 	for client, _ := range config.Clients {
 		err := client.WriteJSON(gin.H{
 			"type":    "message",
@@ -124,7 +271,8 @@ func BroadcastMessageToRoom(room string, message config.ChatMessage) {
 			"time":    message.Time,
 		})
 		if err != nil {
-			config.Logger.Error("Error broadcasting message:", err)
+			config.Logger.WithError(err).Error("Error broadcasting message")
+			metrics.MessageDroppedCounter.WithLabelValues("write_error").Inc()
 			client.Close()
 			delete(config.Clients, client)
 		} else {
@@ -139,18 +287,65 @@ func BroadcastUserStatus(username string, online bool) {
 	if online {
 		status = "online"
 	}
+	defer observeBroadcast("user_status", time.Now())
+
+	config.Mu.Lock()
+	defer config.Mu.Unlock()
+
 	for client := range config.Clients {
 		err := client.WriteJSON(gin.H{"type": "userStatus", "username": username, "status": status})
 		if err != nil {
-			log.Println("Error broadcasting user status:", err)
+			config.Logger.WithError(err).Error("Error broadcasting user status")
 		}
 	}
 }
 
-func saveMessageToDB(message config.ChatMessage) error {
-	_, err := config.PgConn.Exec(config.Ctx, "INSERT INTO chat_messages (room, sender, content, time) VALUES ($1, $2, $3, $4)",
-		message.Room, message.Sender, message.Content, message.Time)
-	return err
+// observeBroadcast 记录一次广播写入所有本地连接的耗时，包括等待 config.Mu 的时间
+func observeBroadcast(kind string, start time.Time) {
+	metrics.BroadcastDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+}
+
+// 处理一条新消息：保存、广播、通知被提及的用户并投递出站 Webhook
+// WebSocket 与入站 Webhook 共用此流程
+// 服务器关闭时返回 ErrShuttingDown，关闭过程会等待进行中的消息处理完成
+// ctx 携带调用方的日志字段
+func processChatMessage(ctx context.Context, message config.ChatMessage) (config.ChatMessage, error) {
+	metrics.MessageReceiveCounter.WithLabelValues(metrics.RoomLabel(message.Room)).Inc()
+	if !beginWrite() {
+		metrics.MessageDroppedCounter.WithLabelValues("shutting_down").Inc()
+		return message, ErrShuttingDown
+	}
+	defer inFlight.Done()
+
+	ctx, span := telemetry.Tracer().Start(ctx, "chat.process_message", trace.WithAttributes(
+		attribute.String("chat.room", message.Room),
+		attribute.String("chat.sender", message.Sender),
+	))
+	defer span.End()
+
+	id, err := saveMessageToDB(ctx, message)
+	if err != nil {
+		metrics.MessageDroppedCounter.WithLabelValues("db_error").Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return message, err
+	}
+	message.ID = id
+	span.SetAttributes(attribute.Int("chat.message_id", id))
+
+	// 先推送给本实例的连接，再通过 Redis 发布给其他实例
+	BroadcastMessageToRoom(message.Room, message)
+	publishFanout(ctx, message)
+	notifyMentions(ctx, message)
+	dispatchOutgoingWebhooks(ctx, message)
+	return message, nil
+}
+
+func saveMessageToDB(ctx context.Context, message config.ChatMessage) (int, error) {
+	var id int
+	err := config.PgConn.QueryRow(ctx, "INSERT INTO chat_messages (room, sender, content, time) VALUES ($1, $2, $3, $4) RETURNING id",
+		message.Room, message.Sender, message.Content, message.Time).Scan(&id)
+	return id, err
 }
 
 func saveUserDisconnectTime(username string) error {
@@ -165,12 +360,12 @@ func handleWebSocketDisconnect(conn *websocket.Conn, username string) {
 
 	// 更新用户在线状态
 	if err := utils.UpdateUserOnlineStatus(config.RedisClient, config.Ctx, username, false); err != nil {
-		config.Logger.Error("Error updating online status in Redis:", err)
+		config.Logger.WithError(err).Error("Error updating online status in Redis")
 	}
 
 	// 保存断开连接时间
 	if err := saveUserDisconnectTime(username); err != nil {
-		config.Logger.Error("Error saving disconnect time:", err)
+		config.Logger.WithError(err).Error("Error saving disconnect time")
 	}
 
 	// 广播用户状态
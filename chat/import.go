@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"example.com/m/chat/archive"
+	"example.com/m/chat/config"
+)
+
+// ChatImport 将导出的房间归档重放到 chat_messages
+// room 为空时使用归档中的房间，format 为空时按扩展名判断
+func ChatImport(flags *config.Flags, path, room, format string) {
+	if path == "" {
+		log.Fatal("An archive file must be given with -importFile")
+	}
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	archiveFormat, err := archive.ParseFormat(format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	loadConfig(flags)
+	db, err := config.InitDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := config.CheckAndCreateTableChat(db); err != nil {
+		log.Fatalf("Error checking/creating chat table: %v", err)
+	}
+
+	reader, err := archive.NewReader(file, archiveFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result, err := archive.Import(context.Background(), db, reader, archive.ImportOptions{Room: room})
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	fmt.Printf("Imported %d messages from %d members into room %s\n", result.Messages, result.Members, result.Room)
+}
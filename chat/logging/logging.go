@@ -0,0 +1,48 @@
+// Package logging 提供聊天服務器統一使用的 logrus 日誌器
+// 請求與 WebSocket 連接的日誌字段（request_id、conn_id、username）通過 context 傳遞
+package logging
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Logger 是聊天服務器的根日誌器，config.Logger 指向同一個實例
+var Logger = New()
+
+// New 創建輸出 JSON 並隱藏敏感信息的日誌器
+func New() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(RedactHook{})
+	return logger
+}
+
+type entryKey struct{}
+
+// WithEntry 將日誌條目保存到 context 中
+func WithEntry(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, entryKey{}, entry)
+}
+
+// WithFields 在 context 中的日誌條目上添加字段
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return WithEntry(ctx, FromContext(ctx).WithFields(fields))
+}
+
+// FromContext 返回 context 中的日誌條目，沒有時返回根日誌器
+func FromContext(ctx context.Context) *logrus.Entry {
+	if ctx != nil {
+		if entry, ok := ctx.Value(entryKey{}).(*logrus.Entry); ok {
+			return entry
+		}
+	}
+	return logrus.NewEntry(Logger)
+}
+
+// FromGin 返回當前請求的日誌條目
+func FromGin(c *gin.Context) *logrus.Entry {
+	return FromContext(c.Request.Context())
+}
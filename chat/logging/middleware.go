@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader 用於傳遞請求 ID，客戶端未提供時由服務器生成
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 限制客戶端提供的請求 ID 長度，避免日誌被污染
+const maxRequestIDLength = 128
+
+// NewID 生成請求 ID 與 WebSocket 連接 ID
+func NewID() string {
+	return uuid.NewString()
+}
+
+// validRequestID 只接受長度合理的可打印 ASCII 字符
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID 為每個請求分配或沿用 X-Request-ID，並把帶有 request_id 的日誌條目放入請求 context
+// 請求結束時記錄一條包含狀態碼與耗時的日誌
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = NewID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Set("requestID", id)
+
+		entry := logrus.NewEntry(Logger).WithField("request_id", id)
+		// 追蹤中間件在前時，帶上 trace_id 以便從日誌跳轉到對應的追蹤
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+			entry = entry.WithField("trace_id", sc.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(WithEntry(c.Request.Context(), entry))
+
+		start := time.Now()
+		c.Next()
+
+		FromGin(c).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.FullPath(),
+			"status":     c.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+		}).Debug("Request completed")
+	}
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redacted 替換日誌中的敏感信息
+const Redacted = "[REDACTED]"
+
+var (
+	// JWT 由三段 base64url 組成，頭部總是以 "eyJ" 開頭
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)\bBearer\s+[^\s"]+`)
+
+	// 字段名包含以下詞語時整個值會被隱藏
+	sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie", "encrypted"}
+)
+
+// RedactString 隱藏字符串中的 JWT 與 Bearer 令牌
+func RedactString(s string) string {
+	s = bearerPattern.ReplaceAllString(s, "Bearer "+Redacted)
+	return jwtPattern.ReplaceAllString(s, Redacted)
+}
+
+// sensitiveKey 判斷字段名是否為敏感字段
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHook 在日誌寫出前隱藏敏感字段與消息中的令牌
+type RedactHook struct{}
+
+func (RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (RedactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = RedactString(entry.Message)
+
+	// logrus 在觸發 hook 前已複製 Data，可以直接修改
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			if sensitiveKey(key) {
+				entry.Data[key] = Redacted
+			} else {
+				entry.Data[key] = RedactString(v)
+			}
+		case error:
+			entry.Data[key] = RedactString(v.Error())
+		default:
+			if sensitiveKey(key) {
+				entry.Data[key] = Redacted
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	dbQueryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "chat_db_query_duration_seconds",
+			Help:    "PostgreSQL query latency by statement type",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		},
+		[]string{"operation", "status"},
+	)
+	redisCommandDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "chat_redis_command_duration_seconds",
+			Help:    "Redis command latency by command",
+			Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25},
+		},
+		[]string{"command", "status"},
+	)
+)
+
+type startKey struct{}
+
+func withStart(ctx context.Context) context.Context {
+	return context.WithValue(ctx, startKey{}, time.Now())
+}
+
+// elapsed 返回從 withStart 開始經過的秒數，ctx 中沒有開始時間時返回 false
+func elapsed(ctx context.Context) (float64, bool) {
+	start, ok := ctx.Value(startKey{}).(time.Time)
+	if !ok {
+		return 0, false
+	}
+	return time.Since(start).Seconds(), true
+}
+
+func status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// PgxTracer 記錄每個 pgx 查詢的耗時，操作標籤取 SQL 的第一個單詞
+type PgxTracer struct{}
+
+func (PgxTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(withStart(ctx), operationKey{}, operation(data.SQL))
+}
+
+func (PgxTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	seconds, ok := elapsed(ctx)
+	if !ok {
+		return
+	}
+	op, _ := ctx.Value(operationKey{}).(string)
+	dbQueryDuration.WithLabelValues(op, status(data.Err)).Observe(seconds)
+}
+
+type operationKey struct{}
+
+// operation 返回 SQL 的第一個單詞（SELECT、INSERT 等），不把參數或表名寫入標籤
+func operation(sql string) string {
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+	return "UNKNOWN"
+}
+
+// RedisHook 記錄每個 Redis 命令與管道的耗時，redis.Nil 不算錯誤
+type RedisHook struct{}
+
+var _ redis.Hook = RedisHook{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return withStart(ctx), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if seconds, ok := elapsed(ctx); ok {
+		redisCommandDuration.WithLabelValues(cmd.Name(), redisStatus(cmd.Err())).Observe(seconds)
+	}
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return withStart(ctx), nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	seconds, ok := elapsed(ctx)
+	if !ok {
+		return nil
+	}
+	var err error
+	for _, cmd := range cmds {
+		if redisStatus(cmd.Err()) == "error" {
+			err = cmd.Err()
+			break
+		}
+	}
+	redisCommandDuration.WithLabelValues("pipeline", status(err)).Observe(seconds)
+	return nil
+}
+
+func redisStatus(err error) string {
+	if errors.Is(err, redis.Nil) {
+		return "ok"
+	}
+	return status(err)
+}
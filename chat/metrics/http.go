@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_http_requests_total",
+			Help: "Total number of HTTP requests by route and status code",
+		},
+		[]string{"method", "route", "code"},
+	)
+	httpRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "chat_http_request_duration_seconds",
+			Help:    "HTTP request latency by route",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "route"},
+	)
+	httpRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "chat_http_requests_in_flight",
+		Help: "Number of HTTP requests being served",
+	})
+)
+
+// Middleware 記錄每個路由的請求數、錯誤數與耗時
+// 路由標籤使用 Gin 的路由模板，未匹配的請求記為 "unmatched"，避免路徑參數撐大標籤基數
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+
+		// WebSocket 請求的耗時是整個連接的時長，不計入請求延遲
+		if !c.IsWebsocket() {
+			httpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		}
+	}
+}
+
+// Handler 返回導出 Registry 的 HTTP 處理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
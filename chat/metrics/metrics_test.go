@@ -0,0 +1,127 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/m/chat/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// families 返回 Registry 中當前導出的所有指標名
+func families(t *testing.T) map[string]bool {
+	t.Helper()
+	mfs, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	names := make(map[string]bool, len(mfs))
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+	return names
+}
+
+// histogramCount 返回帶有指定標籤的直方圖樣本數
+func histogramCount(t *testing.T, name string, labels map[string]string) uint64 {
+	t.Helper()
+	mfs, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+	series:
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if labels[lp.GetName()] != lp.GetValue() {
+					continue series
+				}
+			}
+			return m.GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+func TestRegistryIncludesRuntimeCollectors(t *testing.T) {
+	names := families(t)
+	assert.True(t, names["go_goroutines"])
+	assert.True(t, names["chat_websocket_connections"])
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(metrics.Middleware())
+	router.GET("/rooms/:room", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	for _, path := range []string{"/rooms/general", "/rooms/random", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	assert.Contains(t, body, `chat_http_requests_total{code="204",method="GET",route="/rooms/:room"} 2`)
+	assert.Contains(t, body, `chat_http_requests_total{code="404",method="GET",route="unmatched"} 1`)
+	assert.Contains(t, body, `chat_http_request_duration_seconds_count{method="GET",route="/rooms/:room"} 2`)
+	assert.NotContains(t, body, "/rooms/general")
+}
+
+func TestPgxTracer(t *testing.T) {
+	tracer := metrics.PgxTracer{}
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "  insert into chat_messages (room) values ($1)"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+
+	assert.Equal(t, uint64(1), histogramCount(t, "chat_db_query_duration_seconds", map[string]string{"operation": "INSERT", "status": "error"}))
+}
+
+func TestRedisHook(t *testing.T) {
+	hook := metrics.RedisHook{}
+
+	get := redis.NewStringCmd(context.Background(), "get", "missing")
+	get.SetErr(redis.Nil)
+	ctx, _ := hook.BeforeProcess(context.Background(), get)
+	require.NoError(t, hook.AfterProcess(ctx, get))
+
+	cmds := []redis.Cmder{redis.NewStatusCmd(context.Background(), "set", "k", "v")}
+	ctx, _ = hook.BeforeProcessPipeline(context.Background(), cmds)
+	require.NoError(t, hook.AfterProcessPipeline(ctx, cmds))
+
+	assert.Equal(t, uint64(1), histogramCount(t, "chat_redis_command_duration_seconds", map[string]string{"command": "get", "status": "ok"}))
+	assert.Equal(t, uint64(1), histogramCount(t, "chat_redis_command_duration_seconds", map[string]string{"command": "pipeline", "status": "ok"}))
+}
+
+func TestRoomLabel(t *testing.T) {
+	t.Cleanup(func() { metrics.SetRooms(nil) })
+
+	// 未登記任何房間時全部記為 other
+	assert.Equal(t, metrics.OtherRoom, metrics.RoomLabel("general"))
+
+	metrics.SetRooms([]string{"general", "random"})
+	assert.Equal(t, "general", metrics.RoomLabel("general"))
+	assert.Equal(t, "random", metrics.RoomLabel("random"))
+	assert.Equal(t, metrics.OtherRoom, metrics.RoomLabel("attacker-chosen-1234"))
+
+	// 登記的房間數有上限
+	many := make([]string, metrics.MaxRoomLabels+10)
+	for i := range many {
+		many[i] = fmt.Sprintf("room-%d", i)
+	}
+	metrics.SetRooms(many)
+	assert.Equal(t, "room-0", metrics.RoomLabel("room-0"))
+	assert.Equal(t, metrics.OtherRoom, metrics.RoomLabel(fmt.Sprintf("room-%d", metrics.MaxRoomLabels)))
+	assert.Equal(t, metrics.OtherRoom, metrics.RoomLabel("general"))
+}
@@ -1,46 +1,102 @@
+// Package metrics 定義聊天服務器的 Prometheus 指標
+// 所有指標註冊在私有的 Registry 上，不使用全局註冊表，重複初始化不會 panic
 package metrics
 
 import (
-	"sync"
-
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
-var (
-	initOnce sync.Once
+// Registry 是聊天服務器的指標註冊表，由 /metrics 導出
+var Registry = prometheus.NewRegistry()
 
+var (
 	MessageSendCounter = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "chat_message_sent_total",
 		Help: "Total number of chat messages sent",
 	})
 
-	MessageReceiveCounter = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "chat_message_received_total",
-		Help: "Total number of chat messages received",
+	MessageReceiveCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_message_received_total",
+			Help: "Total number of chat messages received from clients and webhooks, by room (see RoomLabel)",
+		},
+		[]string{"room"},
+	)
+	MessageDroppedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_message_dropped_total",
+			Help: "Total number of chat messages that were not saved or delivered",
+		},
+		[]string{"reason"},
+	)
+	BroadcastDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "chat_broadcast_duration_seconds",
+			Help:    "Time spent writing one broadcast to all local WebSocket clients",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"type"},
+	)
+	WebSocketConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "chat_websocket_connections",
+		Help: "Number of open WebSocket connections",
 	})
+	WebSocketRoomConnections = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "chat_websocket_room_connections",
+			Help: "Number of open WebSocket connections that have posted to a room, by room (see RoomLabel)",
+		},
+		[]string{"room"},
+	)
 	RegisterUserCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "register_user_counter",
-			Help: "Counts the number of user registrations",
+			Name: "user_registration_total",
+			Help: "Total number of user registrations",
 		},
 		[]string{"status"},
 	)
 	LoginCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "login_counter",
-			Help: "Counts the number of user logins",
+			Name: "user_login_total",
+			Help: "Total number of user logins",
+		},
+		[]string{"status"},
+	)
+	RetentionArchivedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_retention_rows_archived_total",
+			Help: "Total number of chat messages archived and deleted by retention policies",
+		},
+		[]string{"room"},
+	)
+	RetentionRunsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_retention_runs_total",
+			Help: "Total number of retention worker runs",
 		},
 		[]string{"status"},
 	)
 )
 
-func InitMetrics() {
-	initOnce.Do(func() {
-		// 註冊 Prometheus 指標
-		prometheus.MustRegister(MessageSendCounter)
-		prometheus.MustRegister(MessageReceiveCounter)
-		prometheus.MustRegister(RegisterUserCounter)
-		prometheus.MustRegister(LoginCounter)
-		// 添加其他 Prometheus 指標的初始化代碼
-	})
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		MessageSendCounter,
+		MessageReceiveCounter,
+		MessageDroppedCounter,
+		BroadcastDuration,
+		WebSocketConnections,
+		WebSocketRoomConnections,
+		RegisterUserCounter,
+		LoginCounter,
+		RetentionArchivedCounter,
+		RetentionRunsCounter,
+		httpRequestsTotal,
+		httpRequestDuration,
+		httpRequestsInFlight,
+		dbQueryDuration,
+		redisCommandDuration,
+	)
 }
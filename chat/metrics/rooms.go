@@ -0,0 +1,34 @@
+package metrics
+
+import "sync/atomic"
+
+// OtherRoom 是未登記房間使用的 room 標籤值
+const OtherRoom = "other"
+
+// MaxRoomLabels 限制 room 標籤的取值數量
+const MaxRoomLabels = 200
+
+// rooms 是可以作為 room 標籤的房間，房間名來自客戶端，只有登記過的房間才單獨統計
+var rooms atomic.Pointer[map[string]struct{}]
+
+// SetRooms 設置可以作為 room 標籤的房間，超過 MaxRoomLabels 的部分記為 OtherRoom
+func SetRooms(names []string) {
+	set := make(map[string]struct{}, min(len(names), MaxRoomLabels))
+	for _, name := range names {
+		if len(set) == MaxRoomLabels {
+			break
+		}
+		set[name] = struct{}{}
+	}
+	rooms.Store(&set)
+}
+
+// RoomLabel 返回房間的 room 標籤值，未登記的房間返回 OtherRoom
+func RoomLabel(room string) string {
+	if set := rooms.Load(); set != nil {
+		if _, ok := (*set)[room]; ok {
+			return room
+		}
+	}
+	return OtherRoom
+}
@@ -4,11 +4,21 @@ import (
 	"net/http"
 	"time"
 
+	"example.com/m/chat/logging"
 	"example.com/m/chat/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
 )
 
+// jwtSecret 是签发与校验 JWT 使用的密钥，由 SetJWTSecret 从配置设置
+var jwtSecret = []byte("your-secret-key")
+
+// SetJWTSecret 设置 JWT 密钥
+func SetJWTSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
+
 type Claims struct {
 	Username string `json:"username"`
 	jwt.StandardClaims
@@ -23,12 +33,12 @@ func GenerateJWT(username string) (string, error) {
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte("your-secret-key")) // 确保将密钥替换为您的安全密钥
+	return token.SignedString(jwtSecret)
 }
 
 func ParseToken(tokenString string) (*Claims, error) {
 	tokenClaims, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte("your-secret-key"), nil
+		return jwtSecret, nil
 	})
 
 	if err == nil && tokenClaims != nil {
@@ -68,8 +78,9 @@ func MiddlewareJWT() gin.HandlerFunc {
 			return
 		}
 
-		// 将解析后的用户信息添加到上下文中
+		// 将解析后的用户信息添加到上下文中，之后的日志都带有 username
 		c.Set("username", claims.Username)
+		c.Request = c.Request.WithContext(logging.WithFields(c.Request.Context(), logrus.Fields{"username": claims.Username}))
 
 		// 继续处理请求
 		c.Next()
@@ -0,0 +1,71 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/m/chat/middlewares"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareTracing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	router := gin.New()
+	router.Use(middlewares.MiddlewareTracing())
+	router.GET("/rooms/:room", func(c *gin.Context) {
+		c.Set("username", "alice")
+		c.Status(http.StatusInternalServerError)
+	})
+	router.POST("/hooks/:token", func(c *gin.Context) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/rooms/general", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/hooks/s3cr3t-token", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	span := spans[0]
+	assert.Equal(t, "GET /rooms/:room", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, span.Status().Code)
+
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	assert.Equal(t, "500", attrs["http.status_code"])
+	assert.Equal(t, "alice", attrs["enduser.id"])
+	assert.Equal(t, "/rooms/:room", attrs["http.target"])
+
+	assert.Equal(t, "GET unmatched", spans[1].Name())
+
+	// 路径中的令牌不出现在 span 中
+	assert.Equal(t, "POST /hooks/:token", spans[2].Name())
+	for _, kv := range spans[2].Attributes() {
+		assert.NotContains(t, kv.Value.Emit(), "s3cr3t-token", string(kv.Key))
+	}
+}
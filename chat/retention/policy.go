@@ -0,0 +1,135 @@
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/m/chat/archive"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Policy 是房間的消息保留策略
+// KeepDays 與 KeepMessages 都為 0 時表示永久保留；兩者都設置時，超出任一限制的消息都會被歸檔
+type Policy struct {
+	Room         string    `json:"room"`
+	KeepDays     int       `json:"keepDays"`     // 保留最近 N 天的消息
+	KeepMessages int       `json:"keepMessages"` // 保留最近 N 條消息
+	UpdatedBy    string    `json:"updatedBy,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt,omitempty"`
+}
+
+// Forever 表示該策略是否永久保留消息
+func (p Policy) Forever() bool {
+	return p.KeepDays <= 0 && p.KeepMessages <= 0
+}
+
+// Cutoff 返回按天數保留時的截止時間，早於該時間的消息會被歸檔
+func (p Policy) Cutoff(now time.Time) (time.Time, bool) {
+	if p.KeepDays <= 0 {
+		return time.Time{}, false
+	}
+	return now.AddDate(0, 0, -p.KeepDays), true
+}
+
+// Validate 檢查策略是否合法
+func (p Policy) Validate() error {
+	if p.Room == "" {
+		return errors.New("room is required")
+	}
+	if p.KeepDays < 0 || p.KeepMessages < 0 {
+		return errors.New("keepDays and keepMessages must not be negative")
+	}
+	return nil
+}
+
+// GetPolicy 讀取房間的保留策略，未設置時返回永久保留
+func GetPolicy(ctx context.Context, db *pgxpool.Pool, room string) (Policy, error) {
+	policy := Policy{Room: room}
+	err := db.QueryRow(ctx, "SELECT keep_days, keep_messages, COALESCE(updated_by, ''), updated_at FROM room_retention_policies WHERE room = $1", room).
+		Scan(&policy.KeepDays, &policy.KeepMessages, &policy.UpdatedBy, &policy.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return policy, nil
+	}
+	return policy, err
+}
+
+// SetPolicy 保存房間的保留策略
+func SetPolicy(ctx context.Context, db *pgxpool.Pool, policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO room_retention_policies (room, keep_days, keep_messages, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (room) DO UPDATE SET keep_days = EXCLUDED.keep_days, keep_messages = EXCLUDED.keep_messages,
+			updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+		policy.Room, policy.KeepDays, policy.KeepMessages, policy.UpdatedBy)
+	return err
+}
+
+// listPolicies 讀取所有非永久保留的策略
+func listPolicies(ctx context.Context, db *pgxpool.Pool) ([]Policy, error) {
+	rows, err := db.Query(ctx, "SELECT room, keep_days, keep_messages FROM room_retention_policies WHERE keep_days > 0 OR keep_messages > 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []Policy
+	for rows.Next() {
+		var p Policy
+		if err := rows.Scan(&p.Room, &p.KeepDays, &p.KeepMessages); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// Batch 是一批待歸檔的消息
+type Batch struct {
+	Room     string
+	Messages []archive.Record
+}
+
+// FirstID 返回批次中第一條消息的 ID
+func (b Batch) FirstID() int { return b.Messages[0].ID }
+
+// LastID 返回批次中最後一條消息的 ID
+func (b Batch) LastID() int { return b.Messages[len(b.Messages)-1].ID }
+
+// IDs 返回批次中所有消息的 ID
+func (b Batch) IDs() []int {
+	ids := make([]int, len(b.Messages))
+	for i, m := range b.Messages {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+// Encode 將批次編碼為 gzip 壓縮的 JSON Lines 歸檔，格式與房間導出相同
+func (b Batch) Encode() ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	w, err := archive.NewWriter(zw, archive.FormatJSONL)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range b.Messages {
+		if err := w.Write(m); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress batch: %w", err)
+	}
+	return buf.Bytes(), nil
+}
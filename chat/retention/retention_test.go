@@ -0,0 +1,103 @@
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"example.com/m/chat/archive"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	now := time.Date(2024, 10, 20, 12, 0, 0, 0, time.UTC)
+
+	forever := Policy{Room: "general"}
+	assert.True(t, forever.Forever())
+	_, ok := forever.Cutoff(now)
+	assert.False(t, ok)
+
+	days := Policy{Room: "general", KeepDays: 30}
+	assert.False(t, days.Forever())
+	cutoff, ok := days.Cutoff(now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, 9, 20, 12, 0, 0, 0, time.UTC), cutoff)
+
+	assert.NoError(t, days.Validate())
+	assert.Error(t, Policy{}.Validate())
+	assert.Error(t, Policy{Room: "general", KeepMessages: -1}.Validate())
+}
+
+func TestExpiredQuery(t *testing.T) {
+	now := time.Now()
+
+	query, args := expiredQuery(Policy{Room: "general", KeepDays: 7}, now, nil, 100)
+	assert.Contains(t, query, "time < $2")
+	assert.NotContains(t, query, "OFFSET")
+	assert.Contains(t, query, "LIMIT $3")
+	assert.Len(t, args, 3)
+
+	// 按條數保留時與預先算出的位置比較，不再在每批中排序整個房間
+	keepAfter := &position{Time: now.Add(-time.Hour), ID: 42}
+	query, args = expiredQuery(Policy{Room: "general", KeepMessages: 500}, now, keepAfter, 100)
+	assert.NotContains(t, query, "time <")
+	assert.NotContains(t, query, "OFFSET")
+	assert.Contains(t, query, "(time, id) <= ($2, $3)")
+	assert.Equal(t, []interface{}{"general", keepAfter.Time, 42, 100}, args)
+
+	query, args = expiredQuery(Policy{Room: "general", KeepDays: 7, KeepMessages: 500}, now, keepAfter, 100)
+	assert.Contains(t, query, "time < $2 OR (time, id) <= ($3, $4)")
+	assert.Contains(t, query, "LIMIT $5")
+	assert.Len(t, args, 5)
+
+	// 消息未超過條數限制時只按天數歸檔
+	query, args = expiredQuery(Policy{Room: "general", KeepDays: 7, KeepMessages: 500}, now, nil, 100)
+	assert.NotContains(t, query, "id) <=")
+	assert.Len(t, args, 3)
+}
+
+func sampleBatch() Batch {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return Batch{Room: "general", Messages: []archive.Record{
+		{Kind: archive.KindMessage, ID: 3, Room: "general", Sender: "alice", Content: "old", Time: t0},
+		{Kind: archive.KindMessage, ID: 5, Room: "general", Sender: "bob", Content: "older", Time: t0.Add(time.Second)},
+	}}
+}
+
+func decodeBatch(t *testing.T, payload []byte) []archive.Record {
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("payload is not gzip: %v", err)
+	}
+	r, _ := archive.NewReader(zr, archive.FormatJSONL)
+
+	var records []archive.Record
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("could not decode payload: %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestBatchEncode(t *testing.T) {
+	batch := sampleBatch()
+	assert.Equal(t, 3, batch.FirstID())
+	assert.Equal(t, 5, batch.LastID())
+	assert.Equal(t, []int{3, 5}, batch.IDs())
+
+	payload, err := batch.Encode()
+	assert.NoError(t, err)
+
+	records := decodeBatch(t, payload)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "alice", records[0].Sender)
+		assert.Equal(t, "older", records[1].Content)
+	}
+}
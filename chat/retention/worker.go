@@ -0,0 +1,217 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/m/chat/archive"
+	"example.com/m/chat/logging"
+	"example.com/m/chat/metrics"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Sink 保存已過期的消息批次，在刪除消息的同一事務中調用
+type Sink interface {
+	Store(ctx context.Context, tx pgx.Tx, batch Batch, payload []byte) error
+}
+
+// TableSink 將批次保存到 chat_message_archives 表
+type TableSink struct{}
+
+func (TableSink) Store(ctx context.Context, tx pgx.Tx, batch Batch, payload []byte) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO chat_message_archives (room, first_message_id, last_message_id, first_time, last_time, row_count, payload)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		batch.Room, batch.FirstID(), batch.LastID(), batch.Messages[0].Time, batch.Messages[len(batch.Messages)-1].Time, len(batch.Messages), payload)
+	return err
+}
+
+// Worker 定期按保留策略歸檔並刪除過期消息
+type Worker struct {
+	DB        *pgxpool.Pool
+	Sink      Sink
+	Interval  time.Duration // 兩次運行之間的間隔
+	BatchSize int           // 每個事務歸檔的最大消息數
+	Now       func() time.Time
+}
+
+// NewWorker 使用默認配置創建歸檔 Worker，歸檔到 chat_message_archives 表
+func NewWorker(db *pgxpool.Pool) *Worker {
+	return &Worker{DB: db, Sink: TableSink{}, Interval: time.Hour, BatchSize: 1000, Now: time.Now}
+}
+
+// Run 立即運行一次，之後按 Interval 定期運行，直到 ctx 被取消
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := w.RunOnce(ctx); err != nil {
+			logging.Logger.WithError(err).Error("Error running retention")
+		} else if n > 0 {
+			logging.Logger.WithField("archived", n).Info("Retention archived messages")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 對所有設置了保留策略的房間執行一次歸檔，返回歸檔的消息數
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	policies, err := listPolicies(ctx, w.DB)
+	if err != nil {
+		metrics.RetentionRunsCounter.WithLabelValues("error").Inc()
+		return 0, fmt.Errorf("failed to load retention policies: %w", err)
+	}
+
+	total := 0
+	for _, policy := range policies {
+		n, err := w.ApplyPolicy(ctx, policy)
+		total += n
+		if err != nil {
+			metrics.RetentionRunsCounter.WithLabelValues("error").Inc()
+			return total, fmt.Errorf("room %s: %w", policy.Room, err)
+		}
+	}
+
+	metrics.RetentionRunsCounter.WithLabelValues("success").Inc()
+	return total, nil
+}
+
+// position 是消息在房間歷史中的排序位置，按 (time, id) 排序
+type position struct {
+	Time time.Time
+	ID   int
+}
+
+// ApplyPolicy 分批歸檔一個房間的過期消息，直到沒有過期消息為止
+func (w *Worker) ApplyPolicy(ctx context.Context, policy Policy) (int, error) {
+	if policy.Forever() {
+		return 0, nil
+	}
+
+	// 按條數保留時，只在開始時查找一次第 N+1 新的消息，它及更舊的消息都會被歸檔；
+	// 之後的新消息只會排在它前面，不需要每批重新排序整個房間的歷史
+	var keepAfter *position
+	if policy.KeepMessages > 0 {
+		p, ok, err := keepMessagesCutoff(ctx, w.DB, policy)
+		if err != nil {
+			return 0, fmt.Errorf("failed to find the oldest kept message: %w", err)
+		}
+		switch {
+		case ok:
+			keepAfter = &p
+		case policy.KeepDays <= 0:
+			return 0, nil
+		}
+	}
+
+	total := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		n, err := w.archiveBatch(ctx, policy, keepAfter)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < w.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// archiveBatch 在一個事務中選出一批過期消息、保存到 Sink 並刪除
+func (w *Worker) archiveBatch(ctx context.Context, policy Policy, keepAfter *position) (int, error) {
+	tx, err := w.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query, args := expiredQuery(policy, w.Now(), keepAfter, w.BatchSize)
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select expired messages: %w", err)
+	}
+
+	batch := Batch{Room: policy.Room}
+	for rows.Next() {
+		r := archive.Record{Kind: archive.KindMessage, Room: policy.Room}
+		if err := rows.Scan(&r.ID, &r.Sender, &r.Content, &r.Time); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch.Messages = append(batch.Messages, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(batch.Messages) == 0 {
+		return 0, nil
+	}
+
+	payload, err := batch.Encode()
+	if err != nil {
+		return 0, err
+	}
+	if err := w.Sink.Store(ctx, tx, batch, payload); err != nil {
+		return 0, fmt.Errorf("failed to store archive: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM chat_messages WHERE id = ANY($1)", batch.IDs()); err != nil {
+		return 0, fmt.Errorf("failed to delete archived messages: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit archive: %w", err)
+	}
+
+	metrics.RetentionArchivedCounter.WithLabelValues(policy.Room).Add(float64(len(batch.Messages)))
+	return len(batch.Messages), nil
+}
+
+// keepMessagesCutoff 返回房間中第 KeepMessages+1 新的消息的位置，消息不超過 KeepMessages 條時 ok 為 false
+func keepMessagesCutoff(ctx context.Context, db *pgxpool.Pool, policy Policy) (p position, ok bool, err error) {
+	err = db.QueryRow(ctx, "SELECT time, id FROM chat_messages WHERE room = $1 ORDER BY time DESC, id DESC OFFSET $2 LIMIT 1",
+		policy.Room, policy.KeepMessages).Scan(&p.Time, &p.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return p, false, nil
+	}
+	return p, err == nil, err
+}
+
+// expiredQuery 構造選擇過期消息的 SQL，按時間從舊到新排序並鎖定行
+// keepAfter 不為 nil 時，位於它及之前的消息超出了條數限制
+func expiredQuery(policy Policy, now time.Time, keepAfter *position, limit int) (string, []interface{}) {
+	query := "SELECT id, sender, content, time FROM chat_messages WHERE room = $1 AND ("
+	args := []interface{}{policy.Room}
+
+	var conditions []string
+	if cutoff, ok := policy.Cutoff(now); ok {
+		args = append(args, cutoff)
+		conditions = append(conditions, fmt.Sprintf("time < $%d", len(args)))
+	}
+	if keepAfter != nil {
+		args = append(args, keepAfter.Time, keepAfter.ID)
+		conditions = append(conditions, fmt.Sprintf("(time, id) <= ($%d, $%d)", len(args)-1, len(args)))
+	}
+	for i, c := range conditions {
+		if i > 0 {
+			query += " OR "
+		}
+		query += c
+	}
+
+	args = append(args, limit)
+	query += fmt.Sprintf(") ORDER BY time ASC, id ASC LIMIT $%d FOR UPDATE SKIP LOCKED", len(args))
+	return query, args
+}
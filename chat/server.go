@@ -1,14 +1,84 @@
 package chat
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"example.com/m/chat/config"
 	"example.com/m/chat/handlers"
+	"example.com/m/chat/metrics"
+	"example.com/m/chat/middlewares"
+	"example.com/m/chat/retention"
+	"example.com/m/chat/telemetry"
 	"github.com/gin-gonic/gin"
 )
 
-func ChatServer() {
+// loadConfig 按配置文件、环境变量与命令行参数加载配置，配置无效时退出
+func loadConfig(flags *config.Flags) *config.Config {
+	cfg, err := config.Load(flags)
+	if err != nil {
+		log.Fatalf("Error loading chat config: %v", err)
+	}
+	config.Current = cfg
+	return cfg
+}
+
+// PrintConfig 输出生效的配置，密钥会被隐藏
+func PrintConfig(flags *config.Flags) {
+	out, err := loadConfig(flags).YAML()
+	if err != nil {
+		log.Fatalf("Error encoding chat config: %v", err)
+	}
+	fmt.Print(string(out))
+}
+
+func ChatServer(flags *config.Flags) {
+	cfg := loadConfig(flags)
+
+	// Stop on SIGINT/SIGTERM so deploys drain connections instead of dropping them
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Export traces before any database or Redis call is made
+	shutdownTracing, err := telemetry.Setup(ctx, telemetry.Options{
+		Exporter:      cfg.Tracing.Exporter,
+		Endpoint:      cfg.Tracing.Endpoint,
+		ServiceName:   cfg.Tracing.ServiceName,
+		SampleRatio:   cfg.Tracing.SampleRatio,
+		SlowThreshold: time.Duration(cfg.Tracing.SlowThreshold),
+		Registerer:    metrics.Registry,
+	})
+	if err != nil {
+		log.Fatalf("Error setting up tracing: %v", err)
+	}
+
 	// Initialize configurations, databases, and other services
 	config.Init()
+	middlewares.SetJWTSecret(cfg.Auth.JWTSecret)
+
+	// Archive expired messages according to room retention policies
+	workerCtx, stopWorker := context.WithCancel(config.Ctx)
+	workerDone := make(chan struct{})
+	worker := retention.NewWorker(config.PgConn)
+	worker.Interval = time.Duration(cfg.Retention.Interval)
+	worker.BatchSize = cfg.Retention.BatchSize
+	go func() {
+		worker.Run(workerCtx)
+		close(workerDone)
+	}()
+
+	// Deliver messages published by other chat server instances to local clients
+	go handlers.RunFanout(workerCtx)
+
+	// Label metrics only with rooms registered by admins and moderators
+	go handlers.RunMetricRooms(workerCtx)
 
 	r := gin.Default()
 
@@ -16,5 +86,53 @@ func ChatServer() {
 	handlers.SetupRoutes(r)
 
 	// Start the server
-	r.Run(":8080")
+	srv := &http.Server{Addr: cfg.Server.Addr, Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+	config.Logger.WithField("addr", cfg.Server.Addr).Info("Chat server listening")
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			config.Logger.WithError(err).Error("Chat server stopped")
+		}
+	case <-ctx.Done():
+		config.Logger.Info("Shutting down chat server")
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+
+	// Send going-away close frames, drain message writes and mark local users offline.
+	// /readyz reports not ready from here on while the HTTP server keeps serving probes.
+	if err := handlers.Shutdown(shutdownCtx); err != nil {
+		config.Logger.WithError(err).Error("Error draining WebSocket connections")
+	}
+
+	// Stop accepting HTTP requests and wait for in-flight ones; hijacked WebSockets are not tracked here
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		config.Logger.WithError(err).Error("Error shutting down HTTP server")
+	}
+
+	stopWorker()
+	select {
+	case <-workerDone:
+	case <-shutdownCtx.Done():
+	}
+
+	if config.RedisClient != nil {
+		if err := config.RedisClient.Close(); err != nil {
+			config.Logger.WithError(err).Error("Error closing Redis client")
+		}
+	}
+	config.PgConn.Close()
+
+	// Flush spans recorded during shutdown
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		config.Logger.WithError(err).Error("Error flushing traces")
+	}
+	config.Logger.Info("Chat server stopped")
 }
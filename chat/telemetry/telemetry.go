@@ -0,0 +1,84 @@
+// Package telemetry 為聊天服務器配置 OpenTelemetry 追蹤
+// 包括導出器選擇、pgx 查詢追蹤、go-redis 鉤子以及跨實例傳遞追蹤上下文的工具
+package telemetry
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"example.com/m/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName 是聊天服務器創建 span 時使用的追蹤器名稱
+const TracerName = "example.com/m/chat"
+
+// Tracer 返回聊天服務器的追蹤器，使用全局 TracerProvider
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// Options 描述追蹤導出方式
+type Options struct {
+	Exporter    string // none、otlp、otlp-grpc、zipkin、jaeger 或 stdout
+	Endpoint    string // 為空時使用導出器的默認地址
+	ServiceName string
+	// 包含錯誤或慢 span 的追蹤全部導出，其餘按 SampleRatio 採樣
+	SampleRatio   float64
+	SlowThreshold time.Duration
+	// Registerer 用於註冊尾部採樣保留與丟棄的追蹤數指標
+	Registerer prometheus.Registerer
+}
+
+// Setup 根據 Options 設置全局 TracerProvider 與 W3C 傳播器
+// 返回的函數在退出時調用，用於發送剩餘的 span
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Exporter == "" || opts.Exporter == "none" {
+		// 不導出時保留默認的 no-op TracerProvider，仍然傳播上游的追蹤上下文
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := tracing.ExporterByName(opts.Exporter, opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := tracing.Setup(ctx, tracing.Options{
+		ServiceName: opts.ServiceName,
+		Exporter:    exporter,
+		TailSampling: &tracing.TailSamplingOptions{
+			Ratio:            opts.SampleRatio,
+			LatencyThreshold: opts.SlowThreshold,
+			Registerer:       opts.Registerer,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return provider.Shutdown, nil
+}
+
+// Inject 將 ctx 中的追蹤上下文寫入 map，用於通過消息隊列傳遞
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier
+}
+
+// Extract 從 map 中恢復追蹤上下文
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// spanName 截取 SQL 或命令的第一個單詞作為 span 名稱，避免把參數寫入名稱
+func spanName(prefix, statement string) string {
+	fields := strings.Fields(statement)
+	if len(fields) == 0 {
+		return prefix
+	}
+	return prefix + " " + strings.ToUpper(fields[0])
+}
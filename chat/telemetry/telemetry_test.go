@@ -0,0 +1,103 @@
+package telemetry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"example.com/m/chat/telemetry"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// useRecorder 安裝記錄 span 的全局 TracerProvider，測試結束後恢復
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestInjectExtract(t *testing.T) {
+	useRecorder(t)
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+
+	ctx, span := telemetry.Tracer().Start(context.Background(), "publish")
+	defer span.End()
+
+	carrier := telemetry.Inject(ctx)
+	assert.Contains(t, carrier, "traceparent")
+
+	remote := trace.SpanContextFromContext(telemetry.Extract(context.Background(), carrier))
+	assert.True(t, remote.IsRemote())
+	assert.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), remote.SpanID())
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	_, err := telemetry.Setup(context.Background(), telemetry.Options{Exporter: "carrier-pigeon"})
+	assert.Error(t, err)
+
+	shutdown, err := telemetry.Setup(context.Background(), telemetry.Options{Exporter: "none"})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestPgxTracer(t *testing.T) {
+	recorder := useRecorder(t)
+	tracer := telemetry.PgxTracer{}
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "select id from chat_messages where room = $1"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 3")})
+
+	ctx = tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "INSERT INTO notifications"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "postgres SELECT", spans[0].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Contains(t, spans[0].Attributes(), attribute.Int64("db.rows_affected", 3))
+	assert.Equal(t, "postgres INSERT", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+func TestRedisHook(t *testing.T) {
+	recorder := useRecorder(t)
+	hook := telemetry.RedisHook{}
+
+	// redis.Nil 表示鍵不存在，不應標記為錯誤
+	get := redis.NewStringCmd(context.Background(), "get", "session:alice")
+	get.SetErr(redis.Nil)
+	ctx, err := hook.BeforeProcess(context.Background(), get)
+	require.NoError(t, err)
+	require.NoError(t, hook.AfterProcess(ctx, get))
+
+	set := redis.NewStatusCmd(context.Background(), "set", "k", "v")
+	del := redis.NewIntCmd(context.Background(), "del", "k")
+	del.SetErr(errors.New("connection reset"))
+	ctx, err = hook.BeforeProcessPipeline(context.Background(), []redis.Cmder{set, del})
+	require.NoError(t, err)
+	require.NoError(t, hook.AfterProcessPipeline(ctx, []redis.Cmder{set, del}))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "redis GET", spans[0].Name())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, "redis PIPELINE", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
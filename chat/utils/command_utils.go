@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNotAuthenticated = errors.New("please authenticate before sending messages")
+	ErrSenderMismatch   = errors.New("sender does not match the authenticated user")
+)
+
+// ParseCommand 解析以 "/" 開頭的消息，返回小寫的命令名、以空白分隔的參數與命令名之後的原始文本
+// "//text" 用於發送以 "/" 開頭的普通消息，不視為命令
+func ParseCommand(content string) (name string, args []string, raw string, ok bool) {
+	if !strings.HasPrefix(content, "/") || strings.HasPrefix(content, "//") {
+		return "", nil, "", false
+	}
+
+	body := strings.TrimPrefix(content, "/")
+	name, raw, _ = strings.Cut(body, " ")
+	if name == "" || strings.ContainsAny(name, "\t\n") {
+		return "", nil, "", false
+	}
+	raw = strings.TrimSpace(raw)
+	return strings.ToLower(name), strings.Fields(raw), raw, true
+}
+
+// MessageSender 返回聊天消息的發送者：未認證的連接不能發消息，
+// 客戶端聲明的 sender 為空或與已認證的用戶名相同時才接受
+func MessageSender(authenticated, claimed string) (string, error) {
+	if authenticated == "" {
+		return "", ErrNotAuthenticated
+	}
+	if claimed != "" && claimed != authenticated {
+		return "", ErrSenderMismatch
+	}
+	return authenticated, nil
+}
@@ -0,0 +1,54 @@
+package utils_test
+
+import (
+	"testing"
+
+	"example.com/m/chat/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		content string
+		name    string
+		args    []string
+		raw     string
+		ok      bool
+	}{
+		{"hello", "", nil, "", false},
+		{"//not a command", "", nil, "", false},
+		{"/", "", nil, "", false},
+		{"/who", "who", []string{}, "", true},
+		{"/ME waves  hello", "me", []string{"waves", "hello"}, "waves  hello", true},
+		{"/mute @bob 5", "mute", []string{"@bob", "5"}, "@bob 5", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			name, args, raw, ok := utils.ParseCommand(tt.content)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.name, name)
+			assert.Equal(t, tt.raw, raw)
+			if tt.ok {
+				assert.Equal(t, tt.args, args)
+			}
+		})
+	}
+}
+
+func TestMessageSender(t *testing.T) {
+	sender, err := utils.MessageSender("alice", "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", sender)
+
+	// 不填 sender 時使用已認證的用戶名
+	sender, err = utils.MessageSender("alice", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", sender)
+
+	// 不能冒充其他用戶，也不能在認證前發消息
+	_, err = utils.MessageSender("alice", "bob")
+	assert.ErrorIs(t, err, utils.ErrSenderMismatch)
+	_, err = utils.MessageSender("", "bob")
+	assert.ErrorIs(t, err, utils.ErrNotAuthenticated)
+}
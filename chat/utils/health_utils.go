@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// HealthCheck 檢查一個依賴是否可用，返回 nil 表示健康
+type HealthCheck func(ctx context.Context) error
+
+// CheckResult 是單個依賴的檢查結果
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// HealthReport 匯總所有依賴的檢查結果
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Healthy 表示所有依賴都健康
+func (r HealthReport) Healthy() bool {
+	return r.Status == HealthStatusOK
+}
+
+// RunHealthChecks 並行執行所有檢查，每個檢查最多等待 timeout
+// 檢查函數即使忽略 ctx 也不會阻塞報告，超時的檢查會被標記為失敗
+func RunHealthChecks(ctx context.Context, timeout time.Duration, checks map[string]HealthCheck) HealthReport {
+	type named struct {
+		name   string
+		result CheckResult
+	}
+	results := make(chan named, len(checks))
+
+	for name, check := range checks {
+		go func(name string, check HealthCheck) {
+			results <- named{name, runHealthCheck(ctx, timeout, check)}
+		}(name, check)
+	}
+
+	report := HealthReport{Status: HealthStatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	for range checks {
+		r := <-results
+		report.Checks[r.name] = r.result
+		if r.result.Status != HealthStatusOK {
+			report.Status = HealthStatusFail
+		}
+	}
+	return report
+}
+
+func runHealthCheck(ctx context.Context, timeout time.Duration, check HealthCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: HealthStatusOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = HealthStatusFail
+		result.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "timed out after " + timeout.String()
+		}
+	}
+	return result
+}
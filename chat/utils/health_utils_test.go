@@ -0,0 +1,44 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/m/chat/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHealthChecks(t *testing.T) {
+	report := utils.RunHealthChecks(context.Background(), time.Second, map[string]utils.HealthCheck{
+		"postgres": func(ctx context.Context) error { return nil },
+		"redis":    func(ctx context.Context) error { return nil },
+	})
+	assert.True(t, report.Healthy())
+	assert.Len(t, report.Checks, 2)
+	assert.Equal(t, utils.HealthStatusOK, report.Checks["redis"].Status)
+
+	report = utils.RunHealthChecks(context.Background(), time.Second, map[string]utils.HealthCheck{
+		"postgres": func(ctx context.Context) error { return nil },
+		"redis":    func(ctx context.Context) error { return errors.New("connection refused") },
+	})
+	assert.False(t, report.Healthy())
+	assert.Equal(t, utils.HealthStatusOK, report.Checks["postgres"].Status)
+	assert.Equal(t, utils.HealthStatusFail, report.Checks["redis"].Status)
+	assert.Equal(t, "connection refused", report.Checks["redis"].Error)
+}
+
+func TestRunHealthChecksTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	start := time.Now()
+	report := utils.RunHealthChecks(context.Background(), 50*time.Millisecond, map[string]utils.HealthCheck{
+		// 忽略 ctx 的檢查也不會阻塞報告
+		"hub": func(ctx context.Context) error { <-block; return nil },
+	})
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, report.Healthy())
+	assert.Equal(t, "timed out after 50ms", report.Checks["hub"].Error)
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// RoomMention 是通知整個房間的特殊提及
+const RoomMention = "room"
+
+// mentionPattern 匹配 @username，用戶名只允許字母、數字、底線、點與連字號
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// ParseMentions 解析消息中的 @username 與 @room 提及
+// 返回去重後的用戶名列表（保持出現順序），以及是否包含 @room
+func ParseMentions(content string) (usernames []string, room bool) {
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		// 去掉結尾的標點，例如 "@alice." 或 "@bob-"
+		name := strings.TrimRight(match[1], ".-")
+		if name == "" {
+			continue
+		}
+		if strings.EqualFold(name, RoomMention) {
+			room = true
+			continue
+		}
+		if !seen[name] {
+			seen[name] = true
+			usernames = append(usernames, name)
+		}
+	}
+	return usernames, room
+}
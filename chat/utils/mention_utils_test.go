@@ -0,0 +1,35 @@
+package utils_test
+
+import (
+	"testing"
+
+	"example.com/m/chat/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		usernames []string
+		room      bool
+	}{
+		{"no mentions", "hello world", nil, false},
+		{"single user", "hi @alice", []string{"alice"}, false},
+		{"multiple users keep order", "@bob and @alice, see @bob", []string{"bob", "alice"}, false},
+		{"room mention", "@room deploy is done", nil, true},
+		{"room mention case insensitive", "ping @Room", nil, true},
+		{"user and room", "@room @carol please check", []string{"carol"}, true},
+		{"trailing punctuation", "thanks @dave.", []string{"dave"}, false},
+		{"email is not a mention", "mail me at eve@example.com", nil, false},
+		{"double at is ignored", "@@frank", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usernames, room := utils.ParseMentions(tt.content)
+			assert.Equal(t, tt.usernames, usernames)
+			assert.Equal(t, tt.room, room)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SignatureHeader 是出站 Webhook 簽名所在的請求頭
+const SignatureHeader = "X-Chat-Signature"
+
+// RetryPolicy 定義 Webhook 投遞的重試策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最大嘗試次數（包含第一次）
+	BaseDelay   time.Duration // 第一次重試前的等待時間，之後每次翻倍
+	MaxDelay    time.Duration // 單次等待時間上限
+}
+
+// DefaultRetryPolicy 是出站 Webhook 默認的重試策略
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+var (
+	ErrInvalidWebhookURL = errors.New("webhook URL must be an absolute http or https URL")
+	ErrForbiddenAddress  = errors.New("webhook target is a private, loopback or link-local address")
+)
+
+// 運營商級 NAT 的共享地址段，與私有地址一樣不應從外部訪問
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddr 判斷地址是否可以作為出站 Webhook 的目標
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// ValidateWebhookURL 在創建 Webhook 時檢查 URL，提前拒絕明顯指向內網的地址
+// 域名解析後的地址由 NewWebhookClient 在撥號時檢查
+func ValidateWebhookURL(raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return ErrInvalidWebhookURL
+	}
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !IsPublicAddr(addr) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// NewWebhookClient 返回只連接公網地址的 HTTP 客戶端
+// 地址在域名解析後、建立連接前檢查，重定向與 DNS 重綁定也無法繞過；不使用代理
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !IsPublicAddr(addr) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Backoff 返回第 attempt 次嘗試失敗後應等待的時間
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// DeliveryAttempt 記錄一次投遞嘗試的結果
+type DeliveryAttempt struct {
+	Attempt    int
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+}
+
+// Success 表示該次嘗試是否成功
+func (a DeliveryAttempt) Success() bool {
+	return a.Err == nil && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// SignPayload 使用 HMAC-SHA256 對請求內容簽名，格式為 "sha256=<hex>"
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature 校驗簽名是否與請求內容一致
+func VerifySignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignPayload(secret, body)), []byte(signature))
+}
+
+// DeliverWebhook 將已簽名的 JSON 投遞到指定 URL，失敗時按策略重試
+// 每次嘗試都會回調 onAttempt，用於記錄投遞日誌
+func DeliverWebhook(ctx context.Context, client *http.Client, url, secret string, headers map[string]string, body []byte, policy RetryPolicy, onAttempt func(DeliveryAttempt)) error {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	signature := SignPayload(secret, body)
+
+	var lastErr error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result := postWebhook(ctx, client, url, signature, headers, body)
+		result.Attempt = attempt
+		if onAttempt != nil {
+			onAttempt(result)
+		}
+		if result.Success() {
+			return nil
+		}
+
+		if result.Err != nil {
+			lastErr = result.Err
+			// 被禁止的地址重試也不會成功
+			if errors.Is(result.Err, ErrForbiddenAddress) {
+				return lastErr
+			}
+		} else {
+			lastErr = fmt.Errorf("unexpected status code %d", result.StatusCode)
+			// 除 429 外的 4xx 屬於永久性錯誤，不再重試
+			if result.StatusCode >= 400 && result.StatusCode < 500 && result.StatusCode != http.StatusTooManyRequests {
+				return lastErr
+			}
+		}
+
+		if attempt == policy.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(policy.Backoff(attempt)):
+		}
+	}
+
+	return fmt.Errorf("webhook delivery failed after %d attempts: %w", policy.MaxAttempts, lastErr)
+}
+
+func postWebhook(ctx context.Context, client *http.Client, url, signature string, headers map[string]string, body []byte) DeliveryAttempt {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return DeliveryAttempt{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return DeliveryAttempt{Err: err, Duration: time.Since(start)}
+	}
+	resp.Body.Close()
+
+	return DeliveryAttempt{StatusCode: resp.StatusCode, Duration: time.Since(start)}
+}
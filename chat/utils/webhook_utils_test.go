@@ -0,0 +1,190 @@
+package utils_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/m/chat/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var fastRetry = utils.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestSignPayload(t *testing.T) {
+	body := []byte(`{"event":"message.created"}`)
+	signature := utils.SignPayload("secret", body)
+
+	assert.Contains(t, signature, "sha256=")
+	assert.True(t, utils.VerifySignature("secret", body, signature))
+	assert.False(t, utils.VerifySignature("other-secret", body, signature))
+	assert.False(t, utils.VerifySignature("secret", []byte(`{}`), signature))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := utils.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	assert.Equal(t, time.Second, policy.Backoff(1))
+	assert.Equal(t, 2*time.Second, policy.Backoff(2))
+	assert.Equal(t, 4*time.Second, policy.Backoff(3))
+	assert.Equal(t, 5*time.Second, policy.Backoff(4))
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	var received []byte
+	var signature, event string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(utils.SignatureHeader)
+		event = r.Header.Get("X-Chat-Event")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	body := []byte(`{"content":"hello"}`)
+	var attempts []utils.DeliveryAttempt
+	err := utils.DeliverWebhook(context.Background(), receiver.Client(), receiver.URL, "secret",
+		map[string]string{"X-Chat-Event": "message.created"}, body, fastRetry,
+		func(a utils.DeliveryAttempt) { attempts = append(attempts, a) })
+
+	assert.NoError(t, err)
+	assert.Equal(t, body, received)
+	assert.Equal(t, "message.created", event)
+	assert.True(t, utils.VerifySignature("secret", body, signature))
+	if assert.Len(t, attempts, 1) {
+		assert.True(t, attempts[0].Success())
+		assert.Equal(t, http.StatusNoContent, attempts[0].StatusCode)
+	}
+}
+
+func TestDeliverWebhookRetry(t *testing.T) {
+	var calls int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 前兩次返回 503，第三次成功
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	var attempts []utils.DeliveryAttempt
+	err := utils.DeliverWebhook(context.Background(), receiver.Client(), receiver.URL, "secret", nil, []byte(`{}`), fastRetry,
+		func(a utils.DeliveryAttempt) { attempts = append(attempts, a) })
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	if assert.Len(t, attempts, 3) {
+		assert.Equal(t, http.StatusServiceUnavailable, attempts[0].StatusCode)
+		assert.Equal(t, 3, attempts[2].Attempt)
+		assert.True(t, attempts[2].Success())
+	}
+}
+
+func TestDeliverWebhookGivesUp(t *testing.T) {
+	var calls int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	err := utils.DeliverWebhook(context.Background(), receiver.Client(), receiver.URL, "secret", nil, []byte(`{}`), fastRetry, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(fastRetry.MaxAttempts), atomic.LoadInt32(&calls))
+}
+
+func TestDeliverWebhookPermanentFailure(t *testing.T) {
+	var calls int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer receiver.Close()
+
+	// 4xx 不應重試
+	err := utils.DeliverWebhook(context.Background(), receiver.Client(), receiver.URL, "secret", nil, []byte(`{}`), fastRetry, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestDeliverWebhookCanceled(t *testing.T) {
+	var calls int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	// 取消後不再等待退避，也不再嘗試
+	ctx, cancel := context.WithCancel(context.Background())
+	slow := utils.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	start := time.Now()
+	err := utils.DeliverWebhook(ctx, receiver.Client(), receiver.URL, "secret", nil, []byte(`{}`), slow,
+		func(utils.DeliveryAttempt) { cancel() })
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Less(t, time.Since(start), time.Minute)
+
+	err = utils.DeliverWebhook(ctx, receiver.Client(), receiver.URL, "secret", nil, []byte(`{}`), slow, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url string
+		err error
+	}{
+		{"https://hooks.example.com/chat", nil},
+		{"http://93.184.216.34:8080/hook", nil},
+		{"ftp://example.com", utils.ErrInvalidWebhookURL},
+		{"not a url", utils.ErrInvalidWebhookURL},
+		{"http://", utils.ErrInvalidWebhookURL},
+		{"http://localhost:8080/hook", utils.ErrForbiddenAddress},
+		{"http://api.localhost/hook", utils.ErrForbiddenAddress},
+		{"http://127.0.0.1/hook", utils.ErrForbiddenAddress},
+		{"http://10.0.0.5/hook", utils.ErrForbiddenAddress},
+		{"http://192.168.1.1/hook", utils.ErrForbiddenAddress},
+		{"http://169.254.169.254/latest/meta-data", utils.ErrForbiddenAddress},
+		{"http://100.64.0.1/hook", utils.ErrForbiddenAddress},
+		{"http://0.0.0.0/hook", utils.ErrForbiddenAddress},
+		{"http://[::1]/hook", utils.ErrForbiddenAddress},
+		{"http://[fe80::1]/hook", utils.ErrForbiddenAddress},
+		{"http://[fd00::1]/hook", utils.ErrForbiddenAddress},
+		{"http://[::ffff:127.0.0.1]/hook", utils.ErrForbiddenAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := utils.ValidateWebhookURL(tt.url)
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	var calls int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer receiver.Close()
+
+	// httptest 監聽回環地址，撥號時即被拒絕，且不重試
+	var attempts int
+	client := utils.NewWebhookClient(time.Second)
+	err := utils.DeliverWebhook(context.Background(), client, receiver.URL, "secret", nil, []byte(`{}`), fastRetry,
+		func(utils.DeliveryAttempt) { attempts++ })
+	assert.ErrorIs(t, err, utils.ErrForbiddenAddress)
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
@@ -22,14 +22,16 @@ func GoroutineBase() {
 		defer wg.Done()
 		time.Sleep(time.Millisecond * time.Duration(100)) // Simulate some delay
 		for {
-			if atomic.LoadInt64(&product.Stock) > 0 {
-				atomic.AddInt64(&product.Stock, -1)
-				fmt.Printf("Customer %d purchased product %d. Remaining stock: %d\n", customerID, product.ID, product.Stock)
-				break
-			} else {
+			stock := atomic.LoadInt64(&product.Stock)
+			if stock <= 0 {
 				fmt.Printf("Customer %d could not purchase product %d. Out of stock.\n", customerID, product.ID)
 				break
 			}
+			// Only take the item if nobody changed the stock since we read it, otherwise retry
+			if atomic.CompareAndSwapInt64(&product.Stock, stock, stock-1) {
+				fmt.Printf("Customer %d purchased product %d. Remaining stock: %d\n", customerID, product.ID, stock-1)
+				break
+			}
 		}
 	}
 
@@ -1,10 +1,13 @@
 package goroutine
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"sync"
 	"time"
+
+	"example.com/m/workerpool"
 )
 
 // Task represents a task with an ID and a priority
@@ -14,85 +17,76 @@ type Task struct {
 	Priority int // Higher number means higher priority
 }
 
-// Producer function generates tasks and sends them to the channel
-func producer(id int, tasks chan<- Task, stop <-chan struct{}) {
+// Producer function generates tasks and submits them to the pool until ctx is done
+func producer(ctx context.Context, id int, pool *workerpool.Pool, wg *sync.WaitGroup) {
+	defer wg.Done()
 	taskID := 1 // Initialize task ID
 	for {
-		select {
-		case <-stop:
+		// Generate a new task with a random priority
+		task := Task{ID: id, taskID: taskID, Priority: rand.Intn(5)} // Random priority between 0 and 4
+		// Submit waits while the queue is full, so fast producers are slowed down.
+		// The task keeps running after production stops, so it does not inherit the cancellation of ctx.
+		_, err := pool.Submit(context.WithoutCancel(ctx), workerpool.Task{
+			Priority: task.Priority,
+			Run:      func(ctx context.Context) error { return processTask(ctx, task) },
+		})
+		if err != nil {
 			fmt.Printf("Producer %d stopping\n", id)
 			return
-		default:
-			// Generate a new task with a random priority
-			task := Task{ID: id, taskID: taskID, Priority: rand.Intn(5)} // Random priority between 0 and 4
-			tasks <- task                                                // Send task to the channel
-			currentTime := time.Now().Format("15:04:05")                 // Get current time
-			fmt.Printf("Producer %d produced task: %v at %s\n", id, task, currentTime)
-			taskID++ // Increment the task ID for the next task
-
-			// Sleep for a random duration to simulate sporadic task generation
-			time.Sleep(time.Millisecond * time.Duration(rand.Intn(1000))) // Random sleep between 0 and 1000 ms
 		}
-	}
-}
+		currentTime := time.Now().Format("15:04:05") // Get current time
+		fmt.Printf("Producer %d produced task: %v at %s\n", id, task, currentTime)
+		taskID++ // Increment the task ID for the next task
 
-// Consumer function processes tasks received from the channel
-func consumer(id int, tasks <-chan Task, results chan<- string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for task := range tasks {
-		go processTask(task, id, results)
+		// Sleep for a random duration to simulate sporadic task generation
+		select {
+		case <-ctx.Done():
+			fmt.Printf("Producer %d stopping\n", id)
+			return
+		case <-time.After(time.Millisecond * time.Duration(rand.Intn(1000))): // Random sleep between 0 and 1000 ms
+		}
 	}
 }
 
 // processTask function simulates processing of a single task
-func processTask(task Task, consumerID int, results chan<- string) {
+func processTask(ctx context.Context, task Task) error {
 	// Simulate processing based on priority
-	time.Sleep(time.Millisecond * time.Duration(2000-task.Priority*500)) // Higher priority takes less time
-	result := fmt.Sprintf("Consumer %d processed task %d and taskID %d with priority %d", consumerID, task.ID, task.taskID, task.Priority)
-	currentTime := time.Now().Format("15:04:05")           // Get current time
-	results <- fmt.Sprintf("%s - %s", currentTime, result) // Include time in results
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Millisecond * time.Duration(2000-task.Priority*500)): // Higher priority takes less time
+	}
+	currentTime := time.Now().Format("15:04:05") // Get current time
+	fmt.Printf("%s - Processed task %d and taskID %d with priority %d\n", currentTime, task.ID, task.taskID, task.Priority)
+	return nil
 }
 
 func GoroutineChannel() {
-	rand.Seed(time.Now().UnixNano()) // Seed random number generator
-	tasks := make(chan Task, 10)     // Buffered channel for tasks
-	results := make(chan string)     // Buffered channel for results
-
-	var wg sync.WaitGroup
+	// Two workers take the highest priority task first; at most 10 tasks wait in the queue
+	pool, err := workerpool.New(workerpool.Options{Name: "goroutine_channel", Workers: 2, QueueSize: 10, TaskTimeout: 5 * time.Second})
+	if err != nil {
+		fmt.Println("Failed to start worker pool:", err)
+		return
+	}
 
-	// Create a stop channel for producers
-	stop := make(chan struct{})
+	// Stop task production after 10 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	// Start producer goroutines
+	var wg sync.WaitGroup
 	numProducers := 3
 	for i := 1; i <= numProducers; i++ {
-		go producer(i, tasks, stop)
-	}
-
-	// Start consumer goroutines
-	numConsumers := 1
-	for i := 1; i <= numConsumers; i++ {
-		wg.Add(1) // Add to wait group for each consumer
-		go consumer(i, tasks, results, &wg)
+		wg.Add(1)
+		go producer(ctx, i, pool, &wg)
 	}
+	wg.Wait()
 
-	// Stop task production after 3 seconds
-	go func() {
-		time.Sleep(10 * time.Second)
-		close(stop)  // Close the stop channel
-		close(tasks) // Close tasks channel after all consumers finish
-	}()
-
-	// Close the results channel when all tasks are processed
-	go func() {
-		wg.Wait()                   // Wait for all consumers to finish
-		time.Sleep(3 * time.Second) // Wait for 3 seconds
-		close(results)              // Close results channel after all tasks are processed
-	}()
-
-	// Print results as they come in
-	for result := range results {
-		fmt.Println(result) // Print results
+	// Let the workers finish the queued tasks, giving up after 30 seconds
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer stopCancel()
+	if err := pool.Stop(stopCtx); err != nil {
+		fmt.Println("Gave up waiting for tasks:", err)
 	}
 
 	fmt.Println("All tasks processed.")
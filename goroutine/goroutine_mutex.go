@@ -1,49 +1,71 @@
 package goroutine
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"sync"
 	"time"
+
+	"example.com/m/ledger"
 )
 
-// BankAccount represents a bank account with a balance
+// BankAccount represents a bank account whose balance is kept in a ledger.
+// The ledger locks the account (a sync.Mutex) for every deposit and withdrawal.
 type BankAccount struct {
-	mu      sync.Mutex // Mutex to protect access to the balance
-	balance int64      // Current balance
+	ledger *ledger.Ledger
+	id     string
+}
+
+// NewBankAccount opens an account in a new ledger with an initial deposit
+func NewBankAccount(balance int64) *BankAccount {
+	account := &BankAccount{ledger: ledger.New(), id: "account"}
+	account.ledger.Open(account.id)
+	if balance > 0 {
+		account.ledger.Deposit(account.id, balance, "opening")
+	}
+	return account
 }
 
 // Deposit adds amount to the account balance
 func (account *BankAccount) Deposit(amount int64) {
-	account.mu.Lock()         // Lock the mutex
-	defer account.mu.Unlock() // Ensure to unlock the mutex
-	account.balance += amount
-	fmt.Printf("Deposited %d. New balance: %d\n", amount, account.balance)
+	tx, err := account.ledger.Deposit(account.id, amount, "")
+	if err != nil {
+		fmt.Printf("Deposit of %d failed: %v\n", amount, err)
+		return
+	}
+	fmt.Printf("Deposited %d. New balance: %d\n", amount, tx.ToBalance)
 }
 
 // Withdraw deducts amount from the account balance
 func (account *BankAccount) Withdraw(amount int64) bool {
-	account.mu.Lock()         // Lock the mutex
-	defer account.mu.Unlock() // Ensure to unlock the mutex
-	if account.balance >= amount {
-		account.balance -= amount
-		fmt.Printf("Withdrew %d. New balance: %d\n", amount, account.balance)
-		return true
+	tx, err := account.ledger.Withdraw(account.id, amount, "")
+	if errors.Is(err, ledger.ErrInsufficientFunds) {
+		fmt.Printf("Withdrawal of %d failed. Insufficient funds: %d\n", amount, account.DisplayBalance())
+		return false
+	} else if err != nil {
+		fmt.Printf("Withdrawal of %d failed: %v\n", amount, err)
+		return false
 	}
-	fmt.Printf("Withdrawal of %d failed. Insufficient funds: %d\n", amount, account.balance)
-	return false
+	fmt.Printf("Withdrew %d. New balance: %d\n", amount, tx.FromBalance)
+	return true
 }
 
 // DisplayBalance shows the current balance
 func (account *BankAccount) DisplayBalance() int64 {
-	account.mu.Lock()         // Lock the mutex
-	defer account.mu.Unlock() // Ensure to unlock the mutex
-	return account.balance
+	balance, _ := account.ledger.Balance(account.id)
+	return balance
+}
+
+// History returns every deposit and withdrawal so far
+func (account *BankAccount) History() []ledger.Entry {
+	statement, _ := account.ledger.Statement(account.id, time.Time{}, time.Time{})
+	return statement.Entries
 }
 
 func GoroutineMutex() {
-	rand.Seed(time.Now().UnixNano())        // Set seed for random number generator
-	account := &BankAccount{balance: 10000} // Initial balance of 10000
+	rand.Seed(time.Now().UnixNano()) // Set seed for random number generator
+	account := NewBankAccount(10000) // Initial balance of 10000
 	var wg sync.WaitGroup
 
 	// Define a larger number of deposit and withdrawal operations
@@ -83,4 +105,5 @@ func GoroutineMutex() {
 
 	wg.Wait()                                                   // Wait for all goroutines to finish
 	fmt.Printf("Final balance: %d\n", account.DisplayBalance()) // Display final balance
+	fmt.Printf("Transactions recorded: %d\n", len(account.History()))
 }
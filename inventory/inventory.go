@@ -0,0 +1,254 @@
+// Package inventory reserves product stock for buyers without ever overselling it.
+//
+// A purchase is two steps: Reserve holds stock for a limited time, then Commit
+// removes it from the inventory or Release hands it back. Reservations that are
+// neither committed nor released expire after their TTL and their stock becomes
+// available again.
+package inventory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is used when Reserve is called with a TTL of zero or less
+const DefaultTTL = 15 * time.Minute
+
+var (
+	ErrProductNotFound     = errors.New("product not found")
+	ErrInsufficientStock   = errors.New("insufficient stock")
+	ErrReservationNotFound = errors.New("reservation not found or expired")
+	ErrInvalidQuantity     = errors.New("quantity must be positive")
+)
+
+// Product is the stock level of a single product
+type Product struct {
+	ID       int64
+	Stock    int64 // Units on hand, including reserved ones
+	Reserved int64 // Units held by active reservations
+}
+
+// Available returns the number of units that can still be reserved
+func (p Product) Available() int64 {
+	return p.Stock - p.Reserved
+}
+
+// Item is a quantity of one product
+type Item struct {
+	ProductID int64
+	Quantity  int64
+}
+
+// Reservation holds stock for one or more products until ExpiresAt
+type Reservation struct {
+	ID        string
+	Items     []Item
+	ExpiresAt time.Time
+}
+
+// Store keeps stock levels and reservations. All methods are safe for concurrent use.
+type Store interface {
+	// AddStock adds quantity units of a product, creating the product if needed
+	AddStock(ctx context.Context, productID, quantity int64) error
+	Product(ctx context.Context, productID int64) (Product, error)
+	// Reserve holds every item or none of them. It returns ErrInsufficientStock
+	// if any product does not have enough available units.
+	Reserve(ctx context.Context, items []Item, ttl time.Duration) (Reservation, error)
+	// Commit removes the reserved units from the stock
+	Commit(ctx context.Context, reservationID string) error
+	// Release returns the reserved units. An expired reservation can still be released
+	// until ReleaseExpired or a later Reserve sweeps it; after that, as for a reservation
+	// that was already committed or released, it returns ErrReservationNotFound.
+	Release(ctx context.Context, reservationID string) error
+	// ReleaseExpired releases all expired reservations and returns how many there were
+	ReleaseExpired(ctx context.Context) (int, error)
+}
+
+// RunExpiry calls store.ReleaseExpired every interval until ctx is cancelled.
+// Stores release expired reservations on demand as well, so this only keeps
+// Product.Reserved accurate for products nobody is buying.
+func RunExpiry(ctx context.Context, store Store, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := store.ReleaseExpired(ctx); err != nil && ctx.Err() == nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// normalizeItems merges items of the same product and sorts them by product ID,
+// which is also the order in which stores lock products
+func normalizeItems(items []Item) ([]Item, error) {
+	if len(items) == 0 {
+		return nil, ErrInvalidQuantity
+	}
+	quantities := make(map[int64]int64, len(items))
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, ErrInvalidQuantity
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+	merged := make([]Item, 0, len(quantities))
+	for id, quantity := range quantities {
+		merged = append(merged, Item{ProductID: id, Quantity: quantity})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].ProductID < merged[j].ProductID })
+	return merged, nil
+}
+
+func newReservationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// MemoryStore keeps the inventory in memory behind a single mutex
+type MemoryStore struct {
+	now func() time.Time
+
+	mu           sync.Mutex
+	products     map[int64]*Product
+	reservations map[string]Reservation
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:          time.Now,
+		products:     make(map[int64]*Product),
+		reservations: make(map[string]Reservation),
+	}
+}
+
+func (s *MemoryStore) AddStock(ctx context.Context, productID, quantity int64) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.products[productID]
+	if !ok {
+		p = &Product{ID: productID}
+		s.products[productID] = p
+	}
+	p.Stock += quantity
+	return nil
+}
+
+func (s *MemoryStore) Product(ctx context.Context, productID int64) (Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.products[productID]
+	if !ok {
+		return Product{}, ErrProductNotFound
+	}
+	return *p, nil
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, items []Item, ttl time.Duration) (Reservation, error) {
+	items, err := normalizeItems(items)
+	if err != nil {
+		return Reservation{}, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, item := range items {
+		if _, ok := s.products[item.ProductID]; !ok {
+			return Reservation{}, ErrProductNotFound
+		}
+	}
+	if !s.available(items) {
+		// Expired reservations still count as reserved until they are released
+		s.releaseExpired(now)
+		if !s.available(items) {
+			return Reservation{}, ErrInsufficientStock
+		}
+	}
+
+	for _, item := range items {
+		s.products[item.ProductID].Reserved += item.Quantity
+	}
+	r := Reservation{ID: newReservationID(), Items: items, ExpiresAt: now.Add(ttl)}
+	s.reservations[r.ID] = r
+	return r, nil
+}
+
+func (s *MemoryStore) available(items []Item) bool {
+	for _, item := range items {
+		if s.products[item.ProductID].Available() < item.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MemoryStore) Commit(ctx context.Context, reservationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.reservations[reservationID]
+	if !ok || !s.now().Before(r.ExpiresAt) {
+		return ErrReservationNotFound
+	}
+	delete(s.reservations, reservationID)
+	for _, item := range r.Items {
+		p := s.products[item.ProductID]
+		p.Stock -= item.Quantity
+		p.Reserved -= item.Quantity
+	}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, reservationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.reservations[reservationID]
+	if !ok {
+		return ErrReservationNotFound
+	}
+	s.release(r)
+	return nil
+}
+
+func (s *MemoryStore) ReleaseExpired(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.releaseExpired(s.now()), nil
+}
+
+func (s *MemoryStore) releaseExpired(now time.Time) int {
+	n := 0
+	for _, r := range s.reservations {
+		if !now.Before(r.ExpiresAt) {
+			s.release(r)
+			n++
+		}
+	}
+	return n
+}
+
+func (s *MemoryStore) release(r Reservation) {
+	delete(s.reservations, r.ID)
+	for _, item := range r.Items {
+		s.products[item.ProductID].Reserved -= item.Quantity
+	}
+}
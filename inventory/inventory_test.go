@@ -0,0 +1,216 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClock is a manually advanced clock shared by the store and the test
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestMemoryStore(t *testing.T) (Store, *testClock) {
+	clock := newTestClock()
+	s := NewMemoryStore()
+	s.now = clock.Now
+	return s, clock
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, newTestMemoryStore)
+}
+
+func TestMemoryStoreNoOversell(t *testing.T) {
+	testNoOversell(t, newTestMemoryStore, 5000)
+}
+
+func assertProduct(t *testing.T, s Store, id, stock, reserved int64) {
+	t.Helper()
+	p, err := s.Product(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, Product{ID: id, Stock: stock, Reserved: reserved}, p)
+}
+
+// testStore checks the behaviour every Store implementation must have
+func testStore(t *testing.T, newStore func(t *testing.T) (Store, *testClock)) {
+	ctx := context.Background()
+
+	t.Run("ReserveCommitRelease", func(t *testing.T) {
+		s, _ := newStore(t)
+		require.NoError(t, s.AddStock(ctx, 1, 10))
+		require.NoError(t, s.AddStock(ctx, 2, 5))
+
+		r, err := s.Reserve(ctx, []Item{{1, 3}, {2, 1}, {1, 1}}, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, []Item{{1, 4}, {2, 1}}, r.Items)
+		assertProduct(t, s, 1, 10, 4)
+		assertProduct(t, s, 2, 5, 1)
+
+		require.NoError(t, s.Commit(ctx, r.ID))
+		assertProduct(t, s, 1, 6, 0)
+		assertProduct(t, s, 2, 4, 0)
+		assert.ErrorIs(t, s.Commit(ctx, r.ID), ErrReservationNotFound)
+		assert.ErrorIs(t, s.Release(ctx, r.ID), ErrReservationNotFound)
+
+		r, err = s.Reserve(ctx, []Item{{2, 4}}, time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, s.Release(ctx, r.ID))
+		assertProduct(t, s, 2, 4, 0)
+		assert.ErrorIs(t, s.Commit(ctx, r.ID), ErrReservationNotFound)
+	})
+
+	t.Run("AllOrNothing", func(t *testing.T) {
+		s, _ := newStore(t)
+		require.NoError(t, s.AddStock(ctx, 1, 10))
+		require.NoError(t, s.AddStock(ctx, 2, 1))
+
+		_, err := s.Reserve(ctx, []Item{{1, 5}, {2, 2}}, time.Minute)
+		assert.ErrorIs(t, err, ErrInsufficientStock)
+		_, err = s.Reserve(ctx, []Item{{1, 5}, {3, 1}}, time.Minute)
+		assert.ErrorIs(t, err, ErrProductNotFound)
+		assertProduct(t, s, 1, 10, 0)
+		assertProduct(t, s, 2, 1, 0)
+
+		_, err = s.Reserve(ctx, []Item{{1, 0}}, time.Minute)
+		assert.ErrorIs(t, err, ErrInvalidQuantity)
+		_, err = s.Reserve(ctx, nil, time.Minute)
+		assert.ErrorIs(t, err, ErrInvalidQuantity)
+		assert.ErrorIs(t, s.AddStock(ctx, 1, -1), ErrInvalidQuantity)
+		_, err = s.Product(ctx, 3)
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		s, clock := newStore(t)
+		require.NoError(t, s.AddStock(ctx, 1, 2))
+		r, err := s.Reserve(ctx, []Item{{1, 2}}, time.Minute)
+		require.NoError(t, err)
+		_, err = s.Reserve(ctx, []Item{{1, 1}}, time.Minute)
+		assert.ErrorIs(t, err, ErrInsufficientStock)
+
+		// An expired reservation cannot be committed and its stock can be reserved again
+		clock.Advance(time.Minute)
+		assert.ErrorIs(t, s.Commit(ctx, r.ID), ErrReservationNotFound)
+		r2, err := s.Reserve(ctx, []Item{{1, 2}}, time.Minute)
+		require.NoError(t, err)
+		assertProduct(t, s, 1, 2, 2)
+		assert.ErrorIs(t, s.Release(ctx, r.ID), ErrReservationNotFound)
+
+		clock.Advance(2 * time.Minute)
+		n, err := s.ReleaseExpired(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assertProduct(t, s, 1, 2, 0)
+		assert.ErrorIs(t, s.Release(ctx, r2.ID), ErrReservationNotFound)
+	})
+
+	t.Run("ReleaseExpired", func(t *testing.T) {
+		s, clock := newStore(t)
+		require.NoError(t, s.AddStock(ctx, 1, 2))
+		r, err := s.Reserve(ctx, []Item{{1, 2}}, time.Minute)
+		require.NoError(t, err)
+
+		// Expired but not yet swept: releasing it frees the units
+		clock.Advance(time.Minute)
+		require.NoError(t, s.Release(ctx, r.ID))
+		assertProduct(t, s, 1, 2, 0)
+
+		// Once swept the reservation is gone
+		r, err = s.Reserve(ctx, []Item{{1, 1}}, time.Minute)
+		require.NoError(t, err)
+		clock.Advance(time.Minute)
+		_, err = s.ReleaseExpired(ctx)
+		require.NoError(t, err)
+		assert.ErrorIs(t, s.Release(ctx, r.ID), ErrReservationNotFound)
+		assertProduct(t, s, 1, 2, 0)
+	})
+}
+
+// testNoOversell lets buyers compete for a small stock of several products. Every
+// buyer reserves one unit of one or two products and then commits or releases it.
+// Units sold plus units left must always equal the initial stock.
+func testNoOversell(t *testing.T, newStore func(t *testing.T) (Store, *testClock), buyers int) {
+	ctx := context.Background()
+	s, _ := newStore(t)
+	const products, stock = 3, 50
+	for id := int64(1); id <= products; id++ {
+		require.NoError(t, s.AddStock(ctx, id, stock))
+	}
+
+	var sold [products + 1]atomic.Int64
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, buyers)
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			items := []Item{{ProductID: int64(i%products + 1), Quantity: 1}}
+			if i%4 == 0 {
+				items = append(items, Item{ProductID: int64((i+1)%products + 1), Quantity: 1})
+			}
+			r, err := s.Reserve(ctx, items, time.Minute)
+			if errors.Is(err, ErrInsufficientStock) {
+				return
+			} else if err != nil {
+				errs <- err
+				return
+			}
+			// Some buyers change their mind, leaving stock for later buyers
+			if i%3 == 0 {
+				if err := s.Release(ctx, r.ID); err != nil {
+					errs <- err
+				}
+				return
+			}
+			if err := s.Commit(ctx, r.ID); err != nil {
+				errs <- err
+				return
+			}
+			for _, item := range r.Items {
+				sold[item.ProductID].Add(item.Quantity)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for id := int64(1); id <= products; id++ {
+		p, err := s.Product(ctx, id)
+		require.NoError(t, err)
+		assert.Zero(t, p.Reserved, "product %d", id)
+		assert.GreaterOrEqual(t, p.Stock, int64(0), "product %d", id)
+		assert.Equal(t, int64(stock), sold[id].Load()+p.Stock, "product %d", id)
+		assert.Positive(t, sold[id].Load(), "product %d", id)
+	}
+}
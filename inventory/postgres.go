@@ -0,0 +1,304 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresStore keeps the inventory in PostgreSQL, so reservations survive restarts
+// and can be shared by several processes.
+//
+// Every transaction locks the product rows it changes with SELECT ... FOR UPDATE,
+// always in product ID order, before touching reservations, so concurrent buyers
+// queue up on the rows instead of deadlocking. The CHECK constraint on
+// inventory_products rejects any update that would oversell regardless.
+type PostgresStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db, now: time.Now}
+}
+
+const tablesSQL = `
+CREATE TABLE IF NOT EXISTS inventory_products (
+	id BIGINT PRIMARY KEY,
+	stock BIGINT NOT NULL DEFAULT 0,
+	reserved BIGINT NOT NULL DEFAULT 0,
+	CHECK (reserved >= 0 AND reserved <= stock)
+);
+CREATE TABLE IF NOT EXISTS inventory_reservations (
+	id CHAR(32) NOT NULL,
+	product_id BIGINT NOT NULL REFERENCES inventory_products (id),
+	quantity BIGINT NOT NULL CHECK (quantity > 0),
+	expires_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (id, product_id)
+);
+CREATE INDEX IF NOT EXISTS inventory_reservations_expires_at_idx ON inventory_reservations (product_id, expires_at)`
+
+// CreateTables creates the inventory_products and inventory_reservations tables
+func (s *PostgresStore) CreateTables(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, tablesSQL)
+	return err
+}
+
+func (s *PostgresStore) AddStock(ctx context.Context, productID, quantity int64) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO inventory_products (id, stock) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET stock = inventory_products.stock + EXCLUDED.stock`,
+		productID, quantity)
+	return err
+}
+
+func (s *PostgresStore) Product(ctx context.Context, productID int64) (Product, error) {
+	p := Product{ID: productID}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT stock, reserved FROM inventory_products WHERE id = $1", productID,
+	).Scan(&p.Stock, &p.Reserved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Product{}, ErrProductNotFound
+	}
+	return p, err
+}
+
+func (s *PostgresStore) Reserve(ctx context.Context, items []Item, ttl time.Duration) (Reservation, error) {
+	items, err := normalizeItems(items)
+	if err != nil {
+		return Reservation{}, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.ProductID
+	}
+
+	r := Reservation{ID: newReservationID(), Items: items}
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		products, err := lockProducts(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+		if len(products) != len(ids) {
+			return ErrProductNotFound
+		}
+		now := s.now()
+		if _, err := releaseExpired(ctx, tx, products, ids, now); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if products[item.ProductID].Available() < item.Quantity {
+				return ErrInsufficientStock
+			}
+		}
+
+		r.ExpiresAt = now.Add(ttl)
+		for _, item := range items {
+			if _, err := tx.ExecContext(ctx,
+				"UPDATE inventory_products SET reserved = reserved + $2 WHERE id = $1",
+				item.ProductID, item.Quantity); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO inventory_reservations (id, product_id, quantity, expires_at) VALUES ($1, $2, $3, $4)",
+				r.ID, item.ProductID, item.Quantity, r.ExpiresAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+	return r, nil
+}
+
+func (s *PostgresStore) Commit(ctx context.Context, reservationID string) error {
+	return s.finish(ctx, reservationID, true)
+}
+
+func (s *PostgresStore) Release(ctx context.Context, reservationID string) error {
+	return s.finish(ctx, reservationID, false)
+}
+
+// finish deletes a reservation and either removes its units from the stock (commit)
+// or only from the reserved count (release). Expired reservations can be released
+// but not committed.
+func (s *PostgresStore) finish(ctx context.Context, reservationID string, commit bool) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		// Lock the products first to keep the same lock order as Reserve
+		rows, err := tx.QueryContext(ctx,
+			"SELECT product_id FROM inventory_reservations WHERE id = $1 ORDER BY product_id", reservationID)
+		if err != nil {
+			return err
+		}
+		ids, err := scanIDs(rows)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return ErrReservationNotFound
+		}
+		if _, err := lockProducts(ctx, tx, ids); err != nil {
+			return err
+		}
+
+		query := "DELETE FROM inventory_reservations WHERE id = $1 RETURNING product_id, quantity"
+		args := []any{reservationID}
+		if commit {
+			query = "DELETE FROM inventory_reservations WHERE id = $1 AND expires_at > $2 RETURNING product_id, quantity"
+			args = append(args, s.now())
+		}
+		freed, err := deleteReservations(ctx, tx, query, args...)
+		if err != nil {
+			return err
+		}
+		if len(freed) == 0 {
+			return ErrReservationNotFound
+		}
+
+		update := "UPDATE inventory_products SET reserved = reserved - $2 WHERE id = $1"
+		if commit {
+			update = "UPDATE inventory_products SET stock = stock - $2, reserved = reserved - $2 WHERE id = $1"
+		}
+		for id, quantity := range freed {
+			if _, err := tx.ExecContext(ctx, update, id, quantity); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *PostgresStore) ReleaseExpired(ctx context.Context) (int, error) {
+	var n int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		now := s.now()
+		rows, err := tx.QueryContext(ctx,
+			"SELECT DISTINCT product_id FROM inventory_reservations WHERE expires_at <= $1 ORDER BY product_id", now)
+		if err != nil {
+			return err
+		}
+		ids, err := scanIDs(rows)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		products, err := lockProducts(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+		n, err = releaseExpired(ctx, tx, products, ids, now)
+		return err
+	})
+	return n, err
+}
+
+// inTx runs fn in a transaction and commits it if fn returns nil
+func (s *PostgresStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockProducts locks the given products in ID order and returns those that exist
+func lockProducts(ctx context.Context, tx *sql.Tx, ids []int64) (map[int64]*Product, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, stock, reserved FROM inventory_products WHERE id = ANY($1) ORDER BY id FOR UPDATE",
+		pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	products := make(map[int64]*Product, len(ids))
+	for rows.Next() {
+		p := &Product{}
+		if err := rows.Scan(&p.ID, &p.Stock, &p.Reserved); err != nil {
+			return nil, err
+		}
+		products[p.ID] = p
+	}
+	return products, rows.Err()
+}
+
+// releaseExpired releases the expired reservations of the locked products, updates
+// products to match and returns the number of reservations released
+func releaseExpired(ctx context.Context, tx *sql.Tx, products map[int64]*Product, ids []int64, now time.Time) (int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM inventory_reservations WHERE product_id = ANY($1) AND expires_at <= $2
+		RETURNING id, product_id, quantity`, pq.Array(ids), now)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	released := make(map[string]bool)
+	freed := make(map[int64]int64)
+	for rows.Next() {
+		var id string
+		var productID, quantity int64
+		if err := rows.Scan(&id, &productID, &quantity); err != nil {
+			return 0, err
+		}
+		released[id] = true
+		freed[productID] += quantity
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, quantity := range freed {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE inventory_products SET reserved = reserved - $2 WHERE id = $1", id, quantity); err != nil {
+			return 0, err
+		}
+		products[id].Reserved -= quantity
+	}
+	return len(released), nil
+}
+
+// deleteReservations runs a DELETE ... RETURNING product_id, quantity and sums the quantities per product
+func deleteReservations(ctx context.Context, tx *sql.Tx, query string, args ...any) (map[int64]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	freed := make(map[int64]int64)
+	for rows.Next() {
+		var productID, quantity int64
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, err
+		}
+		freed[productID] += quantity
+	}
+	return freed, rows.Err()
+}
+
+func scanIDs(rows *sql.Rows) ([]int64, error) {
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,42 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPostgresStore connects to INVENTORY_DATABASE_URL and empties the inventory
+// tables. The tests are skipped when it is not set.
+func newTestPostgresStore(t *testing.T) (Store, *testClock) {
+	url := os.Getenv("INVENTORY_DATABASE_URL")
+	if url == "" {
+		t.Skip("INVENTORY_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", url)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(20)
+
+	s := NewPostgresStore(db)
+	ctx := context.Background()
+	require.NoError(t, s.CreateTables(ctx))
+	_, err = db.ExecContext(ctx, "TRUNCATE inventory_reservations, inventory_products")
+	require.NoError(t, err)
+
+	clock := newTestClock()
+	s.now = clock.Now
+	return s, clock
+}
+
+func TestPostgresStore(t *testing.T) {
+	testStore(t, newTestPostgresStore)
+}
+
+func TestPostgresStoreNoOversell(t *testing.T) {
+	testNoOversell(t, newTestPostgresStore, 1000)
+}
@@ -0,0 +1,286 @@
+// Package ledger keeps account balances as an append-only, double-entry record of
+// every transaction.
+//
+// Money never appears or disappears: each transaction debits one account and
+// credits another by the same amount, so the balances of all accounts, including
+// External, always sum to zero. Deposits and withdrawals are transfers from and to
+// External, the only account that may go negative. Amounts are integers in the
+// smallest currency unit.
+package ledger
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// External stands for money entering or leaving the ledger
+const External = "@external"
+
+var (
+	ErrAccountNotFound     = errors.New("account not found")
+	ErrAccountExists       = errors.New("account already exists")
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrInvalidAmount       = errors.New("amount must be positive")
+	ErrSameAccount         = errors.New("cannot transfer to the same account")
+	ErrIdempotencyConflict = errors.New("idempotency key reused with a different request")
+)
+
+// Transaction moves Amount from one account to another
+type Transaction struct {
+	ID     int64
+	Key    string // Idempotency key, empty if none was given
+	From   string
+	To     string
+	Amount int64
+	Time   time.Time
+
+	FromBalance int64 // Balance of From after the transaction
+	ToBalance   int64 // Balance of To after the transaction
+}
+
+// Entry is one side of a transaction as seen by a single account. Entries are never changed.
+type Entry struct {
+	TransactionID int64
+	Account       string
+	Counterparty  string
+	Amount        int64 // Negative for debits, positive for credits
+	Balance       int64 // Balance of Account after this entry
+	Time          time.Time
+}
+
+// Statement lists the entries of an account in [From, To)
+type Statement struct {
+	Account string
+	From    time.Time
+	To      time.Time
+	Opening int64 // Balance before the first entry
+	Closing int64 // Balance after the last entry
+	Entries []Entry
+}
+
+// Snapshot is a consistent view of all balances at one moment
+type Snapshot struct {
+	Time     time.Time
+	Balances map[string]int64
+}
+
+type account struct {
+	id string
+
+	mu      sync.Mutex
+	balance int64
+	entries []Entry // Ordered by time
+}
+
+// idempotent is the outcome of a request with an idempotency key. done is closed
+// once tx and err are set.
+type idempotent struct {
+	from, to string
+	amount   int64
+
+	done chan struct{}
+	tx   Transaction
+	err  error
+}
+
+// Ledger is safe for concurrent use. Transfers lock only the two accounts involved,
+// always in ID order, so transfers in opposite directions cannot deadlock.
+type Ledger struct {
+	now    func() time.Time
+	nextID atomic.Int64
+
+	mu       sync.RWMutex
+	accounts map[string]*account
+
+	keysMu sync.Mutex
+	keys   map[string]*idempotent
+}
+
+func New() *Ledger {
+	return &Ledger{
+		now:      time.Now,
+		accounts: map[string]*account{External: {id: External}},
+		keys:     make(map[string]*idempotent),
+	}
+}
+
+// Open creates an account with a zero balance
+func (l *Ledger) Open(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.accounts[id]; ok {
+		return ErrAccountExists
+	}
+	l.accounts[id] = &account{id: id}
+	return nil
+}
+
+func (l *Ledger) account(id string) (*account, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	a, ok := l.accounts[id]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+	return a, nil
+}
+
+// Deposit moves amount from External into the account
+func (l *Ledger) Deposit(id string, amount int64, key string) (Transaction, error) {
+	return l.Transfer(External, id, amount, key)
+}
+
+// Withdraw moves amount from the account to External
+func (l *Ledger) Withdraw(id string, amount int64, key string) (Transaction, error) {
+	return l.Transfer(id, External, amount, key)
+}
+
+// Transfer moves amount from one account to another.
+//
+// A non-empty key makes the transfer idempotent: repeating it with the same key
+// returns the original transaction instead of moving the money again, and reusing
+// the key for a different transfer returns ErrIdempotencyConflict. Failed transfers
+// do not keep their key, so they can be retried.
+func (l *Ledger) Transfer(from, to string, amount int64, key string) (Transaction, error) {
+	if key == "" {
+		return l.transfer(from, to, amount, "")
+	}
+
+	l.keysMu.Lock()
+	if prev, ok := l.keys[key]; ok {
+		l.keysMu.Unlock()
+		if prev.from != from || prev.to != to || prev.amount != amount {
+			return Transaction{}, ErrIdempotencyConflict
+		}
+		<-prev.done
+		if prev.err == nil {
+			return prev.tx, nil
+		}
+		// The earlier attempt failed and gave up the key, so try again
+		return l.Transfer(from, to, amount, key)
+	}
+	req := &idempotent{from: from, to: to, amount: amount, done: make(chan struct{})}
+	l.keys[key] = req
+	l.keysMu.Unlock()
+
+	req.tx, req.err = l.transfer(from, to, amount, key)
+	if req.err != nil {
+		l.keysMu.Lock()
+		delete(l.keys, key)
+		l.keysMu.Unlock()
+	}
+	close(req.done)
+	return req.tx, req.err
+}
+
+func (l *Ledger) transfer(from, to string, amount int64, key string) (Transaction, error) {
+	if amount <= 0 {
+		return Transaction{}, ErrInvalidAmount
+	}
+	if from == to {
+		return Transaction{}, ErrSameAccount
+	}
+	src, err := l.account(from)
+	if err != nil {
+		return Transaction{}, err
+	}
+	dst, err := l.account(to)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	first, second := src, dst
+	if first.id > second.id {
+		first, second = second, first
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	if src.id != External && src.balance < amount {
+		return Transaction{}, ErrInsufficientFunds
+	}
+	tx := Transaction{ID: l.nextID.Add(1), Key: key, From: from, To: to, Amount: amount, Time: l.now()}
+	tx.FromBalance = src.append(tx, to, -amount)
+	tx.ToBalance = dst.append(tx, from, amount)
+	return tx, nil
+}
+
+// append records an entry and returns the new balance. The caller holds a.mu.
+func (a *account) append(tx Transaction, counterparty string, amount int64) int64 {
+	a.balance += amount
+	a.entries = append(a.entries, Entry{
+		TransactionID: tx.ID,
+		Account:       a.id,
+		Counterparty:  counterparty,
+		Amount:        amount,
+		Balance:       a.balance,
+		Time:          tx.Time,
+	})
+	return a.balance
+}
+
+// Balance returns the current balance of an account
+func (l *Ledger) Balance(id string) (int64, error) {
+	a, err := l.account(id)
+	if err != nil {
+		return 0, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.balance, nil
+}
+
+// Statement returns the entries of an account with from <= Time < to. A zero to
+// means no upper bound.
+func (l *Ledger) Statement(id string, from, to time.Time) (Statement, error) {
+	a, err := l.account(id)
+	if err != nil {
+		return Statement{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	start := sort.Search(len(a.entries), func(i int) bool { return !a.entries[i].Time.Before(from) })
+	end := len(a.entries)
+	if !to.IsZero() {
+		end = max(start, sort.Search(len(a.entries), func(i int) bool { return !a.entries[i].Time.Before(to) }))
+	}
+	s := Statement{Account: id, From: from, To: to, Entries: append([]Entry(nil), a.entries[start:end]...)}
+	if start > 0 {
+		s.Opening = a.entries[start-1].Balance
+	}
+	s.Closing = s.Opening
+	if end > start {
+		s.Closing = a.entries[end-1].Balance
+	}
+	return s, nil
+}
+
+// Snapshot returns the balances of all accounts at a single point in time. It briefly
+// locks every account, in the same order as transfers do.
+func (l *Ledger) Snapshot() Snapshot {
+	l.mu.RLock()
+	accounts := make([]*account, 0, len(l.accounts))
+	for _, a := range l.accounts {
+		accounts = append(accounts, a)
+	}
+	l.mu.RUnlock()
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].id < accounts[j].id })
+
+	for _, a := range accounts {
+		a.mu.Lock()
+	}
+	s := Snapshot{Time: l.now(), Balances: make(map[string]int64, len(accounts))}
+	for _, a := range accounts {
+		s.Balances[a.id] = a.balance
+	}
+	for _, a := range accounts {
+		a.mu.Unlock()
+	}
+	return s
+}
@@ -0,0 +1,221 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLedger(t *testing.T, accounts ...string) *Ledger {
+	t.Helper()
+	l := New()
+	for _, id := range accounts {
+		require.NoError(t, l.Open(id))
+	}
+	return l
+}
+
+func assertBalance(t *testing.T, l *Ledger, id string, want int64) {
+	t.Helper()
+	got, err := l.Balance(id)
+	require.NoError(t, err)
+	assert.Equal(t, want, got, id)
+}
+
+func TestTransfer(t *testing.T) {
+	l := newTestLedger(t, "alice", "bob")
+	assert.ErrorIs(t, l.Open("alice"), ErrAccountExists)
+
+	tx, err := l.Deposit("alice", 100, "")
+	require.NoError(t, err)
+	assert.Equal(t, Transaction{ID: 1, From: External, To: "alice", Amount: 100, Time: tx.Time, FromBalance: -100, ToBalance: 100}, tx)
+
+	tx, err = l.Transfer("alice", "bob", 30, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(70), tx.FromBalance)
+	assert.Equal(t, int64(30), tx.ToBalance)
+
+	_, err = l.Transfer("bob", "alice", 31, "")
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	_, err = l.Withdraw("bob", 0, "")
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = l.Transfer("bob", "bob", 1, "")
+	assert.ErrorIs(t, err, ErrSameAccount)
+	_, err = l.Transfer("bob", "carol", 1, "")
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+
+	_, err = l.Withdraw("bob", 30, "")
+	require.NoError(t, err)
+	assertBalance(t, l, "alice", 70)
+	assertBalance(t, l, "bob", 0)
+	assertBalance(t, l, External, -70)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	l := newTestLedger(t, "alice", "bob")
+	first, err := l.Deposit("alice", 100, "deposit-1")
+	require.NoError(t, err)
+	again, err := l.Deposit("alice", 100, "deposit-1")
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+	assertBalance(t, l, "alice", 100)
+
+	_, err = l.Deposit("alice", 50, "deposit-1")
+	assert.ErrorIs(t, err, ErrIdempotencyConflict)
+
+	// A failed transfer does not use up its key
+	_, err = l.Transfer("bob", "alice", 10, "refund-1")
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	_, err = l.Deposit("bob", 10, "")
+	require.NoError(t, err)
+	_, err = l.Transfer("bob", "alice", 10, "refund-1")
+	require.NoError(t, err)
+	assertBalance(t, l, "alice", 110)
+
+	// Concurrent retries of the same request move the money once
+	var wg sync.WaitGroup
+	ids := make([]int64, 20)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tx, err := l.Transfer("alice", "bob", 5, "pay-1")
+			assert.NoError(t, err)
+			ids[i] = tx.ID
+		}(i)
+	}
+	wg.Wait()
+	for _, id := range ids {
+		assert.Equal(t, ids[0], id)
+	}
+	assertBalance(t, l, "alice", 105)
+}
+
+func TestStatement(t *testing.T) {
+	l := newTestLedger(t, "alice", "bob")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	l.now = func() time.Time { return now }
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	// 00:00 deposit 100, 01:00 pay bob 40, 02:00 bob pays back 10, 03:00 withdraw 20
+	steps := []func() (Transaction, error){
+		func() (Transaction, error) { return l.Deposit("alice", 100, "") },
+		func() (Transaction, error) { return l.Transfer("alice", "bob", 40, "") },
+		func() (Transaction, error) { return l.Transfer("bob", "alice", 10, "") },
+		func() (Transaction, error) { return l.Withdraw("alice", 20, "") },
+	}
+	for i, step := range steps {
+		now = at(time.Duration(i) * time.Hour)
+		_, err := step()
+		require.NoError(t, err)
+	}
+
+	s, err := l.Statement("alice", at(time.Hour), at(3*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), s.Opening)
+	assert.Equal(t, int64(70), s.Closing)
+	require.Len(t, s.Entries, 2)
+	assert.Equal(t, Entry{TransactionID: 2, Account: "alice", Counterparty: "bob", Amount: -40, Balance: 60, Time: at(time.Hour)}, s.Entries[0])
+	assert.Equal(t, Entry{TransactionID: 3, Account: "alice", Counterparty: "bob", Amount: 10, Balance: 70, Time: at(2 * time.Hour)}, s.Entries[1])
+
+	s, err = l.Statement("alice", at(90*time.Minute), time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), s.Opening)
+	assert.Equal(t, int64(50), s.Closing)
+	assert.Len(t, s.Entries, 2)
+
+	// An empty range carries the balance at that time
+	s, err = l.Statement("alice", at(10*time.Minute), at(20*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, s.Entries)
+	assert.Equal(t, int64(100), s.Opening)
+	assert.Equal(t, int64(100), s.Closing)
+
+	_, err = l.Statement("carol", start, now)
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+}
+
+// TestConcurrentTransfers moves money randomly between accounts in both directions
+// while snapshots are taken. Run it with -race.
+func TestConcurrentTransfers(t *testing.T) {
+	const accounts, workers, transfers = 8, 16, 500
+	ids := make([]string, accounts)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("account-%d", i)
+	}
+	l := newTestLedger(t, ids...)
+	for _, id := range ids {
+		_, err := l.Deposit(id, 1000, "")
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < transfers; i++ {
+				from, to := ids[rng.Intn(accounts)], ids[rng.Intn(accounts)]
+				if from == to {
+					continue
+				}
+				_, err := l.Transfer(from, to, rng.Int63n(300)+1, "")
+				if err != nil && !errors.Is(err, ErrInsufficientFunds) {
+					t.Error(err)
+				}
+			}
+		}(int64(w))
+	}
+	stop := make(chan struct{})
+	snapshots := make(chan int)
+	go func() {
+		n := 0
+		defer func() { snapshots <- n }()
+		for {
+			var sum int64
+			for _, balance := range l.Snapshot().Balances {
+				sum += balance
+			}
+			assert.Zero(t, sum)
+			n++
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	assert.Positive(t, <-snapshots)
+
+	final := l.Snapshot()
+	var sum int64
+	for id, balance := range final.Balances {
+		sum += balance
+		if id == External {
+			assert.Equal(t, int64(-1000*accounts), balance)
+			continue
+		}
+		assert.GreaterOrEqual(t, balance, int64(0), id)
+
+		// Every entry follows from the previous one
+		s, err := l.Statement(id, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		var running int64
+		for _, e := range s.Entries {
+			running += e.Amount
+			require.Equal(t, running, e.Balance, id)
+		}
+		assert.Equal(t, balance, running, id)
+	}
+	assert.Zero(t, sum)
+}
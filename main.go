@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	chat "example.com/m/chat"
+	chatconfig "example.com/m/chat/config"
 	gpm "example.com/m/goroutine"
 	prometheus "example.com/m/prometheus"
 	rdb "example.com/m/redis"
@@ -32,15 +33,25 @@ func main() {
 		"tracingZipkin":            flag.Bool("tracingZipkin", false, "Enable tracing zipkin"),
 		"prometheus":               flag.Bool("prometheus", false, "Enable prometheus base"),
 		"prometheusApiApplication": flag.Bool("prometheusApiApplication", false, "Enable prometheus api application"),
+		"generateRules":            flag.Bool("generateRules", false, "Generate SLO recording and alerting rules"),
 		"redisBase":                flag.Bool("redisbase", false, "Enable redis base"),
 		"redisTransferMoney":       flag.Bool("redisTransferMoney", false, "Enable redis transfer money"),
 		"wsServerBase":             flag.Bool("wsServerBase", false, "Enable websocket server"),
 		"httpServerBase":           flag.Bool("httpServerBase", false, "Enable http server"),
 		"tcpipServerBase":          flag.Bool("tcpipServerBase", false, "Enable TCPIP server"),
 		"chatServer":               flag.Bool("chatServer", false, "Enable chat server"),
+		"chatImport":               flag.Bool("chatImport", false, "Import a chat room archive"),
 		"help":                     flag.Bool("help", false, "Display help information"),
 	}
 
+	// Options for the chat archive import tool
+	importFile := flag.String("importFile", "", "Archive file to import with -chatImport")
+	importRoom := flag.String("importRoom", "", "Target room for -chatImport, defaults to the room in the archive")
+	importFormat := flag.String("importFormat", "", "Archive format for -chatImport (jsonl or csv), defaults to the file extension")
+
+	// Options for the chat server config (-config, -print-config and -chat-*)
+	chatFlags := chatconfig.RegisterFlags(flag.CommandLine)
+
 	// Parse command line flags
 	flag.Parse()
 
@@ -50,6 +61,12 @@ func main() {
 		return
 	}
 
+	// Print the effective chat server config and exit
+	if *chatFlags.PrintConfig {
+		chat.PrintConfig(chatFlags)
+		return
+	}
+
 	// Check enabled flags
 	enabledCount := 0
 	for _, enabled := range flags {
@@ -98,6 +115,8 @@ func main() {
 		prometheus.PrometheusBase()
 	case *flags["prometheusApiApplication"]:
 		prometheus.PrometheusApiApplication()
+	case *flags["generateRules"]:
+		prometheus.GenerateRulesFile()
 	case *flags["redisbase"]:
 		rdb.RedisBase()
 	case *flags["redisTransferMoney"]:
@@ -109,7 +128,9 @@ func main() {
 	case *flags["tcpipServerBase"]:
 		server.TCPIPServer()
 	case *flags["chatServer"]:
-		chat.ChatServer()
+		chat.ChatServer(chatFlags)
+	case *flags["chatImport"]:
+		chat.ChatImport(chatFlags, *importFile, *importRoom, *importFormat)
 	default:
 		// Display error message if no flags are enabled
 		fmt.Println("Error: At least one option must be enabled. Please refer to -help for more information.")
@@ -133,11 +154,14 @@ func displayHelp() {
 	fmt.Println("  -tracingZipkin             This is a Go program that uses OpenTelemetry to initialize the Zipkin tracker and create tracking spans when the operation is executed multiple times to record the execution of the operation and its errors.")
 	fmt.Println("  -prometheus                This is a simple Go program that starts an HTTP server, provides a web UI for Prometheus metrics, and handles requests on the root route, logging the request count and duration.")
 	fmt.Println("  -prometheusApiApplication  This is a Go program that provides Prometheus monitoring API applications, supports HTTP request processing, resource query, user login, and health check, and has the function of gracefully shutting down services.")
+	fmt.Println("  -generateRules             This writes multi-window burn-rate recording and alerting rules for the SLOs in prometheus.DefaultSLOs to prometheus.rules.yml beside prometheus.yml.")
 	fmt.Println("  -redisbase  				  This is a Go program used to record user access. It stores access logs through PostgreSQL and uses Redis to cache the user's last access time to improve query efficiency.")
 	fmt.Println("  -redisTransferMoney  	  This is a Use Redis distributed locks to securely transfer funds and query and update user balances via PostgreSQL while subscribing to Redis expiration events to manage sessions and user activity.")
 	fmt.Println("  -wsServerBase  	 	  	  This is an implementation of a WebSocket server that upgrades HTTP connections and handles messages sent by the client and passes received messages back to the client.")
 	fmt.Println("  -httpServerBase  	 	  This is an implements a simple HTTP server that handles different request methods and prints the request details to the console.")
 	fmt.Println("  -tcpipServerBase  	 	  This is an implements a simple TCPIP server that handles different request methods and prints the request details to the console.")
 	fmt.Println("  -chatServer  	 	  	  This is a chat server implemented in Go and Gin, supporting user registration, login, real-time chat and WebSocket connections, and integrating Redis and PostgreSQL management data.")
+	fmt.Println("  -chatImport  	 	  	  This is an admin tool that replays a room archive exported from GET /rooms/:room/export into chat_messages, use with -importFile, -importRoom and -importFormat.")
+	fmt.Println("  -print-config  	 	  	  Print the effective chat server config (defaults < -config file < CHAT_* env < -chat-* flags) with secrets redacted.")
 	fmt.Println("  -help              		  Display help information")
 }
@@ -0,0 +1,311 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// API 的權限範圍，令牌繼承用戶的全部範圍
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+const (
+	// SessionCookie 是瀏覽器登錄後保存令牌的 Cookie
+	SessionCookie = "session"
+	// DefaultTokenTTL 是令牌的默認有效期
+	DefaultTokenTTL = 24 * time.Hour
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrTokenNotFound      = errors.New("token not found or expired")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrMissingCredentials = errors.New("missing bearer token or session cookie")
+
+	errInvalidScope       = errors.New("invalid scope")
+	errUsernameOrPassword = errors.New("username and password are required")
+)
+
+var validScopes = []string{ScopeRead, ScopeWrite}
+
+const authenticationRequired = "authentication required"
+
+type principalContextKey struct{}
+
+// User 是 api_users 表中的用戶
+type User struct {
+	ID           int64
+	Username     string
+	PasswordHash []byte
+	Scopes       []string
+}
+
+// Principal 是通過令牌認證的調用方
+type Principal struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HasScope 判斷調用方是否擁有 scope
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// PrincipalFromContext 返回 Require 放入請求上下文的調用方
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(Principal)
+	return p, ok
+}
+
+// AuthStore 保存用戶與令牌，令牌只保存 SHA-256 摘要
+type AuthStore interface {
+	UserByName(ctx context.Context, username string) (User, error)
+	CreateUser(ctx context.Context, user User) (User, error)
+	CreateToken(ctx context.Context, tokenHash string, p Principal) error
+	// TokenByHash 返回未過期的令牌，不存在或已過期時返回 ErrTokenNotFound
+	TokenByHash(ctx context.Context, tokenHash string, now time.Time) (Principal, error)
+	DeleteToken(ctx context.Context, tokenHash string) error
+}
+
+// Authenticator 校驗用戶密碼、簽發令牌並保護需要權限的接口
+type Authenticator struct {
+	store    AuthStore
+	TokenTTL time.Duration
+	cost     int
+	now      func() time.Time
+
+	// dummyHash 用於用戶不存在時的 bcrypt 比較，與真實密碼使用相同的 cost，首次使用時生成
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+func NewAuthenticator(store AuthStore) *Authenticator {
+	return &Authenticator{store: store, TokenTTL: DefaultTokenTTL, cost: bcrypt.DefaultCost, now: time.Now}
+}
+
+// CreateUser 以 bcrypt 保存密碼並創建用戶
+func (a *Authenticator) CreateUser(ctx context.Context, username, password string, scopes ...string) (User, error) {
+	if username == "" || password == "" {
+		return User{}, errUsernameOrPassword
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(validScopes, scope) {
+			return User{}, fmt.Errorf("%w %q", errInvalidScope, scope)
+		}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), a.cost)
+	if err != nil {
+		return User{}, err
+	}
+	return a.store.CreateUser(ctx, User{Username: username, PasswordHash: hash, Scopes: scopes})
+}
+
+// dummyPasswordHash 返回以 a.cost 生成的哈希，使不存在的用戶與密碼錯誤的用戶耗時相同
+func (a *Authenticator) dummyPasswordHash() []byte {
+	a.dummyOnce.Do(func() {
+		a.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), a.cost)
+	})
+	return a.dummyHash
+}
+
+// Login 校驗用戶名和密碼，成功時返回新令牌
+// 用戶不存在時同樣執行一次 bcrypt 比較，避免通過響應時間判斷用戶名是否存在
+func (a *Authenticator) Login(ctx context.Context, username, password string) (string, Principal, error) {
+	user, err := a.store.UserByName(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(a.dummyPasswordHash(), []byte(password))
+		return "", Principal{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", Principal{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
+		return "", Principal{}, ErrInvalidCredentials
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", Principal{}, err
+	}
+	p := Principal{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Scopes:    user.Scopes,
+		ExpiresAt: a.now().Add(a.TokenTTL).UTC().Truncate(time.Second),
+	}
+	if err := a.store.CreateToken(ctx, hashToken(token), p); err != nil {
+		return "", Principal{}, err
+	}
+	return token, p, nil
+}
+
+// Authenticate 從 Authorization: Bearer 或 session Cookie 中取出令牌並校驗
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	token := requestToken(r)
+	if token == "" {
+		return Principal{}, ErrMissingCredentials
+	}
+	return a.store.TokenByHash(r.Context(), hashToken(token), a.now())
+}
+
+// Require 只允許擁有 scope 的調用方訪問 next
+// 沒有或無效的令牌返回 401，權限不足返回 403
+func (a *Authenticator) Require(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		switch {
+		case errors.Is(err, ErrMissingCredentials) || errors.Is(err, ErrTokenNotFound):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeError(w, http.StatusUnauthorized, authenticationRequired)
+			return
+		case err != nil:
+			log.Printf("Failed to authenticate request: %v", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		case !p.HasScope(scope):
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+			writeError(w, http.StatusForbidden, fmt.Sprintf("%s scope required", scope))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalContextKey{}, p)))
+	})
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"token_type"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Scopes    []string  `json:"scopes"`
+}
+
+// LoginHandler 處理 POST /api/v1/login
+// 成功時在響應體中返回 Bearer 令牌，同時設置 session Cookie 供瀏覽器使用
+// 響應狀態碼（200、400、401、500）由 Middleware 記錄到 http_requests_total 的 code 標籤
+func (a *Authenticator) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, errUsernameOrPassword.Error())
+		return
+	}
+
+	token, p, err := a.Login(r.Context(), req.Username, req.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		log.Printf("Login failed for user %q", req.Username)
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Login error for user %q: %v", req.Username, err)
+		writeError(w, http.StatusInternalServerError, "Login failed")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  p.ExpiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		// Strict 防止其他站點帶著 Cookie 發起寫請求
+		SameSite: http.SameSiteStrictMode,
+	})
+	writeJSON(w, http.StatusOK, loginResponse{Token: token, TokenType: "Bearer", ExpiresAt: p.ExpiresAt, Scopes: p.Scopes})
+}
+
+// LogoutHandler 處理 POST /api/v1/logout，吊銷當前令牌並清除 Cookie
+func (a *Authenticator) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	token := requestToken(r)
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		writeError(w, http.StatusUnauthorized, authenticationRequired)
+		return
+	}
+	if err := a.store.DeleteToken(r.Context(), hashToken(token)); err != nil && !errors.Is(err, ErrTokenNotFound) {
+		log.Printf("Failed to revoke token: %v", err)
+		writeError(w, http.StatusInternalServerError, "Logout failed")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EnsureAdmin 在用戶不存在時創建擁有全部權限的管理員
+// password 為空時生成隨機密碼並只在日誌中打印一次
+func (a *Authenticator) EnsureAdmin(ctx context.Context, username, password string) error {
+	if _, err := a.store.UserByName(ctx, username); !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+	generated := password == ""
+	if generated {
+		var err error
+		if password, err = newToken(); err != nil {
+			return err
+		}
+	}
+	if _, err := a.CreateUser(ctx, username, password, validScopes...); err != nil && !errors.Is(err, ErrUserExists) {
+		return err
+	}
+	if generated {
+		log.Printf("Created API user %q with generated password %s", username, password)
+	} else {
+		log.Printf("Created API user %q", username)
+	}
+	return nil
+}
+
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// newToken 生成 256 位隨機令牌
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken 返回令牌的 SHA-256 摘要，數據庫洩漏時無法直接使用其中的令牌
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
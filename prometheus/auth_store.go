@@ -0,0 +1,183 @@
+package prometheus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MemoryAuthStore 是內存中的 AuthStore，用於測試和沒有數據庫時運行
+type MemoryAuthStore struct {
+	mu     sync.Mutex
+	users  map[string]User
+	tokens map[string]Principal
+	nextID int64
+}
+
+var _ AuthStore = (*MemoryAuthStore)(nil)
+
+func NewMemoryAuthStore() *MemoryAuthStore {
+	return &MemoryAuthStore{users: make(map[string]User), tokens: make(map[string]Principal)}
+}
+
+func (s *MemoryAuthStore) UserByName(ctx context.Context, username string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[username]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *MemoryAuthStore) CreateUser(ctx context.Context, user User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.Username]; ok {
+		return User{}, ErrUserExists
+	}
+	s.nextID++
+	user.ID = s.nextID
+	user.Scopes = slices.Clone(user.Scopes)
+	s.users[user.Username] = user
+	return user, nil
+}
+
+func (s *MemoryAuthStore) CreateToken(ctx context.Context, tokenHash string, p Principal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[tokenHash] = p
+	return nil
+}
+
+func (s *MemoryAuthStore) TokenByHash(ctx context.Context, tokenHash string, now time.Time) (Principal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.tokens[tokenHash]
+	if !ok || !now.Before(p.ExpiresAt) {
+		return Principal{}, ErrTokenNotFound
+	}
+	return p, nil
+}
+
+func (s *MemoryAuthStore) DeleteToken(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[tokenHash]; !ok {
+		return ErrTokenNotFound
+	}
+	delete(s.tokens, tokenHash)
+	return nil
+}
+
+// SQLAuthStore 把用戶和令牌保存在 PostgreSQL 的 api_users 和 api_tokens 表中
+// 權限範圍以空格分隔保存（與 OAuth 的 scope 參數相同）
+type SQLAuthStore struct {
+	db *sql.DB
+}
+
+var _ AuthStore = (*SQLAuthStore)(nil)
+
+func NewSQLAuthStore(db *sql.DB) *SQLAuthStore {
+	return &SQLAuthStore{db: db}
+}
+
+// authTablesSQL 創建用戶與令牌表，令牌隨用戶刪除
+const authTablesSQL = `
+CREATE TABLE IF NOT EXISTS api_users (
+	id SERIAL PRIMARY KEY,
+	username VARCHAR(100) NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	scopes TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS api_tokens (
+	token_hash CHAR(64) PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES api_users (id) ON DELETE CASCADE,
+	scopes TEXT NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS api_tokens_expires_at_idx ON api_tokens (expires_at)`
+
+// CreateTables 創建 api_users 與 api_tokens 表
+func (s *SQLAuthStore) CreateTables(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, authTablesSQL)
+	return err
+}
+
+func (s *SQLAuthStore) UserByName(ctx context.Context, username string) (User, error) {
+	var user User
+	var scopes string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, username, password_hash, scopes FROM api_users WHERE username = $1", username,
+	).Scan(&user.ID, &user.Username, &user.PasswordHash, &scopes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	user.Scopes = strings.Fields(scopes)
+	return user, err
+}
+
+func (s *SQLAuthStore) CreateUser(ctx context.Context, user User) (User, error) {
+	err := s.db.QueryRowContext(ctx,
+		"INSERT INTO api_users (username, password_hash, scopes) VALUES ($1, $2, $3) RETURNING id",
+		user.Username, string(user.PasswordHash), strings.Join(user.Scopes, " "),
+	).Scan(&user.ID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		return User{}, ErrUserExists
+	}
+	return user, err
+}
+
+func (s *SQLAuthStore) CreateToken(ctx context.Context, tokenHash string, p Principal) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO api_tokens (token_hash, user_id, scopes, expires_at) VALUES ($1, $2, $3, $4)",
+		tokenHash, p.UserID, strings.Join(p.Scopes, " "), p.ExpiresAt)
+	return err
+}
+
+func (s *SQLAuthStore) TokenByHash(ctx context.Context, tokenHash string, now time.Time) (Principal, error) {
+	var p Principal
+	var scopes string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT t.user_id, u.username, t.scopes, t.expires_at
+		FROM api_tokens t JOIN api_users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.expires_at > $2`, tokenHash, now,
+	).Scan(&p.UserID, &p.Username, &scopes, &p.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Principal{}, ErrTokenNotFound
+	}
+	p.Scopes = strings.Fields(scopes)
+	p.ExpiresAt = p.ExpiresAt.UTC()
+	return p, err
+}
+
+func (s *SQLAuthStore) DeleteToken(ctx context.Context, tokenHash string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM api_tokens WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// DeleteExpiredTokens 刪除已過期的令牌，返回刪除的數量
+func (s *SQLAuthStore) DeleteExpiredTokens(ctx context.Context, now time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM api_tokens WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
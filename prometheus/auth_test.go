@@ -0,0 +1,171 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newAuthServer(t *testing.T) (http.Handler, *Authenticator) {
+	t.Helper()
+	auth := NewAuthenticator(NewMemoryAuthStore())
+	auth.cost = bcrypt.MinCost
+
+	ctx := context.Background()
+	_, err := auth.CreateUser(ctx, "writer", "secret", ScopeRead, ScopeWrite)
+	require.NoError(t, err)
+	_, err = auth.CreateUser(ctx, "reader", "secret", ScopeRead)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	RegisterResourceRoutes(mux, NewMemoryResourceStore(), auth)
+	mux.HandleFunc("POST /api/v1/login", auth.LoginHandler)
+	mux.HandleFunc("POST /api/v1/logout", auth.LogoutHandler)
+	return Middleware(mux), auth
+}
+
+func login(t *testing.T, handler http.Handler, username, password string) (loginResponse, *http.Response) {
+	t.Helper()
+	w := doJSON(handler, http.MethodPost, "/api/v1/login", `{"username":"`+username+`","password":"`+password+`"}`, nil)
+	var resp loginResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return resp, w.Result()
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// 不存在的用戶與真實用戶的 bcrypt 比較使用相同的 cost，響應時間不會暴露用戶名是否存在
+func TestLoginUnknownUserCost(t *testing.T) {
+	auth := NewAuthenticator(NewMemoryAuthStore())
+	auth.cost = bcrypt.MinCost + 2
+	ctx := context.Background()
+	user, err := auth.CreateUser(ctx, "writer", "secret", ScopeRead)
+	require.NoError(t, err)
+
+	_, _, err = auth.Login(ctx, "nobody", "secret")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	userCost, err := bcrypt.Cost(user.PasswordHash)
+	require.NoError(t, err)
+	dummyCost, err := bcrypt.Cost(auth.dummyHash)
+	require.NoError(t, err)
+	assert.Equal(t, userCost, dummyCost)
+}
+
+func TestLoginOutcomes(t *testing.T) {
+	handler, _ := newAuthServer(t)
+	loginCount := func(code string) float64 {
+		return testutil.ToFloat64(requestCount.WithLabelValues("POST", "POST /api/v1/login", code))
+	}
+	ok, unauthorized, bad := loginCount("200"), loginCount("401"), loginCount("400")
+
+	resp, res := login(t, handler, "writer", "secret")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NotEmpty(t, resp.Token)
+	assert.Equal(t, "Bearer", resp.TokenType)
+	assert.Equal(t, []string{ScopeRead, ScopeWrite}, resp.Scopes)
+
+	cookies := res.Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, SessionCookie, cookies[0].Name)
+	assert.Equal(t, resp.Token, cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+
+	_, res = login(t, handler, "writer", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	_, res = login(t, handler, "nobody", "secret")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	_, res = login(t, handler, "writer", "")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	// 登錄結果按狀態碼記錄在 http_requests_total 中
+	assert.Equal(t, ok+1, loginCount("200"))
+	assert.Equal(t, unauthorized+2, loginCount("401"))
+	assert.Equal(t, bad+1, loginCount("400"))
+}
+
+func TestResourceScopes(t *testing.T) {
+	handler, _ := newAuthServer(t)
+	writer, _ := login(t, handler, "writer", "secret")
+	reader, _ := login(t, handler, "reader", "secret")
+	body := `{"name":"Resource A","type":"Type 1"}`
+
+	w := doJSON(handler, http.MethodGet, "/api/v1/resources", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+
+	w = doJSON(handler, http.MethodGet, "/api/v1/resources", "", bearer("not-a-token"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doJSON(handler, http.MethodGet, "/api/v1/resources", "", bearer(reader.Token))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doJSON(handler, http.MethodPost, "/api/v1/resources", body, bearer(reader.Token))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `scope="write"`)
+
+	w = doJSON(handler, http.MethodPost, "/api/v1/resources", body, bearer(writer.Token))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// 瀏覽器通過 session Cookie 認證
+	w = doJSON(handler, http.MethodGet, "/api/v1/resources/1", "", http.Header{"Cookie": {SessionCookie + "=" + reader.Token}})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestTokenExpiryAndLogout(t *testing.T) {
+	handler, auth := newAuthServer(t)
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+
+	resp, _ := login(t, handler, "reader", "secret")
+	w := doJSON(handler, http.MethodGet, "/api/v1/resources", "", bearer(resp.Token))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	now = now.Add(DefaultTokenTTL)
+	w = doJSON(handler, http.MethodGet, "/api/v1/resources", "", bearer(resp.Token))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	now = time.Now()
+	resp, _ = login(t, handler, "reader", "secret")
+	w = doJSON(handler, http.MethodPost, "/api/v1/logout", "", bearer(resp.Token))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = doJSON(handler, http.MethodGet, "/api/v1/resources", "", bearer(resp.Token))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestCreateUser(t *testing.T) {
+	auth := NewAuthenticator(NewMemoryAuthStore())
+	auth.cost = bcrypt.MinCost
+	ctx := context.Background()
+
+	user, err := auth.CreateUser(ctx, "alice", "secret", ScopeRead)
+	require.NoError(t, err)
+	assert.NotEqual(t, []byte("secret"), user.PasswordHash)
+	assert.NoError(t, bcrypt.CompareHashAndPassword(user.PasswordHash, []byte("secret")))
+
+	_, err = auth.CreateUser(ctx, "alice", "other")
+	assert.ErrorIs(t, err, ErrUserExists)
+	_, err = auth.CreateUser(ctx, "bob", "secret", "admin")
+	assert.Error(t, err)
+
+	// EnsureAdmin 不會覆蓋已存在的用戶
+	require.NoError(t, auth.EnsureAdmin(ctx, "alice", "changed"))
+	_, _, err = auth.Login(ctx, "alice", "secret")
+	assert.NoError(t, err)
+
+	require.NoError(t, auth.EnsureAdmin(ctx, "admin", "admin-password"))
+	_, p, err := auth.Login(ctx, "admin", "admin-password")
+	require.NoError(t, err)
+	assert.True(t, p.HasScope(ScopeWrite))
+}
@@ -0,0 +1,262 @@
+// Package client 是 PrometheusApiApplication 資源接口的 Go 客戶端，
+// 類型與 /api/v1/openapi.json 中的 schema 一一對應
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resource 對應 OpenAPI 中的 Resource，ETag 來自響應頭，用於後續的條件更新
+type Resource struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ETag      string    `json:"-"`
+}
+
+// ResourceInput 對應 OpenAPI 中的 ResourceInput
+type ResourceInput struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ResourcePatch 對應 OpenAPI 中的 ResourcePatch，為 nil 的字段不修改
+type ResourcePatch struct {
+	Name *string `json:"name,omitempty"`
+	Type *string `json:"type,omitempty"`
+}
+
+// ResourceList 對應 OpenAPI 中的 ResourceList
+type ResourceList struct {
+	Items    []Resource `json:"items"`
+	Total    int        `json:"total"`
+	Page     int        `json:"page"`
+	PageSize int        `json:"page_size"`
+}
+
+// ListOptions 是 listResources 的查詢參數，零值表示使用服務端默認值
+type ListOptions struct {
+	Type     string
+	Sort     string // 如 "name" 或 "-created_at"
+	Page     int
+	PageSize int
+}
+
+// Token 對應 OpenAPI 中的 Token
+type Token struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"token_type"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Scopes    []string  `json:"scopes"`
+}
+
+// Error 是服務端返回的非 2xx 響應
+type Error struct {
+	StatusCode int
+	Message    string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsStatus 判斷 err 是否是狀態碼為 code 的 *Error
+func IsStatus(err error, code int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// IsNotFound 判斷資源是否不存在
+func IsNotFound(err error) bool { return IsStatus(err, http.StatusNotFound) }
+
+// IsConflict 判斷條件更新是否因為資源已被修改而失敗（412）
+func IsConflict(err error) bool { return IsStatus(err, http.StatusPreconditionFailed) }
+
+// Client 調用資源接口，可以並發發送請求，但 Login、Logout 和 SetToken 不能與其他請求並發調用
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient 使用自定義的 http.Client，默認為 http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithToken 使用已有的 Bearer 令牌
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// New 創建客戶端，baseURL 如 http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{baseURL: strings.TrimRight(baseURL, "/"), httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SetToken 設置後續請求使用的 Bearer 令牌
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// Login 登錄並在客戶端中保存返回的令牌
+func (c *Client) Login(ctx context.Context, username, password string) (*Token, error) {
+	var token Token
+	body := map[string]string{"username": username, "password": password}
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/login", nil, body, &token); err != nil {
+		return nil, err
+	}
+	c.token = token.Token
+	return &token, nil
+}
+
+// Logout 吊銷當前令牌
+func (c *Client) Logout(ctx context.Context) error {
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/logout", nil, nil, nil); err != nil {
+		return err
+	}
+	c.token = ""
+	return nil
+}
+
+func (c *Client) ListResources(ctx context.Context, opts ListOptions) (*ResourceList, error) {
+	query := url.Values{}
+	if opts.Type != "" {
+		query.Set("type", opts.Type)
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.PageSize > 0 {
+		query.Set("page_size", strconv.Itoa(opts.PageSize))
+	}
+	path := "/api/v1/resources"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var list ResourceList
+	if _, err := c.do(ctx, http.MethodGet, path, nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (c *Client) GetResource(ctx context.Context, id int64) (*Resource, error) {
+	return c.resource(ctx, http.MethodGet, id, "", nil)
+}
+
+func (c *Client) CreateResource(ctx context.Context, in ResourceInput) (*Resource, error) {
+	var res Resource
+	header, err := c.do(ctx, http.MethodPost, "/api/v1/resources", nil, in, &res)
+	if err != nil {
+		return nil, err
+	}
+	res.ETag = header.Get("ETag")
+	return &res, nil
+}
+
+// UpdateResource 替換資源，etag 不為空時只有資源未被修改才會成功
+func (c *Client) UpdateResource(ctx context.Context, id int64, in ResourceInput, etag string) (*Resource, error) {
+	return c.resource(ctx, http.MethodPut, id, etag, in)
+}
+
+// PatchResource 修改資源的部分字段，etag 不為空時只有資源未被修改才會成功
+func (c *Client) PatchResource(ctx context.Context, id int64, patch ResourcePatch, etag string) (*Resource, error) {
+	return c.resource(ctx, http.MethodPatch, id, etag, patch)
+}
+
+// DeleteResource 刪除資源，etag 不為空時只有資源未被修改才會成功
+func (c *Client) DeleteResource(ctx context.Context, id int64, etag string) error {
+	_, err := c.do(ctx, http.MethodDelete, resourcePath(id), ifMatch(etag), nil, nil)
+	return err
+}
+
+func (c *Client) resource(ctx context.Context, method string, id int64, etag string, body any) (*Resource, error) {
+	var res Resource
+	header, err := c.do(ctx, method, resourcePath(id), ifMatch(etag), body, &res)
+	if err != nil {
+		return nil, err
+	}
+	res.ETag = header.Get("ETag")
+	return &res, nil
+}
+
+func resourcePath(id int64) string {
+	return "/api/v1/resources/" + strconv.FormatInt(id, 10)
+}
+
+func ifMatch(etag string) http.Header {
+	if etag == "" {
+		return nil
+	}
+	return http.Header{"If-Match": {etag}}
+}
+
+// do 發送請求，body 不為 nil 時編碼為 JSON，2xx 響應解碼到 out，其他響應返回 *Error
+func (c *Client) do(ctx context.Context, method, path string, header http.Header, body, out any) (http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return resp.Header, apiErr
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.Header, fmt.Errorf("api: decode %s %s response: %w", method, path, err)
+		}
+	}
+	return resp.Header, nil
+}
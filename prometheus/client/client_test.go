@@ -0,0 +1,98 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/m/prometheus"
+	"example.com/m/prometheus/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	auth := prometheus.NewAuthenticator(prometheus.NewMemoryAuthStore())
+	_, err := auth.CreateUser(context.Background(), "admin", "secret", prometheus.ScopeRead, prometheus.ScopeWrite)
+	require.NoError(t, err)
+	_, err = auth.CreateUser(context.Background(), "viewer", "secret", prometheus.ScopeRead)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	prometheus.RegisterAPIRoutes(mux, prometheus.NewMemoryResourceStore(), auth)
+	srv := httptest.NewServer(prometheus.ValidateRequests(mux))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClientResources(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	c := client.New(srv.URL, client.WithHTTPClient(srv.Client()))
+
+	_, err := c.ListResources(ctx, client.ListOptions{})
+	assert.True(t, client.IsStatus(err, http.StatusUnauthorized))
+
+	token, err := c.Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read", "write"}, token.Scopes)
+
+	a, err := c.CreateResource(ctx, client.ResourceInput{Name: "Resource A", Type: "Type 1"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, a.ETag)
+	_, err = c.CreateResource(ctx, client.ResourceInput{Name: "Resource B", Type: "Type 2"})
+	require.NoError(t, err)
+
+	list, err := c.ListResources(ctx, client.ListOptions{Type: "Type 1", Sort: "-name", PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, list.Total)
+	assert.Equal(t, "Resource A", list.Items[0].Name)
+
+	got, err := c.GetResource(ctx, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, a.ETag, got.ETag)
+
+	name := "Resource A2"
+	patched, err := c.PatchResource(ctx, a.ID, client.ResourcePatch{Name: &name}, got.ETag)
+	require.NoError(t, err)
+	assert.Equal(t, "Resource A2", patched.Name)
+	assert.Equal(t, "Type 1", patched.Type)
+
+	// 使用過期的 ETag 更新返回 412
+	_, err = c.UpdateResource(ctx, a.ID, client.ResourceInput{Name: "Stale", Type: "Type 1"}, got.ETag)
+	assert.True(t, client.IsConflict(err))
+
+	updated, err := c.UpdateResource(ctx, a.ID, client.ResourceInput{Name: "Resource A3", Type: "Type 3"}, patched.ETag)
+	require.NoError(t, err)
+
+	require.NoError(t, c.DeleteResource(ctx, a.ID, updated.ETag))
+	_, err = c.GetResource(ctx, a.ID)
+	assert.True(t, client.IsNotFound(err))
+
+	require.NoError(t, c.Logout(ctx))
+	_, err = c.ListResources(ctx, client.ListOptions{})
+	assert.True(t, client.IsStatus(err, http.StatusUnauthorized))
+}
+
+func TestClientErrors(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	c := client.New(srv.URL+"/", client.WithHTTPClient(srv.Client()))
+
+	_, err := c.Login(ctx, "admin", "wrong")
+	assert.True(t, client.IsStatus(err, http.StatusUnauthorized))
+
+	_, err = c.Login(ctx, "viewer", "secret")
+	require.NoError(t, err)
+	_, err = c.CreateResource(ctx, client.ResourceInput{Name: "Resource A", Type: "Type 1"})
+	assert.True(t, client.IsStatus(err, http.StatusForbidden))
+
+	// 請求不符合 OpenAPI 文檔時由校驗中間件拒絕
+	_, err = c.ListResources(ctx, client.ListOptions{PageSize: 1000})
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Message, "page_size")
+}
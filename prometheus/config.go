@@ -0,0 +1,69 @@
+package prometheus
+
+import (
+	"bytes"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile 是 Supervisor 生成並傳給 prometheus 的配置文件
+const ConfigFile = "prometheus.yml"
+
+// PrometheusConfig 是 prometheus.yml 中用到的部分
+type PrometheusConfig struct {
+	Global        GlobalConfig   `yaml:"global"`
+	RuleFiles     []string       `yaml:"rule_files,omitempty"`
+	ScrapeConfigs []ScrapeConfig `yaml:"scrape_configs"`
+}
+
+type GlobalConfig struct {
+	ScrapeInterval string `yaml:"scrape_interval"`
+}
+
+type ScrapeConfig struct {
+	JobName       string         `yaml:"job_name"`
+	MetricsPath   string         `yaml:"metrics_path,omitempty"`
+	StaticConfigs []StaticConfig `yaml:"static_configs"`
+}
+
+type StaticConfig struct {
+	Targets []string `yaml:"targets"`
+}
+
+// DefaultPrometheusConfig 每 15 秒抓取 target 上的 /metrics，並加載 SLO 規則
+func DefaultPrometheusConfig(target string) PrometheusConfig {
+	return PrometheusConfig{
+		Global:    GlobalConfig{ScrapeInterval: "15s"},
+		RuleFiles: []string{RulesFile},
+		ScrapeConfigs: []ScrapeConfig{{
+			JobName:       "golang_app",
+			StaticConfigs: []StaticConfig{{Targets: []string{target}}},
+		}},
+	}
+}
+
+// Marshal 返回帶有生成說明的 YAML
+func (c PrometheusConfig) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by prometheus.Supervisor from DefaultPrometheusConfig. DO NOT EDIT.\n")
+	buf.WriteString("# SLO rules are regenerated with `go run . -generateRules`.\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile 把配置寫入 path
+func (c PrometheusConfig) WriteFile(path string) error {
+	data, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
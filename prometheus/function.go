@@ -1,11 +1,11 @@
 package prometheus
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -14,21 +14,17 @@ import (
 func init() {
 	prometheus.MustRegister(requestCount)
 	prometheus.MustRegister(requestDuration)
+	prometheus.MustRegister(requestSize)
+	prometheus.MustRegister(responseSize)
 	prometheus.MustRegister(currentConnections)
 	prometheus.MustRegister(requestLatency)
 }
 
-// startPrometheus starts the Prometheus process
-func startPrometheus() {
-	cmd := exec.Command("prometheus", "--config.file=prometheus.yml")
-	err := cmd.Start()
-	if err != nil {
-		log.Fatalf("Failed to start Prometheus: %v", err)
-	}
-	log.Println("Prometheus started. Access it at http://localhost:9090")
-	err = cmd.Wait()
-	if err != nil {
-		log.Fatalf("Prometheus exited with error: %v", err)
+// startPrometheus runs Prometheus under a supervisor until ctx is cancelled.
+// A missing binary is only logged, so the application keeps serving without Prometheus.
+func startPrometheus(ctx context.Context, sup *Supervisor) {
+	if err := sup.Run(ctx); err != nil {
+		log.Printf("Prometheus not started: %v", err)
 	}
 }
 
@@ -84,5 +80,10 @@ func checkAndCreateTable(db *sql.DB) error {
 		}
 	}
 
+	// Keep updated_at current on every update (also for tables created before the trigger existed)
+	if _, err := db.Exec(resourceTriggerSQL); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,110 @@
+package prometheus
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// unmatchedRoute 是沒有匹配到路由的請求使用的 route 標籤，避免把任意路徑寫入標籤
+const unmatchedRoute = "unmatched"
+
+// Middleware 記錄 net/http 請求的數量、耗時、請求與響應大小以及正在處理的請求數
+// next 通常是 *http.ServeMux，route 標籤取匹配到的路由模板（Request.Pattern）
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		currentConnections.Inc()
+		defer currentConnections.Dec()
+
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// ServeMux 在同一個 *http.Request 上設置 Pattern
+		observe(r, r.Pattern, rec.status, rec.size, time.Since(start))
+	})
+}
+
+// GinMiddleware 記錄 Gin 請求的指標，route 標籤取 Gin 的路由模板（如 /users/:id）
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentConnections.Inc()
+		defer currentConnections.Dec()
+
+		start := time.Now()
+		c.Next()
+
+		observe(c.Request, c.FullPath(), c.Writer.Status(), max(c.Writer.Size(), 0), time.Since(start))
+	}
+}
+
+// MetricsHandler 導出默認註冊表中的指標，使用 OpenMetrics 格式時包含指向追蹤的 exemplar
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+func observe(r *http.Request, route string, status, size int, elapsed time.Duration) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	method := r.Method
+	exemplar := traceExemplar(r)
+
+	counter := requestCount.WithLabelValues(method, route, strconv.Itoa(status))
+	duration := requestDuration.WithLabelValues(method, route)
+	if exemplar != nil {
+		counter.(prometheus.ExemplarAdder).AddWithExemplar(1, exemplar)
+		duration.(prometheus.ExemplarObserver).ObserveWithExemplar(elapsed.Seconds(), exemplar)
+	} else {
+		counter.Inc()
+		duration.Observe(elapsed.Seconds())
+	}
+	requestLatency.WithLabelValues(method, route).Observe(elapsed.Seconds())
+
+	if r.ContentLength >= 0 {
+		requestSize.WithLabelValues(method, route).Observe(float64(r.ContentLength))
+	}
+	responseSize.WithLabelValues(method, route).Observe(float64(size))
+}
+
+// traceExemplar 返回帶有 trace_id 的 exemplar，請求不在已採樣的追蹤中時返回 nil
+func traceExemplar(r *http.Request) prometheus.Labels {
+	sc := trace.SpanContextFromContext(r.Context())
+	if !sc.IsSampled() {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": sc.TraceID().String()}
+}
+
+// responseRecorder 記錄 handler 寫入的狀態碼與響應大小
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Unwrap 讓 http.ResponseController 可以使用底層 ResponseWriter 的 Flush、Hijack 等功能
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,67 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("POST /items", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid item", http.StatusBadRequest)
+	})
+	handler := Middleware(mux)
+
+	for _, path := range []string{"/items/1", "/items/2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":""}`)))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(requestCount.WithLabelValues("GET", "GET /items/{id}", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(requestCount.WithLabelValues("POST", "POST /items", "400")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(requestCount.WithLabelValues("GET", unmatchedRoute, "404")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(currentConnections))
+
+	count, err := testutil.GatherAndCount(prometheus.DefaultGatherer, "http_request_size_bytes", "http_response_size_bytes")
+	assert.NoError(t, err)
+	assert.Positive(t, count)
+}
+
+func TestGinMiddlewareExemplar(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinMiddleware())
+	router.GET("/users/:id", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	router.GET("/metrics", gin.WrapH(MetricsHandler()))
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req = req.WithContext(trace.ContextWithSpanContext(context.Background(), sc))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(requestCount.WithLabelValues("GET", "/users/:id", "200")))
+
+	// exemplar 只在 OpenMetrics 格式中導出
+	w := httptest.NewRecorder()
+	metricsReq := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	metricsReq.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	router.ServeHTTP(w, metricsReq)
+	assert.Contains(t, w.Body.String(), `trace_id="`+sc.TraceID().String()+`"`)
+}
@@ -0,0 +1,385 @@
+package prometheus
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// OpenAPISpec 是資源接口的 OpenAPI 3 文檔，由 /api/v1/openapi.json 提供並用於校驗請求
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+// OpenAPIHandler 返回 OpenAPI 文檔
+func OpenAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(OpenAPISpec)
+	})
+}
+
+// RegisterAPIRoutes 註冊資源接口、登錄登出接口以及 OpenAPI 文檔
+func RegisterAPIRoutes(mux *http.ServeMux, resources ResourceStore, auth *Authenticator) {
+	RegisterResourceRoutes(mux, resources, auth)
+	if auth != nil {
+		mux.HandleFunc("POST /api/v1/login", auth.LoginHandler)
+		mux.HandleFunc("POST /api/v1/logout", auth.LogoutHandler)
+	}
+	mux.Handle("GET /api/v1/openapi.json", OpenAPIHandler())
+}
+
+// schema 是 JSON Schema 中文檔用到的子集
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []any              `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+}
+
+type parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type operation struct {
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool                 `json:"required"`
+		Content  map[string]mediaType `json:"content"`
+	} `json:"requestBody"`
+}
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas    map[string]*schema   `json:"schemas"`
+		Parameters map[string]parameter `json:"parameters"`
+	} `json:"components"`
+}
+
+// route 是文檔中的一個操作，路徑按段匹配，{name} 匹配任意一段
+type route struct {
+	method       string
+	pattern      string // 與 ServeMux 路由相同格式的模板，如 "GET /api/v1/resources/{id}"
+	segments     []string
+	params       []parameter
+	body         *schema
+	bodyRequired bool
+}
+
+// RequestValidator 按 OpenAPI 文檔校驗請求的路徑參數、查詢參數和 JSON 請求體
+// 不在文檔中的請求直接交給下一個 handler
+type RequestValidator struct {
+	schemas map[string]*schema
+	routes  []route
+}
+
+// NewRequestValidator 解析 OpenAPI 文檔
+func NewRequestValidator(spec []byte) (*RequestValidator, error) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	v := &RequestValidator{schemas: doc.Components.Schemas}
+
+	resolve := func(p parameter) (parameter, error) {
+		if p.Ref == "" {
+			return p, nil
+		}
+		resolved, ok := doc.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+		if !ok {
+			return p, fmt.Errorf("openapi: unknown parameter %s", p.Ref)
+		}
+		return resolved, nil
+	}
+
+	// 按路徑排序，匹配結果與 map 的遍歷順序無關
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		item := doc.Paths[path]
+		var shared []parameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("openapi: %s parameters: %w", path, err)
+			}
+		}
+		for method, raw := range item {
+			if method == "parameters" {
+				continue
+			}
+			var op operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %w", method, path, err)
+			}
+			rt := route{method: strings.ToUpper(method), pattern: strings.ToUpper(method) + " " + path, segments: strings.Split(path, "/")}
+			for _, p := range append(slices.Clone(shared), op.Parameters...) {
+				p, err := resolve(p)
+				if err != nil {
+					return nil, err
+				}
+				rt.params = append(rt.params, p)
+			}
+			if op.RequestBody != nil {
+				if media, ok := op.RequestBody.Content["application/json"]; ok {
+					rt.body = media.Schema
+					rt.bodyRequired = op.RequestBody.Required
+				}
+			}
+			v.routes = append(v.routes, rt)
+		}
+	}
+	return v, nil
+}
+
+// Middleware 校驗請求，參數錯誤或請求體不是 JSON 時返回 400，請求體不符合 schema 時返回 422
+// 請求體讀取後會放回 r.Body，並且不替換 *http.Request，Middleware 仍能讀到 ServeMux 設置的 Pattern
+// 被拒絕的請求不會到達 ServeMux，此時把 Pattern 設為文檔中匹配到的路由，指標仍按路由統計
+func (v *RequestValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt, pathValues, ok := v.match(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := v.validateParams(r, rt, pathValues); err != nil {
+			r.Pattern = rt.pattern
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if rt.body != nil {
+			if status, err := v.validateBody(w, r, rt); err != nil {
+				r.Pattern = rt.pattern
+				writeError(w, status, err.Error())
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+var defaultValidator = func() *RequestValidator {
+	v, err := NewRequestValidator(OpenAPISpec)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}()
+
+// ValidateRequests 按內嵌的 OpenAPISpec 校驗請求
+func ValidateRequests(next http.Handler) http.Handler {
+	return defaultValidator.Middleware(next)
+}
+
+func (v *RequestValidator) match(r *http.Request) (route, map[string]string, bool) {
+	segments := strings.Split(r.URL.Path, "/")
+	for _, rt := range v.routes {
+		if rt.method != r.Method || len(rt.segments) != len(segments) {
+			continue
+		}
+		values := map[string]string{}
+		matched := true
+		for i, seg := range rt.segments {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") && segments[i] != "" {
+				values[seg[1:len(seg)-1]] = segments[i]
+			} else if seg != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rt, values, true
+		}
+	}
+	return route{}, nil, false
+}
+
+func (v *RequestValidator) validateParams(r *http.Request, rt route, pathValues map[string]string) error {
+	query := r.URL.Query()
+	for _, p := range rt.params {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			raw, present = pathValues[p.Name]
+		case "query":
+			present = query.Has(p.Name)
+			raw = query.Get(p.Name)
+		case "header":
+			raw = r.Header.Get(p.Name)
+			present = raw != ""
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				return fmt.Errorf("%s parameter %s is required", p.In, p.Name)
+			}
+			continue
+		}
+		if p.Schema == nil {
+			continue
+		}
+		value := any(raw)
+		if p.Schema.Type == "integer" || p.Schema.Type == "number" {
+			value = json.Number(raw)
+		}
+		if err := v.validate(p.Schema, value, p.Name); err != nil {
+			return fmt.Errorf("%s parameter %w", p.In, err)
+		}
+	}
+	return nil
+}
+
+func (v *RequestValidator) validateBody(w http.ResponseWriter, r *http.Request, rt route) (int, error) {
+	if r.ContentLength != 0 {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			return http.StatusUnsupportedMediaType, errors.New("content type must be application/json")
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if rt.bodyRequired {
+			return http.StatusBadRequest, errors.New("request body is required")
+		}
+		return 0, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var body any
+	if err := decoder.Decode(&body); err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if err := v.validate(rt.body, body, "body"); err != nil {
+		return http.StatusUnprocessableEntity, err
+	}
+	return 0, nil
+}
+
+// validate 按 schema 校驗 value，value 是 json.Decoder（UseNumber）解碼得到的值
+func (v *RequestValidator) validate(s *schema, value any, name string) error {
+	if s.Ref != "" {
+		resolved, ok := v.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", name, s.Ref)
+		}
+		s = resolved
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", name)
+		}
+		for _, field := range s.Required {
+			if _, ok := obj[field]; !ok {
+				return fmt.Errorf("%s.%s is required", name, field)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			prop, ok := s.Properties[key]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s.%s is not allowed", name, key)
+				}
+				continue
+			}
+			if err := v.validate(prop, obj[key], name+"."+key); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s must be an array", name)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := v.validate(s.Items, item, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", name)
+		}
+		length := utf8.RuneCountInString(str)
+		if s.MinLength != nil && length < *s.MinLength {
+			return fmt.Errorf("%s must be at least %d characters", name, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fmt.Errorf("%s must be at most %d characters", name, *s.MaxLength)
+		}
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s must be a %s", name, s.Type)
+		}
+		f, err := num.Float64()
+		if s.Type == "integer" {
+			_, err = num.Int64()
+		}
+		if err != nil {
+			return fmt.Errorf("%s must be a %s", name, s.Type)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s must be at least %g", name, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s must be at most %g", name, *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", name)
+		}
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(value) }) {
+		return fmt.Errorf("%s must be one of %v", name, s.Enum)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoHandler 返回讀到的請求體，用於確認校驗之後請求體仍可讀取
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Write(body)
+})
+
+func TestRequestValidator(t *testing.T) {
+	handler := ValidateRequests(echoHandler)
+
+	tests := []struct {
+		name, method, path, body string
+		code                     int
+	}{
+		{"valid list", http.MethodGet, "/api/v1/resources?type=Type+1&sort=-name&page=2&page_size=5", "", http.StatusOK},
+		{"unknown sort", http.MethodGet, "/api/v1/resources?sort=password", "", http.StatusBadRequest},
+		{"page not integer", http.MethodGet, "/api/v1/resources?page=one", "", http.StatusBadRequest},
+		{"page size too large", http.MethodGet, "/api/v1/resources?page_size=101", "", http.StatusBadRequest},
+		{"id not integer", http.MethodGet, "/api/v1/resources/abc", "", http.StatusBadRequest},
+		{"id below minimum", http.MethodDelete, "/api/v1/resources/0", "", http.StatusBadRequest},
+		{"valid create", http.MethodPost, "/api/v1/resources", `{"name":"a","type":"b"}`, http.StatusOK},
+		{"missing body", http.MethodPost, "/api/v1/resources", "", http.StatusBadRequest},
+		{"malformed body", http.MethodPost, "/api/v1/resources", `{"name":`, http.StatusBadRequest},
+		{"missing field", http.MethodPost, "/api/v1/resources", `{"name":"a"}`, http.StatusUnprocessableEntity},
+		{"unknown field", http.MethodPut, "/api/v1/resources/1", `{"name":"a","type":"b","owner":"c"}`, http.StatusUnprocessableEntity},
+		{"wrong type", http.MethodPatch, "/api/v1/resources/1", `{"name":1}`, http.StatusUnprocessableEntity},
+		{"too long", http.MethodPatch, "/api/v1/resources/1", `{"type":"` + strings.Repeat("b", 51) + `"}`, http.StatusUnprocessableEntity},
+		{"valid patch", http.MethodPatch, "/api/v1/resources/1", `{"type":"b"}`, http.StatusOK},
+		{"not in spec", http.MethodGet, "/health", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doJSON(handler, tt.method, tt.path, tt.body, nil)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code == http.StatusOK {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader("username=a&password=b"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+// 被校驗拒絕的請求按文檔中的路由統計，而不是記為 unmatched
+func TestRejectedRequestsKeepRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterAPIRoutes(mux, NewMemoryResourceStore(), NewAuthenticator(NewMemoryAuthStore()))
+	handler := Middleware(ValidateRequests(mux))
+	count := func(route, code string) float64 {
+		return testutil.ToFloat64(requestCount.WithLabelValues("POST", route, code))
+	}
+	login, unmatched := count("POST /api/v1/login", "400"), count(unmatchedRoute, "400")
+
+	w := doJSON(handler, http.MethodPost, "/api/v1/login", `{"username":`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, login+1, count("POST /api/v1/login", "400"))
+	assert.Equal(t, unmatched, count(unmatchedRoute, "400"))
+
+	before := count("POST /api/v1/resources", "422")
+	w = doJSON(handler, http.MethodPost, "/api/v1/resources", `{"name":"a"}`, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, before+1, count("POST /api/v1/resources", "422"))
+}
+
+// 文檔中的每個操作都已註冊，註冊的路由也都寫在文檔中
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterAPIRoutes(mux, NewMemoryResourceStore(), NewAuthenticator(NewMemoryAuthStore()))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.True(t, strings.HasPrefix(doc.OpenAPI, "3."))
+
+	var documented []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			pattern := strings.ToUpper(method) + " " + path
+			documented = append(documented, pattern)
+
+			req := httptest.NewRequest(strings.ToUpper(method), strings.ReplaceAll(path, "{id}", "1"), nil)
+			_, registered := mux.Handler(req)
+			assert.Equal(t, pattern, registered)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"POST /api/v1/login",
+		"POST /api/v1/logout",
+		"GET /api/v1/resources",
+		"POST /api/v1/resources",
+		"GET /api/v1/resources/{id}",
+		"PUT /api/v1/resources/{id}",
+		"PATCH /api/v1/resources/{id}",
+		"DELETE /api/v1/resources/{id}",
+	}, documented)
+}
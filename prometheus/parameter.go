@@ -23,12 +23,13 @@ var (
 	)
 
 	// 定義指標，包括 Gauge 和 Summary
+	// 由 Middleware 與 GinMiddleware 記錄，route 為路由模板而不是實際路徑
 	requestCount = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "http_requests_total",
 			Help: "Total number of HTTP requests",
 		},
-		[]string{"method", "status", "path"},
+		[]string{"method", "route", "code"},
 	)
 
 	requestDuration = prometheus.NewHistogramVec(
@@ -37,10 +38,28 @@ var (
 			Help:    "Duration of HTTP requests in seconds.",
 			Buckets: prometheus.DefBuckets,
 		},
-		[]string{"method", "path"}, // Expecting three labels: method, endpoint, and status
+		[]string{"method", "route"},
 	)
 
-	// 追蹤當前連接數的 Gauge
+	requestSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_size_bytes",
+			Help:    "Size of HTTP request bodies in bytes",
+			Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+		},
+		[]string{"method", "route"},
+	)
+
+	responseSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Size of HTTP response bodies in bytes",
+			Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+		},
+		[]string{"method", "route"},
+	)
+
+	// 追蹤當前連接數的 Gauge，即正在處理的請求數
 	currentConnections = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "http_current_connections",
@@ -55,6 +74,6 @@ var (
 			Help:       "Summary of HTTP request latencies",
 			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}, // 自定義分位數
 		},
-		[]string{"method", "path"},
+		[]string{"method", "route"},
 	)
 )
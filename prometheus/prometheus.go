@@ -1,6 +1,7 @@
 package prometheus
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"time"
@@ -10,7 +11,7 @@ import (
 
 func PrometheusBase() {
 	// Start the Prometheus web UI
-	go startPrometheus()
+	go startPrometheus(context.Background(), NewSupervisor(SupervisorOptionsFromEnv()))
 
 	// Start the application's HTTP server
 	http.HandleFunc("/", handler)
@@ -3,7 +3,6 @@ package prometheus
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"log"
 	"net/http"
 	"os"
@@ -13,7 +12,7 @@ import (
 	"time"
 
 	_ "github.com/lib/pq" // PostgreSQL driver
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func PrometheusApiApplication() {
@@ -23,7 +22,7 @@ func PrometheusApiApplication() {
 	// Create an HTTP server that supports graceful shutdown
 	server := &http.Server{
 		Addr:    ":8080",
-		Handler: http.DefaultServeMux,
+		Handler: Middleware(ValidateRequests(http.DefaultServeMux)), // Record request metrics and validate against the OpenAPI spec
 	}
 
 	// Initialize database connection
@@ -37,21 +36,35 @@ func PrometheusApiApplication() {
 		log.Fatal(err)
 	}
 
-	// Start Prometheus UI in a goroutine
+	// Set up API users and tokens, creating the admin user on first start
+	auth, err := initAuth(context.Background(), db)
+	if err != nil {
+		log.Fatalf("Failed to initialize authentication: %v", err)
+	}
+
+	// Start Prometheus UI in a goroutine, stopped after the HTTP server shuts down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	supervisor := NewSupervisor(SupervisorOptionsFromEnv())
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		startPrometheus()
+		startPrometheus(ctx, supervisor)
 	}()
 
-	// Handle HTTP request paths
-	http.HandleFunc("/api/v1/resource", func(w http.ResponseWriter, r *http.Request) {
-		resourceHandler(w, r, db)
-	})
+	// Keep recent samples of our own metrics in memory for /debug/metrics/query
+	tsdb := NewTSDB(prometheus.DefaultGatherer, TSDBOptions{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tsdb.Run(ctx)
+	}()
 
-	http.HandleFunc("/api/v1/login", loginHandler)
-	http.HandleFunc("/health", healthHandler)
-	http.Handle("/metrics", promhttp.Handler()) // Provide Prometheus metrics
+	// Handle HTTP request paths
+	RegisterAPIRoutes(http.DefaultServeMux, NewSQLResourceStore(db), auth)
+	http.HandleFunc("/health", healthHandler(supervisor))
+	http.Handle("/metrics", MetricsHandler()) // Provide Prometheus metrics
+	http.Handle("GET /debug/metrics/query", tsdb.QueryHandler())
 
 	// Start HTTP server
 	wg.Add(1)
@@ -65,83 +78,45 @@ func PrometheusApiApplication() {
 
 	// Handle graceful shutdown
 	gracefulShutdown(server)
+	cancel()
 
 	wg.Wait() // Wait for all goroutines to finish
 }
 
-// Handle resource requests
-func resourceHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	start := time.Now()
-
-	// Query the database
-	rows, err := db.Query("SELECT id, name, type, created_at, updated_at FROM resources")
-	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		requestCount.WithLabelValues(r.Method, "500", r.URL.Path).Inc()
-		return
+// initAuth creates the auth tables, removes expired tokens and ensures the admin user exists.
+// The admin is named by API_ADMIN_USERNAME (default "admin"); without API_ADMIN_PASSWORD a random
+// password is generated and logged once.
+func initAuth(ctx context.Context, db *sql.DB) (*Authenticator, error) {
+	store := NewSQLAuthStore(db)
+	if err := store.CreateTables(ctx); err != nil {
+		return nil, err
 	}
-	defer rows.Close()
-
-	// Process query results
-	var resources []map[string]interface{}
-	for rows.Next() {
-		var id int
-		var name, resourceType string
-		var createdAt, updatedAt string
-		if err := rows.Scan(&id, &name, &resourceType, &createdAt, &updatedAt); err != nil {
-			http.Error(w, "Data error", http.StatusInternalServerError)
-			requestCount.WithLabelValues(r.Method, "500", r.URL.Path).Inc()
-			return
-		}
-
-		// Add resource information to the slice
-		resource := map[string]interface{}{
-			"id":         id,
-			"name":       name,
-			"type":       resourceType,
-			"created_at": createdAt,
-			"updated_at": updatedAt,
-		}
-		resources = append(resources, resource)
+	if n, err := store.DeleteExpiredTokens(ctx, time.Now()); err != nil {
+		return nil, err
+	} else if n > 0 {
+		log.Printf("Removed %d expired API tokens", n)
 	}
 
-	// Encode response as JSON
-	response, err := json.Marshal(resources)
-	if err != nil {
-		http.Error(w, "JSON encoding error", http.StatusInternalServerError)
-		requestCount.WithLabelValues(r.Method, "500", r.URL.Path).Inc()
-		return
+	auth := NewAuthenticator(store)
+	username := os.Getenv("API_ADMIN_USERNAME")
+	if username == "" {
+		username = "admin"
 	}
-
-	// Set response headers and write response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(response)
-
-	// Update metrics
-	requestCount.WithLabelValues(r.Method, http.StatusText(http.StatusOK), r.URL.Path).Inc()
-	requestDuration.WithLabelValues(r.Method, r.URL.Path).Observe(time.Since(start).Seconds())
-	requestLatency.WithLabelValues(r.Method, r.URL.Path).Observe(time.Since(start).Seconds())
-}
-
-// Handle login requests
-func loginHandler(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-
-	// Simulate login processing
-	w.Write([]byte("Login successful"))
-
-	// Update metrics
-	statusCode := http.StatusOK
-	requestCount.WithLabelValues(r.Method, http.StatusText(statusCode), r.URL.Path).Inc()
-	requestDuration.WithLabelValues(r.Method, r.URL.Path).Observe(time.Since(start).Seconds())
-	requestLatency.WithLabelValues(r.Method, r.URL.Path).Observe(time.Since(start).Seconds())
+	if err := auth.EnsureAdmin(ctx, username, os.Getenv("API_ADMIN_PASSWORD")); err != nil {
+		return nil, err
+	}
+	return auth, nil
 }
 
-// Health check handler
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+// healthHandler reports the API as healthy together with the state of the Prometheus process.
+// Prometheus being down does not make the API unhealthy.
+func healthHandler(supervisor *Supervisor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"status":     "OK",
+			"prometheus": supervisor.Status(),
+		})
+	}
 }
 
 // Handle graceful shutdown
@@ -0,0 +1,341 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	maxBodyBytes    = 1 << 20
+
+	// 與 resources 表的 VARCHAR 長度一致
+	maxResourceName = 100
+	maxResourceType = 50
+)
+
+// ResourceList 是列表接口的響應
+type ResourceList struct {
+	Items    []Resource `json:"items"`
+	Total    int        `json:"total"`
+	Page     int        `json:"page"`
+	PageSize int        `json:"page_size"`
+}
+
+// resourcePatch 是 PATCH 的請求體，沒有出現的字段保持不變
+type resourcePatch struct {
+	Name *string `json:"name"`
+	Type *string `json:"type"`
+}
+
+type resourceAPI struct {
+	store ResourceStore
+}
+
+// RegisterResourceRoutes 在 mux 上註冊 /api/v1/resources 的 CRUD 接口
+//
+//	GET    /api/v1/resources?type=&sort=-created_at&page=1&page_size=20
+//	POST   /api/v1/resources
+//	GET    /api/v1/resources/{id}
+//	PUT    /api/v1/resources/{id}
+//	PATCH  /api/v1/resources/{id}
+//	DELETE /api/v1/resources/{id}
+//
+// 單個資源的響應帶有根據 updated_at 生成的 ETag，PUT、PATCH 和 DELETE 帶上 If-Match 時，
+// 資源已被其他請求修改則返回 412
+//
+// auth 不為 nil 時，讀取需要 read 權限，修改需要 write 權限
+func RegisterResourceRoutes(mux *http.ServeMux, store ResourceStore, auth *Authenticator) {
+	api := &resourceAPI{store: store}
+	handle := func(pattern, scope string, h http.HandlerFunc) {
+		if auth == nil {
+			mux.Handle(pattern, h)
+			return
+		}
+		mux.Handle(pattern, auth.Require(scope, h))
+	}
+	handle("GET /api/v1/resources", ScopeRead, api.list)
+	handle("POST /api/v1/resources", ScopeWrite, api.create)
+	handle("GET /api/v1/resources/{id}", ScopeRead, api.get)
+	handle("PUT /api/v1/resources/{id}", ScopeWrite, api.replace)
+	handle("PATCH /api/v1/resources/{id}", ScopeWrite, api.patch)
+	handle("DELETE /api/v1/resources/{id}", ScopeWrite, api.delete)
+}
+
+func (a *resourceAPI) list(w http.ResponseWriter, r *http.Request) {
+	q, page, err := parseResourceQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, total, err := a.store.List(r.Context(), q)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if items == nil {
+		items = []Resource{}
+	}
+	writeJSON(w, http.StatusOK, ResourceList{Items: items, Total: total, Page: page, PageSize: q.Limit})
+}
+
+func (a *resourceAPI) create(w http.ResponseWriter, r *http.Request) {
+	var in ResourceInput
+	if err := decodeJSON(w, r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateResourceInput(in); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	res, err := a.store.Create(r.Context(), in)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/resources/%d", res.ID))
+	writeResource(w, http.StatusCreated, res)
+}
+
+func (a *resourceAPI) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := resourceID(w, r)
+	if !ok {
+		return
+	}
+	res, err := a.store.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && match == resourceETag(res) {
+		w.Header().Set("ETag", resourceETag(res))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeResource(w, http.StatusOK, res)
+}
+
+func (a *resourceAPI) replace(w http.ResponseWriter, r *http.Request) {
+	id, ok := resourceID(w, r)
+	if !ok {
+		return
+	}
+	version, ok := ifMatchVersion(w, r, id)
+	if !ok {
+		return
+	}
+
+	var in ResourceInput
+	if err := decodeJSON(w, r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateResourceInput(in); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	res, err := a.store.Update(r.Context(), id, in, version)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeResource(w, http.StatusOK, res)
+}
+
+func (a *resourceAPI) patch(w http.ResponseWriter, r *http.Request) {
+	id, ok := resourceID(w, r)
+	if !ok {
+		return
+	}
+	version, ok := ifMatchVersion(w, r, id)
+	if !ok {
+		return
+	}
+
+	var p resourcePatch
+	if err := decodeJSON(w, r, &p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	current, err := a.store.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	// 沒有 If-Match 時以讀到的版本為條件，合併期間資源被修改同樣返回 412
+	if version.IsZero() {
+		version = current.UpdatedAt
+	}
+
+	in := ResourceInput{Name: current.Name, Type: current.Type}
+	if p.Name != nil {
+		in.Name = *p.Name
+	}
+	if p.Type != nil {
+		in.Type = *p.Type
+	}
+	if err := validateResourceInput(in); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	res, err := a.store.Update(r.Context(), id, in, version)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeResource(w, http.StatusOK, res)
+}
+
+func (a *resourceAPI) delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := resourceID(w, r)
+	if !ok {
+		return
+	}
+	version, ok := ifMatchVersion(w, r, id)
+	if !ok {
+		return
+	}
+	if err := a.store.Delete(r.Context(), id, version); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseResourceQuery 解析列表的過濾、排序與分頁參數，返回查詢和頁碼
+func parseResourceQuery(r *http.Request) (ResourceQuery, int, error) {
+	values := r.URL.Query()
+	q := ResourceQuery{Type: values.Get("type"), Sort: "id", Limit: defaultPageSize}
+
+	if sort := values.Get("sort"); sort != "" {
+		q.Sort, q.Desc = strings.CutPrefix(sort, "-")
+		if _, ok := resourceSortFields[q.Sort]; !ok {
+			return q, 0, fmt.Errorf("invalid sort field %q", q.Sort)
+		}
+	}
+
+	page := 1
+	if v := values.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return q, 0, errors.New("page must be a positive integer")
+		}
+		page = n
+	}
+	if v := values.Get("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return q, 0, fmt.Errorf("page_size must be between 1 and %d", maxPageSize)
+		}
+		q.Limit = n
+	}
+	q.Offset = (page - 1) * q.Limit
+	return q, page, nil
+}
+
+func validateResourceInput(in ResourceInput) error {
+	var errs []error
+	if strings.TrimSpace(in.Name) == "" {
+		errs = append(errs, errors.New("name is required"))
+	} else if utf8.RuneCountInString(in.Name) > maxResourceName {
+		errs = append(errs, fmt.Errorf("name must be at most %d characters", maxResourceName))
+	}
+	if strings.TrimSpace(in.Type) == "" {
+		errs = append(errs, errors.New("type is required"))
+	} else if utf8.RuneCountInString(in.Type) > maxResourceType {
+		errs = append(errs, fmt.Errorf("type must be at most %d characters", maxResourceType))
+	}
+	return errors.Join(errs...)
+}
+
+// decodeJSON 解碼請求體中的單個 JSON 對象，拒絕未知字段
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return errors.New("invalid JSON body: unexpected data after the object")
+	}
+	return nil
+}
+
+func resourceID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		writeError(w, http.StatusBadRequest, "invalid resource id")
+		return 0, false
+	}
+	return id, true
+}
+
+// resourceETag 根據 updated_at 生成資源的 ETag，每次修改都會變化
+func resourceETag(res Resource) string {
+	return fmt.Sprintf(`"%d-%d"`, res.ID, res.UpdatedAt.UnixMicro())
+}
+
+// ifMatchVersion 從 If-Match 中取出預期的 updated_at，沒有 If-Match 或為 * 時返回零值
+// ETag 格式不正確或不屬於該資源時直接響應 412
+func ifMatchVersion(w http.ResponseWriter, r *http.Request, id int64) (time.Time, bool) {
+	match := strings.TrimSpace(r.Header.Get("If-Match"))
+	if match == "" || match == "*" {
+		return time.Time{}, true
+	}
+	for _, tag := range strings.Split(match, ",") {
+		tag = strings.Trim(strings.TrimSpace(tag), `"`)
+		idPart, micros, ok := strings.Cut(tag, "-")
+		if !ok || idPart != strconv.FormatInt(id, 10) {
+			continue
+		}
+		if n, err := strconv.ParseInt(micros, 10, 64); err == nil {
+			return time.UnixMicro(n).UTC(), true
+		}
+	}
+	writeError(w, http.StatusPreconditionFailed, ErrResourceConflict.Error())
+	return time.Time{}, false
+}
+
+func writeResource(w http.ResponseWriter, status int, res Resource) {
+	w.Header().Set("ETag", resourceETag(res))
+	writeJSON(w, status, res)
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrResourceNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrResourceConflict):
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+	default:
+		log.Printf("Resource store error: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
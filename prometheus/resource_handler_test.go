@@ -0,0 +1,174 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newResourceServer(t *testing.T) http.Handler {
+	t.Helper()
+	mux := http.NewServeMux()
+	RegisterResourceRoutes(mux, NewMemoryResourceStore(), nil)
+	return mux
+}
+
+func doJSON(handler http.Handler, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func createResource(t *testing.T, handler http.Handler, name, typ string) Resource {
+	t.Helper()
+	w := doJSON(handler, http.MethodPost, "/api/v1/resources", `{"name":"`+name+`","type":"`+typ+`"}`, nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var res Resource
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "/api/v1/resources/"+strconv.FormatInt(res.ID, 10), w.Header().Get("Location"))
+	assert.Equal(t, resourceETag(res), w.Header().Get("ETag"))
+	return res
+}
+
+func TestResourceList(t *testing.T) {
+	handler := newResourceServer(t)
+	createResource(t, handler, "Resource C", "Type 1")
+	createResource(t, handler, "Resource A", "Type 2")
+	createResource(t, handler, "Resource B", "Type 1")
+
+	list := func(query string) ResourceList {
+		w := doJSON(handler, http.MethodGet, "/api/v1/resources"+query, "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page ResourceList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page
+	}
+	names := func(page ResourceList) []string {
+		var out []string
+		for _, r := range page.Items {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	page := list("")
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, defaultPageSize, page.PageSize)
+	assert.Equal(t, []string{"Resource C", "Resource A", "Resource B"}, names(page))
+
+	assert.Equal(t, []string{"Resource A", "Resource B", "Resource C"}, names(list("?sort=name")))
+	assert.Equal(t, []string{"Resource C", "Resource B"}, names(list("?type=Type+1&sort=-name")))
+
+	page = list("?sort=name&page=2&page_size=2")
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, 2, page.Page)
+	assert.Equal(t, []string{"Resource C"}, names(page))
+
+	empty := list("?type=missing")
+	assert.NotNil(t, empty.Items)
+	assert.Zero(t, empty.Total)
+
+	for _, query := range []string{"?sort=password", "?page=0", "?page_size=101", "?page=x"} {
+		w := doJSON(handler, http.MethodGet, "/api/v1/resources"+query, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestResourceCreateValidation(t *testing.T) {
+	handler := newResourceServer(t)
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"name":"a","type":"b"`, http.StatusBadRequest},
+		{`{"name":"a","type":"b","owner":"c"}`, http.StatusBadRequest},
+		{`{"name":"a","type":"b"}{}`, http.StatusBadRequest},
+		{`{"name":" ","type":"b"}`, http.StatusUnprocessableEntity},
+		{`{"name":"a"}`, http.StatusUnprocessableEntity},
+		{`{"name":"` + strings.Repeat("a", maxResourceName+1) + `","type":"b"}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		w := doJSON(handler, http.MethodPost, "/api/v1/resources", tt.body, nil)
+		assert.Equal(t, tt.code, w.Code, tt.body)
+		assert.Contains(t, w.Body.String(), `"error"`)
+	}
+}
+
+func TestResourceGetUpdateDelete(t *testing.T) {
+	handler := newResourceServer(t)
+	res := createResource(t, handler, "Resource A", "Type 1")
+	path := "/api/v1/resources/" + strconv.FormatInt(res.ID, 10)
+
+	w := doJSON(handler, http.MethodGet, path, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+
+	w = doJSON(handler, http.MethodGet, path, "", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// PUT 帶當前 ETag 成功，ETag 隨之變化
+	w = doJSON(handler, http.MethodPut, path, `{"name":"Resource B","type":"Type 2"}`, http.Header{"If-Match": {etag}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	newETag := w.Header().Get("ETag")
+	assert.NotEqual(t, etag, newETag)
+
+	// 舊 ETag 的更新與刪除都返回 412
+	w = doJSON(handler, http.MethodPut, path, `{"name":"Resource C","type":"Type 3"}`, http.Header{"If-Match": {etag}})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	w = doJSON(handler, http.MethodDelete, path, "", http.Header{"If-Match": {etag}})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	w = doJSON(handler, http.MethodPatch, path, `{"name":"x"}`, http.Header{"If-Match": {`"garbage"`}})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	// PATCH 只修改出現的字段
+	w = doJSON(handler, http.MethodPatch, path, `{"type":"Type 9"}`, http.Header{"If-Match": {newETag}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var patched Resource
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &patched))
+	assert.Equal(t, "Resource B", patched.Name)
+	assert.Equal(t, "Type 9", patched.Type)
+	assert.True(t, patched.UpdatedAt.After(res.UpdatedAt))
+	assert.Equal(t, res.CreatedAt, patched.CreatedAt)
+
+	w = doJSON(handler, http.MethodPatch, path, `{"name":""}`, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = doJSON(handler, http.MethodDelete, path, "", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = doJSON(handler, http.MethodGet, path, "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doJSON(handler, http.MethodPut, path, `{"name":"a","type":"b"}`, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doJSON(handler, http.MethodGet, "/api/v1/resources/abc", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doJSON(handler, http.MethodPost, path, "", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestVersionParam(t *testing.T) {
+	assert.Nil(t, versionParam(time.Time{}))
+
+	// 經過 double 換算會舍入錯誤的微秒值也必須原樣保留
+	for _, micros := range []int64{1700000000123457, 1712345678999999, 1000000} {
+		version := time.UnixMicro(micros)
+		param := versionParam(version)
+		parsed, err := time.Parse("2006-01-02 15:04:05.000000", param.(string))
+		require.NoError(t, err)
+		assert.Equal(t, micros, parsed.UnixMicro())
+	}
+}
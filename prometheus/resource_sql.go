@@ -0,0 +1,161 @@
+package prometheus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLResourceStore 把資源存儲在 PostgreSQL 的 resources 表中
+// updated_at 由 checkAndCreateTable 創建的觸發器維護
+type SQLResourceStore struct {
+	db *sql.DB
+}
+
+var _ ResourceStore = (*SQLResourceStore)(nil)
+
+func NewSQLResourceStore(db *sql.DB) *SQLResourceStore {
+	return &SQLResourceStore{db: db}
+}
+
+const resourceColumns = "id, name, type, created_at, updated_at"
+
+// versionCondition 直接比較 updated_at 與預期的時間戳，$2 為 NULL 時不檢查版本
+// 不經過 extract(epoch ...)：它在 PostgreSQL 14 之前返回 double，換算微秒時的舍入會讓有效的 ETag 比較失敗
+const versionCondition = "($2::timestamp IS NULL OR updated_at = $2::timestamp)"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanResource(row rowScanner) (Resource, error) {
+	var r Resource
+	if err := row.Scan(&r.ID, &r.Name, &r.Type, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return Resource{}, err
+	}
+	r.CreatedAt, r.UpdatedAt = r.CreatedAt.UTC(), r.UpdatedAt.UTC()
+	return r, nil
+}
+
+// versionParam 把預期的 updated_at 格式化為微秒精度的 UTC 文本，由 PostgreSQL 精確解析，零值表示不檢查版本
+// updated_at 沒有時區，scanResource 讀出的值按 UTC 解釋，這裡使用同樣的表示
+func versionParam(version time.Time) any {
+	if version.IsZero() {
+		return nil
+	}
+	return version.UTC().Format("2006-01-02 15:04:05.000000")
+}
+
+func (s *SQLResourceStore) List(ctx context.Context, q ResourceQuery) ([]Resource, int, error) {
+	var where string
+	var args []any
+	if q.Type != "" {
+		where = " WHERE type = $1"
+		args = append(args, q.Type)
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT count(*) FROM resources"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := resourceSortFields[q.Sort]
+	if !ok {
+		column = "id"
+	}
+	direction := "ASC"
+	if q.Desc {
+		direction = "DESC"
+	}
+	query := fmt.Sprintf("SELECT %s FROM resources%s ORDER BY %s %s, id ASC", resourceColumns, where, column, direction)
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	args = append(args, q.Offset)
+	query += fmt.Sprintf(" OFFSET $%d", len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := []Resource{}
+	for rows.Next() {
+		r, err := scanResource(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, r)
+	}
+	return items, total, rows.Err()
+}
+
+func (s *SQLResourceStore) Get(ctx context.Context, id int64) (Resource, error) {
+	r, err := scanResource(s.db.QueryRowContext(ctx,
+		"SELECT "+resourceColumns+" FROM resources WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Resource{}, ErrResourceNotFound
+	}
+	return r, err
+}
+
+func (s *SQLResourceStore) Create(ctx context.Context, in ResourceInput) (Resource, error) {
+	return scanResource(s.db.QueryRowContext(ctx,
+		"INSERT INTO resources (name, type) VALUES ($1, $2) RETURNING "+resourceColumns, in.Name, in.Type))
+}
+
+func (s *SQLResourceStore) Update(ctx context.Context, id int64, in ResourceInput, version time.Time) (Resource, error) {
+	r, err := scanResource(s.db.QueryRowContext(ctx,
+		"UPDATE resources SET name = $3, type = $4 WHERE id = $1 AND "+versionCondition+" RETURNING "+resourceColumns,
+		id, versionParam(version), in.Name, in.Type))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Resource{}, s.missOrConflict(ctx, id)
+	}
+	return r, err
+}
+
+func (s *SQLResourceStore) Delete(ctx context.Context, id int64, version time.Time) error {
+	result, err := s.db.ExecContext(ctx,
+		"DELETE FROM resources WHERE id = $1 AND "+versionCondition, id, versionParam(version))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return s.missOrConflict(ctx, id)
+	}
+	return nil
+}
+
+// missOrConflict 在條件更新沒有影響任何行時區分資源不存在與版本衝突
+func (s *SQLResourceStore) missOrConflict(ctx context.Context, id int64) error {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM resources WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrResourceConflict
+	}
+	return ErrResourceNotFound
+}
+
+// resourceTriggerSQL 讓每次 UPDATE 都刷新 updated_at，樂觀並發控制依賴它
+// 使用 clock_timestamp() 而不是 now()，同一事務中的多次更新也會得到不同的時間
+var resourceTriggerSQL = strings.Join([]string{
+	`CREATE OR REPLACE FUNCTION resources_set_updated_at() RETURNS trigger AS $$
+	BEGIN
+		NEW.updated_at = clock_timestamp();
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS resources_set_updated_at ON resources`,
+	`CREATE TRIGGER resources_set_updated_at BEFORE UPDATE ON resources
+		FOR EACH ROW EXECUTE FUNCTION resources_set_updated_at()`,
+	`CREATE INDEX IF NOT EXISTS resources_type_idx ON resources (type)`,
+}, ";\n")
@@ -0,0 +1,182 @@
+package prometheus
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrResourceNotFound 表示資源不存在
+	ErrResourceNotFound = errors.New("resource not found")
+	// ErrResourceConflict 表示資源在讀取之後已被修改（updated_at 與預期不一致）
+	ErrResourceConflict = errors.New("resource was modified concurrently")
+)
+
+// Resource 對應 resources 表的一行
+type Resource struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ResourceInput 是創建或替換資源時可以寫入的字段
+type ResourceInput struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ResourceQuery 描述列表查詢的過濾、排序與分頁
+type ResourceQuery struct {
+	Type   string // 不為空時只返回該類型的資源
+	Sort   string // resourceSortFields 中的字段
+	Desc   bool
+	Limit  int
+	Offset int
+}
+
+// resourceSortFields 是允許排序的字段及其列名，SQL 中只使用這裡的列名
+var resourceSortFields = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"type":       "type",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// ResourceStore 是資源的存儲
+// Update 與 Delete 的 version 不為零值時，只有當前 updated_at 等於 version 才會修改，否則返回 ErrResourceConflict
+type ResourceStore interface {
+	List(ctx context.Context, q ResourceQuery) (items []Resource, total int, err error)
+	Get(ctx context.Context, id int64) (Resource, error)
+	Create(ctx context.Context, in ResourceInput) (Resource, error)
+	Update(ctx context.Context, id int64, in ResourceInput, version time.Time) (Resource, error)
+	Delete(ctx context.Context, id int64, version time.Time) error
+}
+
+// MemoryResourceStore 是內存中的 ResourceStore，用於測試和沒有數據庫時運行
+type MemoryResourceStore struct {
+	mu        sync.Mutex
+	resources map[int64]Resource
+	nextID    int64
+	last      time.Time
+	now       func() time.Time
+}
+
+var _ ResourceStore = (*MemoryResourceStore)(nil)
+
+func NewMemoryResourceStore() *MemoryResourceStore {
+	return &MemoryResourceStore{resources: make(map[int64]Resource), now: time.Now}
+}
+
+// timestampLocked 返回微秒精度（與 PostgreSQL 一致）且嚴格遞增的時間，保證每次修改都改變 updated_at
+func (s *MemoryResourceStore) timestampLocked() time.Time {
+	t := s.now().UTC().Truncate(time.Microsecond)
+	if !t.After(s.last) {
+		t = s.last.Add(time.Microsecond)
+	}
+	s.last = t
+	return t
+}
+
+func (s *MemoryResourceStore) List(ctx context.Context, q ResourceQuery) ([]Resource, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []Resource
+	for _, r := range s.resources {
+		if q.Type == "" || r.Type == q.Type {
+			items = append(items, r)
+		}
+	}
+	slices.SortFunc(items, func(a, b Resource) int {
+		c := compareResources(a, b, q.Sort)
+		if q.Desc {
+			c = -c
+		}
+		return cmp.Or(c, cmp.Compare(a.ID, b.ID))
+	})
+
+	total := len(items)
+	start := min(q.Offset, total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+	return items[start:end], total, nil
+}
+
+func compareResources(a, b Resource, field string) int {
+	switch field {
+	case "name":
+		return strings.Compare(a.Name, b.Name)
+	case "type":
+		return strings.Compare(a.Type, b.Type)
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case "updated_at":
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	default:
+		return cmp.Compare(a.ID, b.ID)
+	}
+}
+
+func (s *MemoryResourceStore) Get(ctx context.Context, id int64) (Resource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.resources[id]
+	if !ok {
+		return Resource{}, ErrResourceNotFound
+	}
+	return r, nil
+}
+
+func (s *MemoryResourceStore) Create(ctx context.Context, in ResourceInput) (Resource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	now := s.timestampLocked()
+	r := Resource{ID: s.nextID, Name: in.Name, Type: in.Type, CreatedAt: now, UpdatedAt: now}
+	s.resources[r.ID] = r
+	return r, nil
+}
+
+func (s *MemoryResourceStore) Update(ctx context.Context, id int64, in ResourceInput, version time.Time) (Resource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.checkLocked(id, version)
+	if err != nil {
+		return Resource{}, err
+	}
+	r.Name, r.Type = in.Name, in.Type
+	r.UpdatedAt = s.timestampLocked()
+	s.resources[id] = r
+	return r, nil
+}
+
+func (s *MemoryResourceStore) Delete(ctx context.Context, id int64, version time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.checkLocked(id, version); err != nil {
+		return err
+	}
+	delete(s.resources, id)
+	return nil
+}
+
+func (s *MemoryResourceStore) checkLocked(id int64, version time.Time) (Resource, error) {
+	r, ok := s.resources[id]
+	if !ok {
+		return Resource{}, ErrResourceNotFound
+	}
+	if !version.IsZero() && !r.UpdatedAt.Equal(version) {
+		return Resource{}, ErrResourceConflict
+	}
+	return r, nil
+}
@@ -0,0 +1,209 @@
+package prometheus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+// RulesFile 是 -generateRules 寫入的規則文件名，與 prometheus.yml 放在同一目錄
+const RulesFile = "prometheus.rules.yml"
+
+// SLO 描述一個路由的服務水平目標
+// Latency 為 0 時是可用性 SLO（非 5xx 響應的比例），否則是延遲 SLO（耗時不超過 Latency 的請求比例）
+type SLO struct {
+	Name      string        // 規則與告警中的 slo 標籤，只能包含字母、數字、- 和 _
+	Route     string        // Middleware 記錄的 route 標籤
+	Objective float64       // 目標比例，如 0.999
+	Latency   time.Duration // 必須是 http_request_duration_seconds 的一個桶邊界
+}
+
+// DefaultSLOs 是 PrometheusApiApplication 各路由的服務水平目標
+var DefaultSLOs = []SLO{
+	{Name: "resource-availability", Route: "GET /api/v1/resources", Objective: 0.999},
+	{Name: "resource-latency", Route: "GET /api/v1/resources", Objective: 0.99, Latency: 250 * time.Millisecond},
+	{Name: "login-availability", Route: "POST /api/v1/login", Objective: 0.999},
+	// 登錄需要一次 bcrypt 比較（DefaultCost 約數十毫秒）
+	{Name: "login-latency", Route: "POST /api/v1/login", Objective: 0.99, Latency: 250 * time.Millisecond},
+}
+
+// burnRateAlert 是一組多窗口燃燒率告警：長窗口與短窗口的錯誤率都超過 Factor 倍的錯誤預算時觸發
+// 按 Google SRE Workbook 的建議，30 天預算消耗 2% 或 5% 時呼叫值班，10% 時開工單
+type burnRateAlert struct {
+	Severity string
+	Long     string
+	Short    string
+	Factor   float64
+	For      string
+}
+
+var burnRateAlerts = []burnRateAlert{
+	{Severity: "page", Long: "1h", Short: "5m", Factor: 14.4, For: "2m"},
+	{Severity: "page", Long: "6h", Short: "30m", Factor: 6, For: "15m"},
+	{Severity: "ticket", Long: "1d", Short: "2h", Factor: 3, For: "1h"},
+	{Severity: "ticket", Long: "3d", Short: "6h", Factor: 1, For: "3h"},
+}
+
+// sliWindows 是需要記錄錯誤率的所有窗口
+var sliWindows = []string{"5m", "30m", "1h", "2h", "6h", "1d", "3d"}
+
+// RuleFile 對應 Prometheus 規則文件的結構
+type RuleFile struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+type RuleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule 是記錄規則（Record）或告警規則（Alert）
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Validate 檢查 SLO 是否可以生成規則
+func (s SLO) Validate() error {
+	var errs []error
+	if s.Name == "" || strings.Trim(s.Name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_") != "" {
+		errs = append(errs, fmt.Errorf("slo name %q must only contain letters, digits, - and _", s.Name))
+	}
+	if s.Route == "" {
+		errs = append(errs, fmt.Errorf("slo %s: route is required", s.Name))
+	}
+	if s.Objective <= 0 || s.Objective >= 1 {
+		errs = append(errs, fmt.Errorf("slo %s: objective must be between 0 and 1", s.Name))
+	}
+	if s.Latency > 0 && bucketLabel(s.Latency) == "" {
+		errs = append(errs, fmt.Errorf("slo %s: latency %s is not a bucket of http_request_duration_seconds", s.Name, s.Latency))
+	}
+	return errors.Join(errs...)
+}
+
+// bucketLabel 返回 latency 對應的直方圖桶的 le 標籤，不是桶邊界時返回空字符串
+func bucketLabel(latency time.Duration) string {
+	for _, bucket := range prometheus.DefBuckets {
+		if bucket == latency.Seconds() {
+			return strconv.FormatFloat(bucket, 'g', -1, 64)
+		}
+	}
+	return ""
+}
+
+// errorRatio 返回 SLO 在 window 內的錯誤率表達式
+func (s SLO) errorRatio(window string) string {
+	route := strconv.Quote(s.Route)
+	if s.Latency > 0 {
+		return fmt.Sprintf(
+			`1 - (sum(rate(http_request_duration_seconds_bucket{route=%s,le="%s"}[%s])) / sum(rate(http_request_duration_seconds_count{route=%s}[%s])))`,
+			route, bucketLabel(s.Latency), window, route, window)
+	}
+	return fmt.Sprintf(
+		`sum(rate(http_requests_total{route=%s,code=~"5.."}[%s])) / sum(rate(http_requests_total{route=%s}[%s]))`,
+		route, window, route, window)
+}
+
+func recordName(window string) string {
+	return "slo:sli_error:ratio_rate" + window
+}
+
+// group 生成一個 SLO 的記錄規則與燃燒率告警
+func (s SLO) group() RuleGroup {
+	labels := map[string]string{"slo": s.Name, "route": s.Route}
+	group := RuleGroup{Name: "slo-" + s.Name}
+
+	for _, window := range sliWindows {
+		group.Rules = append(group.Rules, Rule{
+			Record: recordName(window),
+			Expr:   s.errorRatio(window),
+			Labels: labels,
+		})
+	}
+
+	budget := 1 - s.Objective
+	selector := fmt.Sprintf(`{slo=%q}`, s.Name)
+	for _, a := range burnRateAlerts {
+		threshold := strconv.FormatFloat(a.Factor*budget, 'g', 6, 64)
+		group.Rules = append(group.Rules, Rule{
+			Alert: "SLOErrorBudgetBurn",
+			Expr: fmt.Sprintf("%s%s > %s and %s%s > %s",
+				recordName(a.Long), selector, threshold, recordName(a.Short), selector, threshold),
+			For: a.For,
+			Labels: map[string]string{
+				"slo":      s.Name,
+				"route":    s.Route,
+				"severity": a.Severity,
+				"window":   a.Long,
+			},
+			Annotations: map[string]string{
+				"summary":     fmt.Sprintf("%s is burning its error budget %gx too fast", s.Name, a.Factor),
+				"description": fmt.Sprintf("Error ratio over %s and %s is above %s (objective %g).", a.Long, a.Short, threshold, s.Objective),
+			},
+		})
+	}
+	return group
+}
+
+// GenerateRules 將 SLO 生成為 Prometheus 規則文件的 YAML
+func GenerateRules(slos []SLO) ([]byte, error) {
+	var file RuleFile
+	names := make(map[string]bool)
+	for _, slo := range slos {
+		if err := slo.Validate(); err != nil {
+			return nil, err
+		}
+		if names[slo.Name] {
+			return nil, fmt.Errorf("duplicate slo name %q", slo.Name)
+		}
+		names[slo.Name] = true
+		file.Groups = append(file.Groups, slo.group())
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(file); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteRules 生成規則並寫入 dir 下的 RulesFile，返回寫入的路徑
+func WriteRules(dir string, slos []SLO) (string, error) {
+	data, err := GenerateRules(slos)
+	if err != nil {
+		return "", err
+	}
+	header := "# Generated by prometheus.WriteRules (go run . -generateRules). DO NOT EDIT.\n"
+	path := filepath.Join(dir, RulesFile)
+	if err := os.WriteFile(path, append([]byte(header), data...), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// GenerateRulesFile 將 DefaultSLOs 的規則寫到當前目錄（prometheus.yml 所在目錄）
+func GenerateRulesFile() {
+	path, err := WriteRules(".", DefaultSLOs)
+	if err != nil {
+		log.Fatalf("Failed to generate rules: %v", err)
+	}
+	log.Printf("Rules written to %s", path)
+}
@@ -0,0 +1,106 @@
+package prometheus
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func parseRules(t *testing.T, slos []SLO) RuleFile {
+	t.Helper()
+	data, err := GenerateRules(slos)
+	require.NoError(t, err)
+
+	var file RuleFile
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	require.NoError(t, decoder.Decode(&file))
+	return file
+}
+
+func TestGenerateRulesAvailability(t *testing.T) {
+	file := parseRules(t, []SLO{{Name: "checkout", Route: "/api/v1/checkout", Objective: 0.999}})
+	require.Len(t, file.Groups, 1)
+
+	group := file.Groups[0]
+	assert.Equal(t, "slo-checkout", group.Name)
+	require.Len(t, group.Rules, len(sliWindows)+len(burnRateAlerts))
+
+	records := map[string]Rule{}
+	for _, rule := range group.Rules[:len(sliWindows)] {
+		records[rule.Record] = rule
+		assert.Equal(t, map[string]string{"slo": "checkout", "route": "/api/v1/checkout"}, rule.Labels)
+	}
+	assert.Equal(t,
+		`sum(rate(http_requests_total{route="/api/v1/checkout",code=~"5.."}[5m])) / sum(rate(http_requests_total{route="/api/v1/checkout"}[5m]))`,
+		records["slo:sli_error:ratio_rate5m"].Expr)
+
+	// 每個告警引用的窗口都有對應的記錄規則
+	alerts := group.Rules[len(sliWindows):]
+	for _, alert := range alerts {
+		assert.Equal(t, "SLOErrorBudgetBurn", alert.Alert)
+		assert.NotEmpty(t, alert.For)
+		for _, field := range strings.Fields(alert.Expr) {
+			if name, _, ok := strings.Cut(field, "{"); ok {
+				assert.Contains(t, records, name)
+			}
+		}
+	}
+
+	// 1 小時 / 5 分鐘窗口按 14.4 倍燃燒率呼叫值班
+	assert.Equal(t,
+		`slo:sli_error:ratio_rate1h{slo="checkout"} > 0.0144 and slo:sli_error:ratio_rate5m{slo="checkout"} > 0.0144`,
+		alerts[0].Expr)
+	assert.Equal(t, "page", alerts[0].Labels["severity"])
+	assert.Equal(t, "ticket", alerts[len(alerts)-1].Labels["severity"])
+}
+
+func TestGenerateRulesLatency(t *testing.T) {
+	file := parseRules(t, []SLO{{Name: "search-latency", Route: "/search", Objective: 0.99, Latency: 500 * time.Millisecond}})
+
+	expr := file.Groups[0].Rules[0].Expr
+	assert.Contains(t, expr, `http_request_duration_seconds_bucket{route="/search",le="0.5"}[5m]`)
+	assert.Contains(t, expr, `http_request_duration_seconds_count{route="/search"}[5m]`)
+	assert.True(t, strings.HasPrefix(expr, "1 - "))
+}
+
+func TestGenerateRulesValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		slos []SLO
+	}{
+		{"missing name", []SLO{{Route: "/a", Objective: 0.99}}},
+		{"invalid name", []SLO{{Name: "a b", Route: "/a", Objective: 0.99}}},
+		{"missing route", []SLO{{Name: "a", Objective: 0.99}}},
+		{"objective out of range", []SLO{{Name: "a", Route: "/a", Objective: 1}}},
+		{"latency not a bucket", []SLO{{Name: "a", Route: "/a", Objective: 0.99, Latency: 300 * time.Millisecond}}},
+		{"duplicate name", []SLO{{Name: "a", Route: "/a", Objective: 0.99}, {Name: "a", Route: "/b", Objective: 0.99}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GenerateRules(tt.slos)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWriteRules(t *testing.T) {
+	dir := t.TempDir()
+	path, err := WriteRules(dir, DefaultSLOs)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, RulesFile), path)
+
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// 倉庫中的規則文件與 DefaultSLOs 保持一致，修改 SLO 後需要重新運行 -generateRules
+	committed, err := os.ReadFile(filepath.Join("..", RulesFile))
+	require.NoError(t, err)
+	assert.Equal(t, string(written), strings.ReplaceAll(string(committed), "\r\n", "\n"))
+}
@@ -0,0 +1,215 @@
+package prometheus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Supervisor 的狀態
+const (
+	StateDisabled    = "disabled"    // 未啟用，API 單獨運行
+	StateUnavailable = "unavailable" // 找不到 prometheus 可執行文件或無法寫入配置
+	StateStarting    = "starting"
+	StateRunning     = "running"
+	StateBackoff     = "backoff" // 進程退出，等待重啟
+	StateStopped     = "stopped"
+)
+
+// ErrPrometheusNotFound 表示 PATH 中找不到 prometheus 可執行文件
+var ErrPrometheusNotFound = errors.New("prometheus binary not found")
+
+// SupervisorOptions 描述如何運行 prometheus 進程，零值字段使用默認值
+type SupervisorOptions struct {
+	Disabled   bool
+	Binary     string           // 可執行文件名或路徑，默認 prometheus
+	ConfigFile string           // 啟動前寫入 Config 的路徑，默認 ConfigFile
+	Config     PrometheusConfig // 默認 DefaultPrometheusConfig("localhost:8080")
+	Args       []string         // 默認 --config.file=<ConfigFile>
+	// 進程退出後等待 MinBackoff 重啟，連續退出時翻倍直到 MaxBackoff
+	// 進程運行超過 StableAfter 後退出時重新從 MinBackoff 開始
+	MinBackoff  time.Duration // 默認 1s
+	MaxBackoff  time.Duration // 默認 1m
+	StableAfter time.Duration // 默認 1m
+	StopTimeout time.Duration // 停止時發送 SIGTERM 後等待多久強制結束，默認 5s
+	Logger      *log.Logger   // 記錄狀態變化以及進程的 stdout/stderr，默認 log.Default()
+}
+
+// SupervisorOptionsFromEnv 讀取 PROMETHEUS_DISABLED 和 PROMETHEUS_BINARY
+func SupervisorOptionsFromEnv() SupervisorOptions {
+	disabled, _ := strconv.ParseBool(os.Getenv("PROMETHEUS_DISABLED"))
+	return SupervisorOptions{Disabled: disabled, Binary: os.Getenv("PROMETHEUS_BINARY")}
+}
+
+// SupervisorStatus 是 Supervisor 的當前狀態，由 /health 返回
+type SupervisorStatus struct {
+	State     string    `json:"state"`
+	PID       int       `json:"pid,omitempty"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+// Supervisor 運行 prometheus 進程，進程退出時按退避時間重啟，不會影響 API 本身
+type Supervisor struct {
+	opts SupervisorOptions
+
+	mu     sync.Mutex
+	status SupervisorStatus
+}
+
+func NewSupervisor(opts SupervisorOptions) *Supervisor {
+	if opts.Binary == "" {
+		opts.Binary = "prometheus"
+	}
+	if opts.ConfigFile == "" {
+		opts.ConfigFile = ConfigFile
+	}
+	if opts.Config.ScrapeConfigs == nil {
+		opts.Config = DefaultPrometheusConfig("localhost:8080")
+	}
+	if opts.Args == nil {
+		opts.Args = []string{"--config.file=" + opts.ConfigFile}
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(time.Minute, opts.MinBackoff)
+	}
+	if opts.StableAfter <= 0 {
+		opts.StableAfter = time.Minute
+	}
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = 5 * time.Second
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+
+	s := &Supervisor{opts: opts}
+	s.status = SupervisorStatus{State: StateStopped, Since: time.Now()}
+	if opts.Disabled {
+		s.status.State = StateDisabled
+	}
+	return s
+}
+
+// Status 返回當前狀態的副本
+func (s *Supervisor) Status() SupervisorStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *Supervisor) setState(state string, pid int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.State != state {
+		s.status.Since = time.Now()
+	}
+	s.status.State = state
+	s.status.PID = pid
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+}
+
+// Run 寫入配置並運行 prometheus，直到 ctx 取消
+// 未啟用時立即返回 nil；找不到可執行文件或無法寫入配置時返回錯誤，調用方可以只記錄日誌並繼續運行 API
+func (s *Supervisor) Run(ctx context.Context) error {
+	if s.opts.Disabled {
+		s.opts.Logger.Println("Prometheus supervisor disabled")
+		return nil
+	}
+
+	path, err := exec.LookPath(s.opts.Binary)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrPrometheusNotFound, s.opts.Binary)
+		s.setState(StateUnavailable, 0, err)
+		return err
+	}
+	if err := s.opts.Config.WriteFile(s.opts.ConfigFile); err != nil {
+		err = fmt.Errorf("write prometheus config: %w", err)
+		s.setState(StateUnavailable, 0, err)
+		return err
+	}
+
+	backoff := s.opts.MinBackoff
+	for {
+		started := time.Now()
+		err := s.runOnce(ctx, path)
+		if ctx.Err() != nil {
+			s.setState(StateStopped, 0, nil)
+			s.opts.Logger.Println("Prometheus stopped")
+			return nil
+		}
+
+		if time.Since(started) >= s.opts.StableAfter {
+			backoff = s.opts.MinBackoff
+		}
+		if err == nil {
+			err = errors.New("prometheus exited")
+		}
+		s.mu.Lock()
+		s.status.Restarts++
+		s.mu.Unlock()
+		s.setState(StateBackoff, 0, err)
+		s.opts.Logger.Printf("Prometheus exited: %v, restarting in %s", err, backoff)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.setState(StateStopped, 0, nil)
+			return nil
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, s.opts.MaxBackoff)
+	}
+}
+
+// runOnce 啟動一次進程並等待它退出，ctx 取消時先發送 SIGTERM，超過 StopTimeout 後強制結束
+func (s *Supervisor) runOnce(ctx context.Context, path string) error {
+	s.setState(StateStarting, 0, nil)
+
+	cmd := exec.CommandContext(ctx, path, s.opts.Args...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = s.opts.StopTimeout
+
+	stdout := s.logWriter("stdout")
+	stderr := s.logWriter("stderr")
+	defer stdout.Close()
+	defer stderr.Close()
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	s.setState(StateRunning, cmd.Process.Pid, nil)
+	s.opts.Logger.Printf("Prometheus started (pid %d). Access it at http://localhost:9090", cmd.Process.Pid)
+	return cmd.Wait()
+}
+
+// logWriter 把進程的輸出按行寫入 Logger
+func (s *Supervisor) logWriter(stream string) io.WriteCloser {
+	r, w := io.Pipe()
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			s.opts.Logger.Printf("prometheus %s: %s", stream, scanner.Text())
+		}
+		// 單行超過緩衝區時丟棄剩餘輸出，避免阻塞進程
+		io.Copy(io.Discard, r)
+	}()
+	return w
+}
@@ -0,0 +1,160 @@
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHelperProcess 不是真正的測試，Supervisor 測試以它代替 prometheus 可執行文件
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	fmt.Println("helper started")
+	fmt.Fprintln(os.Stderr, "helper warning")
+	if os.Getenv("HELPER_MODE") == "exit" {
+		os.Exit(3)
+	}
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+// syncBuffer 是可以被多個 goroutine 寫入的日誌緩衝區
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func helperSupervisor(t *testing.T, mode string) (*Supervisor, *syncBuffer, string) {
+	t.Helper()
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	t.Setenv("HELPER_MODE", mode)
+
+	logs := &syncBuffer{}
+	config := filepath.Join(t.TempDir(), ConfigFile)
+	sup := NewSupervisor(SupervisorOptions{
+		Binary:      os.Args[0],
+		Args:        []string{"-test.run=^TestHelperProcess$"},
+		ConfigFile:  config,
+		MinBackoff:  10 * time.Millisecond,
+		MaxBackoff:  40 * time.Millisecond,
+		StopTimeout: time.Second,
+		Logger:      log.New(logs, "", 0),
+	})
+	return sup, logs, config
+}
+
+// runSupervisor 在後台運行 sup，測試結束時停止並等待 Run 返回
+// done 在 Run 返回後關閉，此時 *runErr 是 Run 的返回值
+func runSupervisor(t *testing.T, sup *Supervisor) (cancel context.CancelFunc, done <-chan struct{}, runErr *error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	runErr = new(error)
+	go func() {
+		defer close(finished)
+		*runErr = sup.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-finished
+	})
+	return cancel, finished, runErr
+}
+
+func TestSupervisorRestartsWithBackoff(t *testing.T) {
+	sup, logs, config := helperSupervisor(t, "exit")
+	runSupervisor(t, sup)
+
+	require.Eventually(t, func() bool { return sup.Status().Restarts >= 3 }, 10*time.Second, 10*time.Millisecond)
+	status := sup.Status()
+	assert.Contains(t, status.LastError, "exit status 3")
+
+	// 進程的 stdout 和 stderr 寫入日誌
+	assert.Eventually(t, func() bool {
+		out := logs.String()
+		return strings.Contains(out, "prometheus stdout: helper started") &&
+			strings.Contains(out, "prometheus stderr: helper warning")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, logs.String(), "restarting in 40ms")
+
+	written, err := os.ReadFile(config)
+	require.NoError(t, err)
+	assert.Contains(t, string(written), "localhost:8080")
+}
+
+func TestSupervisorStopsOnCancel(t *testing.T) {
+	sup, _, _ := helperSupervisor(t, "sleep")
+	cancel, done, runErr := runSupervisor(t, sup)
+
+	require.Eventually(t, func() bool { return sup.Status().State == StateRunning }, 10*time.Second, 10*time.Millisecond)
+	assert.NotZero(t, sup.Status().PID)
+
+	cancel()
+	select {
+	case <-done:
+		assert.NoError(t, *runErr)
+	case <-time.After(10 * time.Second):
+		t.Fatal("supervisor did not stop")
+	}
+	assert.Equal(t, StateStopped, sup.Status().State)
+	assert.Zero(t, sup.Status().Restarts)
+}
+
+func TestSupervisorUnavailableAndDisabled(t *testing.T) {
+	sup := NewSupervisor(SupervisorOptions{Binary: "prometheus-binary-that-does-not-exist", ConfigFile: filepath.Join(t.TempDir(), ConfigFile)})
+	err := sup.Run(context.Background())
+	assert.ErrorIs(t, err, ErrPrometheusNotFound)
+	assert.Equal(t, StateUnavailable, sup.Status().State)
+
+	t.Setenv("PROMETHEUS_DISABLED", "true")
+	sup = NewSupervisor(SupervisorOptionsFromEnv())
+	assert.NoError(t, sup.Run(context.Background()))
+	assert.Equal(t, StateDisabled, sup.Status().State)
+
+	// /health 返回 Prometheus 的狀態，API 本身仍然健康
+	w := httptest.NewRecorder()
+	healthHandler(sup)(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Status     string           `json:"status"`
+		Prometheus SupervisorStatus `json:"prometheus"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "OK", body.Status)
+	assert.Equal(t, StateDisabled, body.Prometheus.State)
+}
+
+func TestPrometheusConfigMatchesCommittedFile(t *testing.T) {
+	data, err := DefaultPrometheusConfig("localhost:8080").Marshal()
+	require.NoError(t, err)
+	committed, err := os.ReadFile(filepath.Join("..", ConfigFile))
+	require.NoError(t, err)
+	assert.Equal(t, string(data), strings.ReplaceAll(string(committed), "\r\n", "\n"))
+}
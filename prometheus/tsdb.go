@@ -0,0 +1,290 @@
+package prometheus
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Sample 是某個時間點的值
+type Sample struct {
+	T time.Time
+	V float64
+}
+
+// TSDBOptions 描述採集間隔與保留時長，每個序列保存 Retention/Interval 個樣本
+type TSDBOptions struct {
+	Interval  time.Duration // 默認 15s
+	Retention time.Duration // 默認 1h
+	MaxSeries int           // 超過後不再記錄新序列，默認 10000
+}
+
+// TSDB 定期從 Gatherer 採集指標，在內存環形緩衝區中保存最近的樣本
+// 直方圖按 Prometheus 的方式展開為 _bucket、_sum 和 _count 序列，摘要展開為帶 quantile 標籤的序列和 _sum、_count
+type TSDB struct {
+	gatherer prometheus.Gatherer
+	opts     TSDBOptions
+	capacity int
+	now      func() time.Time
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+// series 是一個時間序列，samples 是容量固定的環形緩衝區
+type series struct {
+	name    string
+	labels  map[string]string
+	samples []Sample
+	head    int // 下一個寫入位置
+	full    bool
+}
+
+func NewTSDB(gatherer prometheus.Gatherer, opts TSDBOptions) *TSDB {
+	if opts.Interval <= 0 {
+		opts.Interval = 15 * time.Second
+	}
+	if opts.Retention < opts.Interval {
+		opts.Retention = max(time.Hour, opts.Interval)
+	}
+	if opts.MaxSeries <= 0 {
+		opts.MaxSeries = 10000
+	}
+	return &TSDB{
+		gatherer: gatherer,
+		opts:     opts,
+		capacity: int(opts.Retention / opts.Interval),
+		now:      time.Now,
+		series:   make(map[string]*series),
+	}
+}
+
+// Run 每隔 Interval 採集一次，直到 ctx 取消
+func (db *TSDB) Run(ctx context.Context) {
+	ticker := time.NewTicker(db.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := db.Scrape(); err != nil {
+			log.Printf("TSDB scrape failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scrape 採集一次 Gatherer 中的全部指標
+func (db *TSDB) Scrape() error {
+	families, err := db.gatherer.Gather()
+	now := db.now()
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			db.appendMetric(name, mf.GetType(), m, labels, now)
+		}
+	}
+	// Gather 出錯時仍會返回能採集到的指標
+	return err
+}
+
+func (db *TSDB) appendMetric(name string, typ dto.MetricType, m *dto.Metric, labels map[string]string, t time.Time) {
+	switch typ {
+	case dto.MetricType_COUNTER:
+		db.Append(name, labels, t, m.GetCounter().GetValue())
+	case dto.MetricType_GAUGE:
+		db.Append(name, labels, t, m.GetGauge().GetValue())
+	case dto.MetricType_UNTYPED:
+		db.Append(name, labels, t, m.GetUntyped().GetValue())
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		for _, b := range h.GetBucket() {
+			if math.IsInf(b.GetUpperBound(), 1) {
+				continue
+			}
+			db.Append(name+"_bucket", withLabel(labels, "le", formatLe(b.GetUpperBound())), t, float64(b.GetCumulativeCount()))
+		}
+		db.Append(name+"_bucket", withLabel(labels, "le", "+Inf"), t, float64(h.GetSampleCount()))
+		db.Append(name+"_sum", labels, t, h.GetSampleSum())
+		db.Append(name+"_count", labels, t, float64(h.GetSampleCount()))
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		for _, q := range s.GetQuantile() {
+			db.Append(name, withLabel(labels, "quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)), t, q.GetValue())
+		}
+		db.Append(name+"_sum", labels, t, s.GetSampleSum())
+		db.Append(name+"_count", labels, t, float64(s.GetSampleCount()))
+	}
+}
+
+// Append 向序列追加一個樣本，緩衝區滿時覆蓋最舊的樣本
+func (db *TSDB) Append(name string, labels map[string]string, t time.Time, v float64) {
+	key := seriesKey(name, labels)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	s, ok := db.series[key]
+	if !ok {
+		if len(db.series) >= db.opts.MaxSeries {
+			return
+		}
+		s = &series{name: name, labels: labels, samples: make([]Sample, db.capacity)}
+		db.series[key] = s
+	}
+	s.samples[s.head] = Sample{T: t, V: v}
+	s.head = (s.head + 1) % len(s.samples)
+	if s.head == 0 {
+		s.full = true
+	}
+}
+
+// SeriesResult 是一個序列在時間範圍內的樣本
+type SeriesResult struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// Select 返回名為 name 的序列在 (end-rng, end] 內的樣本，按時間排序
+func (db *TSDB) Select(name string, end time.Time, rng time.Duration) []SeriesResult {
+	start := end.Add(-rng)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var result []SeriesResult
+	for _, s := range db.series {
+		if s.name != name {
+			continue
+		}
+		var samples []Sample
+		for _, sample := range s.ordered() {
+			if sample.T.After(start) && !sample.T.After(end) {
+				samples = append(samples, sample)
+			}
+		}
+		if len(samples) > 0 {
+			result = append(result, SeriesResult{Labels: s.labels, Samples: samples})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return seriesKey(name, result[i].Labels) < seriesKey(name, result[j].Labels)
+	})
+	return result
+}
+
+// ordered 按寫入順序返回樣本
+func (s *series) ordered() []Sample {
+	if !s.full {
+		return s.samples[:s.head]
+	}
+	return append(s.samples[s.head:len(s.samples):len(s.samples)], s.samples[:s.head]...)
+}
+
+// counterRate 返回計數器在樣本範圍內每秒的平均增長，計數器重置（值變小）時從 0 重新計算
+// 與 Prometheus 的 rate 不同，這裡不做範圍邊界的外推
+func counterRate(samples []Sample) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+	var increase float64
+	for i := 1; i < len(samples); i++ {
+		delta := samples[i].V - samples[i-1].V
+		if delta < 0 {
+			delta = samples[i].V
+		}
+		increase += delta
+	}
+	elapsed := samples[len(samples)-1].T.Sub(samples[0].T).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return increase / elapsed, true
+}
+
+// bucket 是直方圖的一個累積桶
+type bucket struct {
+	upper float64
+	count float64
+}
+
+// bucketQuantile 按 histogram_quantile 的方式在桶內線性插值求 q 分位數
+// buckets 必須包含 +Inf 桶，沒有觀測值時返回 NaN
+func bucketQuantile(q float64, buckets []bucket) float64 {
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].upper < buckets[j].upper })
+	if len(buckets) < 2 || !math.IsInf(buckets[len(buckets)-1].upper, 1) {
+		return math.NaN()
+	}
+	total := buckets[len(buckets)-1].count
+	if total == 0 {
+		return math.NaN()
+	}
+
+	rank := q * total
+	i := sort.Search(len(buckets), func(i int) bool { return buckets[i].count >= rank })
+	if i == len(buckets)-1 {
+		// 落在 +Inf 桶中時返回最大的有限上界
+		return buckets[len(buckets)-2].upper
+	}
+
+	lower, prevCount := 0.0, 0.0
+	if i > 0 {
+		lower, prevCount = buckets[i-1].upper, buckets[i-1].count
+	} else if buckets[0].upper <= 0 {
+		return buckets[0].upper
+	}
+	inBucket := buckets[i].count - prevCount
+	if inBucket == 0 {
+		return buckets[i].upper
+	}
+	return lower + (buckets[i].upper-lower)*(rank-prevCount)/inBucket
+}
+
+func seriesKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out[name] = value
+	return out
+}
+
+func formatLe(upper float64) string {
+	if math.IsInf(upper, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(upper, 'g', -1, 64)
+}
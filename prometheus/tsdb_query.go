@@ -0,0 +1,223 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 查詢函數
+const (
+	queryRaw      = "raw"      // 範圍內的原始樣本
+	queryRate     = "rate"     // 每個序列每秒的增長
+	querySum      = "sum"      // 按 by 標籤分組求 rate 的和，相當於 sum by (...) (rate(name[range]))
+	queryQuantile = "quantile" // 直方圖分位數，相當於 histogram_quantile(q, sum by (le, ...) (rate(name_bucket[range])))
+)
+
+const defaultQueryRange = 5 * time.Minute
+
+// jsonFloat 把 NaN 和 ±Inf 編碼為字符串，其他值編碼為數字
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return []byte(strconv.Quote(formatLe(v))), nil
+	}
+	return []byte(strconv.FormatFloat(v, 'g', -1, 64)), nil
+}
+
+type querySample struct {
+	T time.Time `json:"t"`
+	V jsonFloat `json:"v"`
+}
+
+type queryResult struct {
+	Labels  map[string]string `json:"labels"`
+	Value   *jsonFloat        `json:"value,omitempty"`
+	Samples []querySample     `json:"samples,omitempty"`
+}
+
+type queryResponse struct {
+	Name   string        `json:"name"`
+	Func   string        `json:"func"`
+	Range  string        `json:"range"`
+	Time   time.Time     `json:"time"`
+	Result []queryResult `json:"result"`
+}
+
+// query 描述 /debug/metrics/query 的參數
+type query struct {
+	name string
+	fn   string
+	rng  time.Duration
+	by   []string
+	q    float64
+}
+
+// QueryHandler 處理 GET /debug/metrics/query?name=&range=&func=&by=&q=
+//
+//	name   指標名，quantile 時為直方圖名（不帶 _bucket）
+//	range  時間範圍，如 5m，默認 5m，不超過保留時長
+//	func   raw（默認）、rate、sum 或 quantile
+//	by     sum 和 quantile 的分組標籤，逗號分隔
+//	q      quantile 的分位數，0 到 1
+func (db *TSDB) QueryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := db.parseQuery(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		end := db.now()
+		writeJSON(w, http.StatusOK, queryResponse{
+			Name:   q.name,
+			Func:   q.fn,
+			Range:  q.rng.String(),
+			Time:   end,
+			Result: db.evaluate(q, end),
+		})
+	})
+}
+
+func (db *TSDB) parseQuery(r *http.Request) (query, error) {
+	values := r.URL.Query()
+	q := query{name: values.Get("name"), fn: values.Get("func"), rng: defaultQueryRange}
+	if q.name == "" {
+		return q, errors.New("name is required")
+	}
+	if q.fn == "" {
+		q.fn = queryRaw
+	}
+	switch q.fn {
+	case queryRaw, queryRate, querySum, queryQuantile:
+	default:
+		return q, fmt.Errorf("func must be one of %s, %s, %s or %s", queryRaw, queryRate, querySum, queryQuantile)
+	}
+
+	if v := values.Get("range"); v != "" {
+		rng, err := time.ParseDuration(v)
+		if err != nil || rng <= 0 {
+			return q, fmt.Errorf("invalid range %q", v)
+		}
+		if rng > db.opts.Retention {
+			return q, fmt.Errorf("range must not exceed the retention of %s", db.opts.Retention)
+		}
+		q.rng = rng
+	}
+	if v := values.Get("by"); v != "" {
+		q.by = strings.Split(v, ",")
+	}
+	if q.fn == queryQuantile {
+		f, err := strconv.ParseFloat(values.Get("q"), 64)
+		if err != nil || f < 0 || f > 1 {
+			return q, errors.New("q must be a number between 0 and 1")
+		}
+		q.q = f
+	}
+	return q, nil
+}
+
+func (db *TSDB) evaluate(q query, end time.Time) []queryResult {
+	results := []queryResult{}
+	switch q.fn {
+	case queryRaw:
+		for _, s := range db.Select(q.name, end, q.rng) {
+			samples := make([]querySample, len(s.Samples))
+			for i, sample := range s.Samples {
+				samples[i] = querySample{T: sample.T, V: jsonFloat(sample.V)}
+			}
+			results = append(results, queryResult{Labels: s.Labels, Samples: samples})
+		}
+	case queryRate:
+		for _, s := range db.Select(q.name, end, q.rng) {
+			if v, ok := counterRate(s.Samples); ok {
+				results = append(results, valueResult(s.Labels, v))
+			}
+		}
+	case querySum:
+		groups := map[string]*queryResult{}
+		for _, s := range db.Select(q.name, end, q.rng) {
+			v, ok := counterRate(s.Samples)
+			if !ok {
+				continue
+			}
+			labels := pickLabels(s.Labels, q.by)
+			key := seriesKey("", labels)
+			if g, ok := groups[key]; ok {
+				*g.Value += jsonFloat(v)
+			} else {
+				g := valueResult(labels, v)
+				groups[key] = &g
+			}
+		}
+		results = sortedResults(groups)
+	case queryQuantile:
+		buckets := map[string]map[float64]float64{}
+		groupLabels := map[string]map[string]string{}
+		for _, s := range db.Select(q.name+"_bucket", end, q.rng) {
+			upper, err := strconv.ParseFloat(s.Labels["le"], 64)
+			if err != nil {
+				continue
+			}
+			v, ok := counterRate(s.Samples)
+			if !ok {
+				continue
+			}
+			labels := pickLabels(s.Labels, q.by)
+			key := seriesKey("", labels)
+			if buckets[key] == nil {
+				buckets[key] = map[float64]float64{}
+				groupLabels[key] = labels
+			}
+			buckets[key][upper] += v
+		}
+		groups := map[string]*queryResult{}
+		for key, byUpper := range buckets {
+			list := make([]bucket, 0, len(byUpper))
+			for upper, count := range byUpper {
+				list = append(list, bucket{upper: upper, count: count})
+			}
+			if v := bucketQuantile(q.q, list); !math.IsNaN(v) {
+				g := valueResult(groupLabels[key], v)
+				groups[key] = &g
+			}
+		}
+		results = sortedResults(groups)
+	}
+	return results
+}
+
+func valueResult(labels map[string]string, v float64) queryResult {
+	value := jsonFloat(v)
+	return queryResult{Labels: labels, Value: &value}
+}
+
+// pickLabels 返回 labels 中 by 列出的標籤
+func pickLabels(labels map[string]string, by []string) map[string]string {
+	out := make(map[string]string, len(by))
+	for _, name := range by {
+		if v, ok := labels[name]; ok {
+			out[name] = v
+		}
+	}
+	return out
+}
+
+func sortedResults(groups map[string]*queryResult) []queryResult {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	results := make([]queryResult, 0, len(keys))
+	for _, key := range keys {
+		results = append(results, *groups[key])
+	}
+	return results
+}
@@ -0,0 +1,244 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tsdbEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestTSDB 返回時鐘固定在 tsdbEpoch+now 的 TSDB
+func newTestTSDB(opts TSDBOptions, now time.Duration) *TSDB {
+	db := NewTSDB(prometheus.NewRegistry(), opts)
+	db.now = func() time.Time { return tsdbEpoch.Add(now) }
+	return db
+}
+
+// appendSeries 從 tsdbEpoch 開始每 15s 追加一個樣本
+func appendSeries(db *TSDB, name string, labels map[string]string, values ...float64) {
+	for i, v := range values {
+		db.Append(name, labels, tsdbEpoch.Add(time.Duration(i)*15*time.Second), v)
+	}
+}
+
+type testQueryResponse struct {
+	Name   string `json:"name"`
+	Func   string `json:"func"`
+	Range  string `json:"range"`
+	Result []struct {
+		Labels  map[string]string `json:"labels"`
+		Value   *float64          `json:"value"`
+		Samples []struct {
+			T time.Time `json:"t"`
+			V float64   `json:"v"`
+		} `json:"samples"`
+	} `json:"result"`
+}
+
+func doQuery(t *testing.T, db *TSDB, query string) (int, testQueryResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	db.QueryHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/metrics/query?"+query, nil))
+	var resp testQueryResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	}
+	return w.Code, resp
+}
+
+func TestCounterRate(t *testing.T) {
+	at := func(s int) time.Time { return tsdbEpoch.Add(time.Duration(s) * time.Second) }
+
+	v, ok := counterRate([]Sample{{at(0), 10}, {at(15), 40}, {at(30), 70}})
+	require.True(t, ok)
+	assert.InDelta(t, 2.0, v, 1e-9)
+
+	// 計數器在 15s 到 30s 之間重置為 0 後增長到 20
+	v, ok = counterRate([]Sample{{at(0), 10}, {at(15), 40}, {at(30), 20}})
+	require.True(t, ok)
+	assert.InDelta(t, 50.0/30, v, 1e-9)
+
+	_, ok = counterRate([]Sample{{at(0), 10}})
+	assert.False(t, ok)
+}
+
+func TestBucketQuantile(t *testing.T) {
+	buckets := []bucket{{math.Inf(1), 100}, {0.1, 50}, {0.5, 90}, {1, 100}}
+	assert.InDelta(t, 0.05, bucketQuantile(0.25, buckets), 1e-9)
+	assert.InDelta(t, 0.1, bucketQuantile(0.5, buckets), 1e-9)
+	assert.InDelta(t, 0.3, bucketQuantile(0.7, buckets), 1e-9)
+	assert.InDelta(t, 0.75, bucketQuantile(0.95, buckets), 1e-9)
+
+	// 落在 +Inf 桶中時返回最大的有限上界
+	assert.Equal(t, 1.0, bucketQuantile(0.99, []bucket{{1, 90}, {math.Inf(1), 100}}))
+	assert.True(t, math.IsNaN(bucketQuantile(0.5, []bucket{{1, 0}, {math.Inf(1), 0}})))
+	assert.True(t, math.IsNaN(bucketQuantile(0.5, []bucket{{1, 10}})))
+}
+
+func TestTSDBRingBufferOverwritesOldestSamples(t *testing.T) {
+	db := newTestTSDB(TSDBOptions{Interval: 15 * time.Second, Retention: time.Minute}, 0)
+	appendSeries(db, "up", nil, 1, 2, 3, 4, 5, 6)
+
+	result := db.Select("up", tsdbEpoch.Add(time.Hour), 2*time.Hour)
+	require.Len(t, result, 1)
+	var values []float64
+	for _, s := range result[0].Samples {
+		values = append(values, s.V)
+	}
+	assert.Equal(t, []float64{3, 4, 5, 6}, values)
+
+	// 範圍為左開右閉區間
+	result = db.Select("up", tsdbEpoch.Add(75*time.Second), 30*time.Second)
+	require.Len(t, result, 1)
+	require.Len(t, result[0].Samples, 2)
+	assert.Equal(t, 5.0, result[0].Samples[0].V)
+	assert.Equal(t, 6.0, result[0].Samples[1].V)
+}
+
+func TestTSDBMaxSeries(t *testing.T) {
+	db := newTestTSDB(TSDBOptions{MaxSeries: 1}, 0)
+	db.Append("a", nil, tsdbEpoch, 1)
+	db.Append("b", nil, tsdbEpoch, 1)
+	assert.Len(t, db.Select("a", tsdbEpoch, time.Minute), 1)
+	assert.Empty(t, db.Select("b", tsdbEpoch, time.Minute))
+}
+
+func TestTSDBScrapeExpandsHistograms(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total"}, []string{"code"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_seconds", Buckets: []float64{0.1, 1}})
+	reg.MustRegister(counter, histogram)
+	counter.WithLabelValues("200").Add(3)
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(2)
+
+	db := NewTSDB(reg, TSDBOptions{})
+	db.now = func() time.Time { return tsdbEpoch }
+	require.NoError(t, db.Scrape())
+
+	latest := func(name string, labels map[string]string) float64 {
+		t.Helper()
+		for _, s := range db.Select(name, tsdbEpoch, time.Minute) {
+			if seriesKey(name, s.Labels) == seriesKey(name, labels) {
+				return s.Samples[len(s.Samples)-1].V
+			}
+		}
+		t.Fatalf("series %s not found", seriesKey(name, labels))
+		return 0
+	}
+	assert.Equal(t, 3.0, latest("test_total", map[string]string{"code": "200"}))
+	assert.Equal(t, 1.0, latest("test_seconds_bucket", map[string]string{"le": "0.1"}))
+	assert.Equal(t, 2.0, latest("test_seconds_bucket", map[string]string{"le": "1"}))
+	assert.Equal(t, 3.0, latest("test_seconds_bucket", map[string]string{"le": "+Inf"}))
+	assert.Equal(t, 3.0, latest("test_seconds_count", map[string]string{}))
+	assert.InDelta(t, 2.55, latest("test_seconds_sum", map[string]string{}), 1e-9)
+}
+
+func TestQueryHandler(t *testing.T) {
+	db := newTestTSDB(TSDBOptions{}, time.Minute)
+	get := map[string]string{"method": "GET", "route": "/a"}
+	post := map[string]string{"method": "POST", "route": "/a"}
+	other := map[string]string{"method": "GET", "route": "/b"}
+	// 60s 內 GET /a 增長 120，POST /a 增長 60（中途重置），GET /b 增長 30
+	appendSeries(db, "requests_total", get, 0, 30, 60, 90, 120)
+	appendSeries(db, "requests_total", post, 10, 25, 15, 30, 45)
+	appendSeries(db, "requests_total", other, 0, 0, 10, 20, 30)
+
+	code, resp := doQuery(t, db, "name=requests_total&range=5m")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "raw", resp.Func)
+	assert.Equal(t, "5m0s", resp.Range)
+	require.Len(t, resp.Result, 3)
+	assert.Len(t, resp.Result[0].Samples, 5)
+
+	code, resp = doQuery(t, db, "name=requests_total&func=rate")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Result, 3)
+	assert.Equal(t, get, resp.Result[0].Labels)
+	assert.InDelta(t, 2.0, *resp.Result[0].Value, 1e-9)
+	assert.Equal(t, other, resp.Result[1].Labels)
+	assert.InDelta(t, 0.5, *resp.Result[1].Value, 1e-9)
+	assert.Equal(t, post, resp.Result[2].Labels)
+	assert.InDelta(t, 1.0, *resp.Result[2].Value, 1e-9)
+
+	code, resp = doQuery(t, db, "name=requests_total&func=sum&by=route")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Result, 2)
+	assert.Equal(t, map[string]string{"route": "/a"}, resp.Result[0].Labels)
+	assert.InDelta(t, 3.0, *resp.Result[0].Value, 1e-9)
+	assert.Equal(t, map[string]string{"route": "/b"}, resp.Result[1].Labels)
+	assert.InDelta(t, 0.5, *resp.Result[1].Value, 1e-9)
+
+	code, resp = doQuery(t, db, "name=requests_total&func=sum")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Result, 1)
+	assert.Empty(t, resp.Result[0].Labels)
+	assert.InDelta(t, 3.5, *resp.Result[0].Value, 1e-9)
+
+	// 只有最後 30s 的樣本：GET /a 增長 60
+	code, resp = doQuery(t, db, "name=requests_total&func=rate&range=31s")
+	require.Equal(t, http.StatusOK, code)
+	assert.InDelta(t, 2.0, *resp.Result[0].Value, 1e-9)
+}
+
+func TestQueryHandlerQuantile(t *testing.T) {
+	db := newTestTSDB(TSDBOptions{}, time.Minute)
+	// 兩個實例的桶在 15s 內分別增長，合計 0.1: 50, 0.5: 90, 1: 100, +Inf: 100
+	for _, b := range []struct {
+		le       string
+		a, other float64
+	}{{"0.1", 30, 20}, {"0.5", 50, 40}, {"1", 60, 40}, {"+Inf", 60, 40}} {
+		appendSeries(db, "latency_seconds_bucket", map[string]string{"instance": "a", "le": b.le}, 0, b.a)
+		appendSeries(db, "latency_seconds_bucket", map[string]string{"instance": "b", "le": b.le}, 0, b.other)
+	}
+
+	code, resp := doQuery(t, db, "name=latency_seconds&func=quantile&q=0.7")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Result, 1)
+	assert.InDelta(t, 0.3, *resp.Result[0].Value, 1e-9)
+
+	code, resp = doQuery(t, db, "name=latency_seconds&func=quantile&q=0.5&by=instance")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Result, 2)
+	assert.Equal(t, map[string]string{"instance": "a"}, resp.Result[0].Labels)
+	assert.InDelta(t, 0.1, *resp.Result[0].Value, 1e-9)
+	assert.Equal(t, map[string]string{"instance": "b"}, resp.Result[1].Labels)
+	assert.InDelta(t, 0.1, *resp.Result[1].Value, 1e-9)
+
+	// 沒有數據時結果為空數組
+	code, resp = doQuery(t, db, "name=missing&func=quantile&q=0.5")
+	require.Equal(t, http.StatusOK, code)
+	assert.NotNil(t, resp.Result)
+	assert.Empty(t, resp.Result)
+}
+
+func TestQueryHandlerErrors(t *testing.T) {
+	db := newTestTSDB(TSDBOptions{}, 0)
+	for _, query := range []string{
+		"",
+		"name=up&func=avg",
+		"name=up&range=abc",
+		"name=up&range=-1m",
+		"name=up&range=2h",
+		"name=up&func=quantile",
+		"name=up&func=quantile&q=1.5",
+	} {
+		code, _ := doQuery(t, db, query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestJSONFloat(t *testing.T) {
+	data, err := json.Marshal([]jsonFloat{1.5, jsonFloat(math.Inf(1)), jsonFloat(math.NaN())})
+	require.NoError(t, err)
+	assert.Equal(t, `[1.5,"+Inf","NaN"]`, string(data))
+}
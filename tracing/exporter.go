@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Exporter 在 Setup 時創建 span 導出器
+type Exporter func(ctx context.Context) (sdktrace.SpanExporter, error)
+
+// 各導出器的默認地址
+const (
+	DefaultOTLPGRPCEndpoint = "localhost:4317"
+	DefaultOTLPHTTPEndpoint = "http://localhost:4318/v1/traces"
+	DefaultZipkinEndpoint   = "http://localhost:9411/api/v2/spans"
+	DefaultJaegerEndpoint   = "http://localhost:14268/api/traces"
+)
+
+// OTLPGRPC 通過 gRPC 發送到 OTLP 收集器，endpoint 為 host:port，為空時使用 DefaultOTLPGRPCEndpoint
+func OTLPGRPC(endpoint string, insecure bool) Exporter {
+	return func(ctx context.Context) (sdktrace.SpanExporter, error) {
+		if endpoint == "" {
+			endpoint = DefaultOTLPGRPCEndpoint
+		}
+		options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+		if insecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, options...)
+	}
+}
+
+// OTLPHTTP 通過 HTTP 發送到 OTLP 收集器，endpoint 為完整 URL，為空時使用 DefaultOTLPHTTPEndpoint
+func OTLPHTTP(endpoint string) Exporter {
+	return func(ctx context.Context) (sdktrace.SpanExporter, error) {
+		if endpoint == "" {
+			endpoint = DefaultOTLPHTTPEndpoint
+		}
+		return otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	}
+}
+
+// Zipkin 發送到 Zipkin，endpoint 為空時使用 DefaultZipkinEndpoint
+func Zipkin(endpoint string) Exporter {
+	return func(context.Context) (sdktrace.SpanExporter, error) {
+		if endpoint == "" {
+			endpoint = DefaultZipkinEndpoint
+		}
+		return zipkin.New(endpoint)
+	}
+}
+
+// Jaeger 發送到 Jaeger 收集器，endpoint 為空時使用 DefaultJaegerEndpoint
+func Jaeger(endpoint string) Exporter {
+	return func(context.Context) (sdktrace.SpanExporter, error) {
+		if endpoint == "" {
+			endpoint = DefaultJaegerEndpoint
+		}
+		return jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(endpoint)))
+	}
+}
+
+// Stdout 將 span 以 JSON 輸出到標準輸出，用於本地調試
+func Stdout() Exporter {
+	return func(context.Context) (sdktrace.SpanExporter, error) {
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	}
+}
+
+// InMemory 將 span 保存在 exporter 中，用於測試
+// Provider.Shutdown 之後 span 仍然保留，可以檢查關閉時發送的 span
+func InMemory(exporter *tracetest.InMemoryExporter) Exporter {
+	return func(context.Context) (sdktrace.SpanExporter, error) {
+		return keepOnShutdown{exporter}, nil
+	}
+}
+
+// keepOnShutdown 忽略 Shutdown，tracetest.InMemoryExporter 在 Shutdown 時會清空已保存的 span
+type keepOnShutdown struct {
+	*tracetest.InMemoryExporter
+}
+
+func (keepOnShutdown) Shutdown(context.Context) error { return nil }
+
+// ExporterByName 按名稱選擇導出器，用於從配置文件或命令行參數創建
+// 支持 otlp（HTTP）、otlp-grpc、zipkin、jaeger 與 stdout
+func ExporterByName(name, endpoint string) (Exporter, error) {
+	switch name {
+	case "otlp", "otlp-http":
+		return OTLPHTTP(endpoint), nil
+	case "otlp-grpc":
+		return OTLPGRPC(endpoint, true), nil
+	case "zipkin":
+		return Zipkin(endpoint), nil
+	case "jaeger":
+		return Jaeger(endpoint), nil
+	case "stdout":
+		return Stdout(), nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", name)
+	}
+}
@@ -2,6 +2,9 @@ package tracing
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"os"
 	"time"
 )
 
@@ -10,3 +13,41 @@ func doOperationWithCtx(ctx context.Context) error {
 	time.Sleep(100 * time.Millisecond)
 	return nil
 }
+
+// runDemo 使用指定的導出器記錄 xTimes 次操作，退出前發送所有 span
+// 服務名可通過 SERVICE_NAME 環境變量修改
+func runDemo(exporter Exporter, xTimes int) {
+	serviceName := os.Getenv("SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "MyService"
+	}
+
+	provider, err := Setup(context.Background(), Options{
+		ServiceName: serviceName,
+		Exporter:    exporter,
+	})
+	if err != nil {
+		log.Fatalf("初始化追踪器失敗: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Printf("關閉追踪器提供者時出錯: %v", err)
+		}
+	}()
+
+	tracer := provider.Tracer("example-tracer")
+
+	for i := 0; i < xTimes; i++ {
+		// 每次操作一個追踪 span
+		ctx, span := tracer.Start(context.Background(), "doOperation")
+		err := doOperationWithCtx(ctx)
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+
+	fmt.Println("Operations completed")
+}
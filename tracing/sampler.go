@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RatioSampler 按 TraceID 採樣 ratio 比例的追蹤，忽略上游的採樣決定
+func RatioSampler(ratio float64) sdktrace.Sampler {
+	return sdktrace.TraceIDRatioBased(ratio)
+}
+
+// ParentBased 沿用上游 span 的採樣決定，沒有上游時使用 root
+func ParentBased(root sdktrace.Sampler) sdktrace.Sampler {
+	return sdktrace.ParentBased(root)
+}
+
+// RateLimitedSampler 每秒最多採樣 perSecond 個追蹤，允許 perSecond 大小（至少 1 個）的突發
+type RateLimitedSampler struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+	now       func() time.Time
+}
+
+// RateLimited 創建 RateLimitedSampler，通常與 ParentBased 一起使用以保證同一追蹤的決定一致
+func RateLimited(perSecond float64) *RateLimitedSampler {
+	burst := math.Max(perSecond, 1)
+	return &RateLimitedSampler{perSecond: perSecond, burst: burst, tokens: burst, now: time.Now}
+}
+
+func (s *RateLimitedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := sdktrace.Drop
+	if s.take() {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s *RateLimitedSampler) Description() string {
+	return fmt.Sprintf("RateLimited{%g}", s.perSecond)
+}
+
+// take 按經過的時間補充令牌，有令牌時消耗一個並返回 true
+func (s *RateLimitedSampler) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !s.last.IsZero() {
+		s.tokens += now.Sub(s.last).Seconds() * s.perSecond
+		s.tokens = math.Min(s.tokens, s.burst)
+	}
+	s.last = now
+
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
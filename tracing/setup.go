@@ -0,0 +1,127 @@
+// Package tracing 提供 OpenTelemetry 追蹤的初始化，以及 Jaeger、Zipkin 的示例程序
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options 描述追蹤的導出方式、採樣策略與資源屬性
+type Options struct {
+	ServiceName    string // 必填
+	ServiceVersion string
+	Environment    string               // deployment.environment，如 production、staging
+	Attributes     []attribute.KeyValue // 附加的資源屬性
+	Exporter       Exporter             // 必填
+	// Sampler 為空時使用 ParentBased(AlwaysSample)
+	Sampler sdktrace.Sampler
+	// Synchronous 為 true 時每個 span 結束後立即導出，用於測試；默認批量導出
+	Synchronous bool
+	// SpanProcessors 附加的 span 處理器，在導出器之前調用
+	SpanProcessors []sdktrace.SpanProcessor
+	// TailSampling 不為空時，導出前按追蹤做尾部採樣，只導出包含錯誤、慢 span 或按比例選中的追蹤
+	TailSampling *TailSamplingOptions
+}
+
+// Provider 包裝 TracerProvider，Shutdown 會先發送所有未導出的 span
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// Setup 根據 Options 創建 TracerProvider，並設置為全局 TracerProvider 與 W3C 傳播器
+func Setup(ctx context.Context, opts Options) (*Provider, error) {
+	if opts.ServiceName == "" {
+		return nil, errors.New("tracing: ServiceName is required")
+	}
+	if opts.Exporter == nil {
+		return nil, errors.New("tracing: Exporter is required")
+	}
+
+	exporter, err := opts.Exporter(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("創建導出器失敗: %w", err)
+	}
+
+	res, err := newResource(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("創建資源失敗: %w", err)
+	}
+
+	sampler := opts.Sampler
+	if sampler == nil {
+		sampler = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+
+	tpOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+	}
+	for _, processor := range opts.SpanProcessors {
+		tpOptions = append(tpOptions, sdktrace.WithSpanProcessor(processor))
+	}
+	var processor sdktrace.SpanProcessor
+	if opts.Synchronous {
+		processor = sdktrace.NewSimpleSpanProcessor(exporter)
+	} else {
+		processor = sdktrace.NewBatchSpanProcessor(exporter)
+	}
+	if opts.TailSampling != nil {
+		if processor, err = NewTailSampler(processor, *opts.TailSampling); err != nil {
+			return nil, err
+		}
+	}
+	tpOptions = append(tpOptions, sdktrace.WithSpanProcessor(processor))
+
+	tp := sdktrace.NewTracerProvider(tpOptions...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return &Provider{tp: tp}, nil
+}
+
+func newResource(ctx context.Context, opts Options) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{semconv.ServiceNameKey.String(opts.ServiceName)}
+	if opts.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(opts.ServiceVersion))
+	}
+	if opts.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(opts.Environment))
+	}
+	attrs = append(attrs, opts.Attributes...)
+
+	// OTEL_RESOURCE_ATTRIBUTES 中的屬性會被 Options 中的同名屬性覆蓋
+	return resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(attrs...),
+	)
+}
+
+// Tracer 返回指定名稱的追蹤器
+func (p *Provider) Tracer(name string) trace.Tracer {
+	return p.tp.Tracer(name)
+}
+
+// TracerProvider 返回底層的 TracerProvider
+func (p *Provider) TracerProvider() *sdktrace.TracerProvider {
+	return p.tp
+}
+
+// Shutdown 發送所有未導出的 span 後關閉導出器，ctx 超時則放棄剩餘的 span
+// 返回後不需要再等待導出器
+func (p *Provider) Shutdown(ctx context.Context) error {
+	flushErr := p.tp.ForceFlush(ctx)
+	if err := p.tp.Shutdown(ctx); err != nil {
+		return errors.Join(flushErr, err)
+	}
+	return flushErr
+}
@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// restoreGlobals 在測試結束後恢復全局 TracerProvider 與傳播器
+func restoreGlobals(t *testing.T) {
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+}
+
+func TestSetupFlushesOnShutdown(t *testing.T) {
+	restoreGlobals(t)
+	exporter := tracetest.NewInMemoryExporter()
+
+	provider, err := Setup(context.Background(), Options{
+		ServiceName:    "checkout",
+		ServiceVersion: "1.2.3",
+		Environment:    "test",
+		Attributes:     []attribute.KeyValue{attribute.String("team", "payments")},
+		Exporter:       InMemory(exporter),
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, span := otel.Tracer("test").Start(context.Background(), "doOperation")
+		span.End()
+	}
+	// 批量導出時 span 在 Shutdown 前不會到達導出器
+	assert.Empty(t, exporter.GetSpans())
+
+	require.NoError(t, provider.Shutdown(context.Background()))
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+
+	attrs := map[attribute.Key]string{}
+	for _, kv := range spans[0].Resource.Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+	assert.Equal(t, "checkout", attrs["service.name"])
+	assert.Equal(t, "1.2.3", attrs["service.version"])
+	assert.Equal(t, "test", attrs["deployment.environment"])
+	assert.Equal(t, "payments", attrs["team"])
+}
+
+func TestSetupValidatesOptions(t *testing.T) {
+	_, err := Setup(context.Background(), Options{Exporter: InMemory(tracetest.NewInMemoryExporter())})
+	assert.Error(t, err)
+
+	_, err = Setup(context.Background(), Options{ServiceName: "checkout"})
+	assert.Error(t, err)
+}
+
+func TestExporterByName(t *testing.T) {
+	for _, name := range []string{"otlp", "otlp-http", "otlp-grpc", "zipkin", "jaeger", "stdout"} {
+		exporter, err := ExporterByName(name, "")
+		require.NoError(t, err, name)
+		assert.NotNil(t, exporter, name)
+	}
+	_, err := ExporterByName("carrier-pigeon", "")
+	assert.Error(t, err)
+}
+
+func TestParentBasedRatioSampler(t *testing.T) {
+	restoreGlobals(t)
+	exporter := tracetest.NewInMemoryExporter()
+
+	provider, err := Setup(context.Background(), Options{
+		ServiceName: "checkout",
+		Exporter:    InMemory(exporter),
+		Sampler:     ParentBased(RatioSampler(0)),
+		Synchronous: true,
+	})
+	require.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+	tracer := provider.Tracer("test")
+
+	// 沒有上游時按比例 0 全部丟棄
+	_, span := tracer.Start(context.Background(), "root")
+	span.End()
+	assert.Empty(t, exporter.GetSpans())
+
+	// 上游已採樣時沿用上游的決定
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	_, span = tracer.Start(trace.ContextWithRemoteSpanContext(context.Background(), parent), "child")
+	span.End()
+	assert.Len(t, exporter.GetSpans(), 1)
+}
+
+func TestRateLimitedSampler(t *testing.T) {
+	now := time.Unix(0, 0)
+	sampler := RateLimited(2)
+	sampler.now = func() time.Time { return now }
+
+	sample := func() bool {
+		return sampler.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background()}).Decision == sdktrace.RecordAndSample
+	}
+
+	// 初始允許 2 個突發
+	assert.True(t, sample())
+	assert.True(t, sample())
+	assert.False(t, sample())
+
+	// 半秒補充 1 個令牌
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, sample())
+	assert.False(t, sample())
+
+	// 長時間空閒後最多累積到突發上限
+	now = now.Add(time.Minute)
+	assert.True(t, sample())
+	assert.True(t, sample())
+	assert.False(t, sample())
+}
@@ -0,0 +1,297 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TailSamplingOptions 描述尾部採樣的規則
+// 包含錯誤或慢 span 的追蹤全部保留，其餘按 Ratio 採樣
+type TailSamplingOptions struct {
+	Ratio float64 // 沒有錯誤且不慢的追蹤的保留比例，0 到 1
+	// LatencyThreshold 任一 span 的耗時達到此值時保留整條追蹤，0 表示不按耗時保留
+	LatencyThreshold time.Duration
+	// DecisionWait 根 span 不在本進程結束時（只有下游 span），等待多久後做決定，默認 5s
+	// 決定之後的 DecisionWait 內到達的同一追蹤的 span 沿用該決定
+	DecisionWait time.Duration
+	// MaxTraces 最多緩存的追蹤數，超過時提前對最早的追蹤做決定，默認 10000
+	MaxTraces int
+	// Registerer 不為空時註冊保留與丟棄的追蹤數指標
+	Registerer prometheus.Registerer
+}
+
+// 採樣決定的原因，用作指標標籤
+const (
+	reasonError = "error"
+	reasonSlow  = "slow"
+	reasonRatio = "ratio"
+)
+
+// TailSampler 是一個 span 處理器，按追蹤緩存結束的 span，在本地根 span 結束時決定是否保留整條追蹤
+// 保留的 span 交給 next（通常是導出器的 BatchSpanProcessor）
+type TailSampler struct {
+	next  sdktrace.SpanProcessor
+	opts  TailSamplingOptions
+	ratio sdktrace.Sampler
+
+	mu      sync.Mutex
+	pending map[trace.TraceID]*pendingTrace
+	decided map[trace.TraceID]decision
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+
+	traces *prometheus.CounterVec
+	spans  *prometheus.CounterVec
+}
+
+type pendingTrace struct {
+	spans []sdktrace.ReadOnlySpan
+	first time.Time
+	err   bool
+	slow  bool
+}
+
+type decision struct {
+	keep bool
+	at   time.Time
+}
+
+var _ sdktrace.SpanProcessor = (*TailSampler)(nil)
+
+// NewTailSampler 創建 TailSampler 並啟動後台的超時決定任務，Shutdown 時停止
+func NewTailSampler(next sdktrace.SpanProcessor, opts TailSamplingOptions) (*TailSampler, error) {
+	if opts.Ratio < 0 || opts.Ratio > 1 {
+		return nil, errors.New("tracing: tail sampling ratio must be between 0 and 1")
+	}
+	if opts.DecisionWait <= 0 {
+		opts.DecisionWait = 5 * time.Second
+	}
+	if opts.MaxTraces <= 0 {
+		opts.MaxTraces = 10000
+	}
+
+	s := &TailSampler{
+		next:    next,
+		opts:    opts,
+		ratio:   sdktrace.TraceIDRatioBased(opts.Ratio),
+		pending: make(map[trace.TraceID]*pendingTrace),
+		decided: make(map[trace.TraceID]decision),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		traces: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracing_tail_sampling_traces_total",
+			Help: "Traces decided by the tail sampler by decision and reason",
+		}, []string{"decision", "reason"}),
+		spans: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracing_tail_sampling_spans_total",
+			Help: "Spans kept or dropped by the tail sampler",
+		}, []string{"decision"}),
+	}
+
+	if opts.Registerer != nil {
+		pendingGauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "tracing_tail_sampling_pending_traces",
+			Help: "Traces buffered by the tail sampler waiting for a decision",
+		}, func() float64 {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return float64(len(s.pending))
+		})
+		for _, c := range []prometheus.Collector{s.traces, s.spans, pendingGauge} {
+			if err := opts.Registerer.Register(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	go s.run()
+	return s, nil
+}
+
+func (s *TailSampler) OnStart(parent context.Context, span sdktrace.ReadWriteSpan) {
+	s.next.OnStart(parent, span)
+}
+
+func (s *TailSampler) OnEnd(span sdktrace.ReadOnlySpan) {
+	if !span.SpanContext().IsSampled() {
+		return
+	}
+	traceID := span.SpanContext().TraceID()
+
+	s.mu.Lock()
+	// 追蹤已有決定時（根 span 之後結束的 span），沿用該決定
+	if d, ok := s.decided[traceID]; ok {
+		s.mu.Unlock()
+		s.emit([]sdktrace.ReadOnlySpan{span}, d.keep)
+		return
+	}
+
+	p := s.pending[traceID]
+	if p == nil {
+		p = &pendingTrace{first: time.Now()}
+		s.pending[traceID] = p
+	}
+	p.spans = append(p.spans, span)
+	p.err = p.err || isError(span)
+	p.slow = p.slow || s.isSlow(span)
+
+	var ready []decided
+	if isLocalRoot(span) {
+		ready = append(ready, s.decideLocked(traceID))
+	}
+	if len(s.pending) > s.opts.MaxTraces {
+		ready = append(ready, s.decideLocked(s.oldestLocked()))
+	}
+	s.mu.Unlock()
+
+	for _, d := range ready {
+		s.emit(d.spans, d.keep)
+	}
+}
+
+// decided 是已做出決定、等待在鎖外交給 next 的 span
+type decided struct {
+	spans []sdktrace.ReadOnlySpan
+	keep  bool
+}
+
+// decideLocked 對緩存的追蹤做出決定並記錄指標，調用方持有 s.mu
+func (s *TailSampler) decideLocked(traceID trace.TraceID) decided {
+	p := s.pending[traceID]
+	delete(s.pending, traceID)
+
+	keep, reason := true, reasonRatio
+	switch {
+	case p.err:
+		reason = reasonError
+	case p.slow:
+		reason = reasonSlow
+	default:
+		keep = s.ratio.ShouldSample(sdktrace.SamplingParameters{TraceID: traceID}).Decision == sdktrace.RecordAndSample
+	}
+	s.decided[traceID] = decision{keep: keep, at: time.Now()}
+	s.traces.WithLabelValues(decisionLabel(keep), reason).Inc()
+	return decided{spans: p.spans, keep: keep}
+}
+
+func (s *TailSampler) oldestLocked() trace.TraceID {
+	var oldest trace.TraceID
+	var first time.Time
+	for traceID, p := range s.pending {
+		if first.IsZero() || p.first.Before(first) {
+			oldest, first = traceID, p.first
+		}
+	}
+	return oldest
+}
+
+// emit 把保留的 span 交給 next，丟棄的只計數
+func (s *TailSampler) emit(spans []sdktrace.ReadOnlySpan, keep bool) {
+	s.spans.WithLabelValues(decisionLabel(keep)).Add(float64(len(spans)))
+	if !keep {
+		return
+	}
+	for _, span := range spans {
+		s.next.OnEnd(span)
+	}
+}
+
+// run 定期對超過 DecisionWait 仍未結束根 span 的追蹤做決定，並清理過期的決定
+func (s *TailSampler) run() {
+	defer close(s.done)
+
+	interval := s.opts.DecisionWait / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.flush(now.Add(-s.opts.DecisionWait))
+		}
+	}
+}
+
+// flush 對 before 之前開始緩存的追蹤做決定，before 為零值時對全部追蹤做決定
+func (s *TailSampler) flush(before time.Time) {
+	s.mu.Lock()
+	var ready []decided
+	for traceID, p := range s.pending {
+		if before.IsZero() || p.first.Before(before) {
+			ready = append(ready, s.decideLocked(traceID))
+		}
+	}
+	for traceID, d := range s.decided {
+		if before.IsZero() || d.at.Before(before) {
+			delete(s.decided, traceID)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, d := range ready {
+		s.emit(d.spans, d.keep)
+	}
+}
+
+// ForceFlush 立即對所有緩存的追蹤做決定，並發送 next 中未導出的 span
+func (s *TailSampler) ForceFlush(ctx context.Context) error {
+	s.flush(time.Time{})
+	return s.next.ForceFlush(ctx)
+}
+
+// Shutdown 停止後台任務，對所有緩存的追蹤做決定後關閉 next
+func (s *TailSampler) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.flush(time.Time{})
+	return s.next.Shutdown(ctx)
+}
+
+func (s *TailSampler) isSlow(span sdktrace.ReadOnlySpan) bool {
+	return s.opts.LatencyThreshold > 0 && span.EndTime().Sub(span.StartTime()) >= s.opts.LatencyThreshold
+}
+
+// isError 判斷 span 是否設置了錯誤狀態或通過 RecordError 記錄了異常
+func isError(span sdktrace.ReadOnlySpan) bool {
+	if span.Status().Code == codes.Error {
+		return true
+	}
+	for _, event := range span.Events() {
+		if event.Name == semconv.ExceptionEventName {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalRoot 判斷 span 是否是本進程中追蹤的第一個 span（沒有父 span 或父 span 來自上游）
+func isLocalRoot(span sdktrace.ReadOnlySpan) bool {
+	parent := span.Parent()
+	return !parent.IsValid() || parent.IsRemote()
+}
+
+func decisionLabel(keep bool) string {
+	if keep {
+		return "kept"
+	}
+	return "dropped"
+}
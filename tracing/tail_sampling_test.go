@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTailSampled 創建只導出尾部採樣保留的 span 的 TracerProvider
+func newTailSampled(t *testing.T, opts TailSamplingOptions) (trace.Tracer, *TailSampler, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	sampler, err := NewTailSampler(sdktrace.NewSimpleSpanProcessor(keepOnShutdown{exporter}), opts)
+	require.NoError(t, err)
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sampler))
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+	return tp.Tracer("test"), sampler, exporter
+}
+
+// runTrace 記錄一條包含根 span 與一個子 span 的追蹤，child 可以修改子 span
+func runTrace(tracer trace.Tracer, child func(span trace.Span)) {
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, span := tracer.Start(ctx, "child")
+	child(span)
+	span.End()
+	root.End()
+}
+
+func TestTailSamplerKeepsErrorAndSlowTraces(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	tracer, sampler, exporter := newTailSampled(t, TailSamplingOptions{
+		Ratio:            0,
+		LatencyThreshold: time.Second,
+		Registerer:       registry,
+	})
+
+	// 普通追蹤按比例 0 丟棄
+	runTrace(tracer, func(trace.Span) {})
+	assert.Empty(t, exporter.GetSpans())
+
+	// 子 span 記錄了錯誤，整條追蹤保留
+	runTrace(tracer, func(span trace.Span) { span.RecordError(errors.New("boom")) })
+	assert.Len(t, exporter.GetSpans(), 2)
+	exporter.Reset()
+
+	// 子 span 超過延遲閾值，整條追蹤保留
+	start := time.Now().Add(-2 * time.Second)
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, slow := tracer.Start(ctx, "slow", trace.WithTimestamp(start))
+	slow.End()
+	root.End()
+	assert.Len(t, exporter.GetSpans(), 2)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(sampler.traces.WithLabelValues("dropped", reasonRatio)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(sampler.traces.WithLabelValues("kept", reasonError)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(sampler.traces.WithLabelValues("kept", reasonSlow)))
+	assert.Equal(t, 4.0, testutil.ToFloat64(sampler.spans.WithLabelValues("kept")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(sampler.spans.WithLabelValues("dropped")))
+
+	count, err := testutil.GatherAndCount(registry, "tracing_tail_sampling_pending_traces")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestTailSamplerRatio(t *testing.T) {
+	tracer, _, exporter := newTailSampled(t, TailSamplingOptions{Ratio: 1})
+
+	runTrace(tracer, func(trace.Span) {})
+	assert.Len(t, exporter.GetSpans(), 2)
+
+	_, err := NewTailSampler(sdktrace.NewSimpleSpanProcessor(exporter), TailSamplingOptions{Ratio: 1.5})
+	assert.Error(t, err)
+}
+
+func TestTailSamplerDecidesAfterWait(t *testing.T) {
+	tracer, _, exporter := newTailSampled(t, TailSamplingOptions{Ratio: 0, DecisionWait: 40 * time.Millisecond})
+
+	// 根 span 尚未結束，子 span 的錯誤在等待超時後決定保留
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.RecordError(errors.New("boom"))
+	child.End()
+	assert.Empty(t, exporter.GetSpans())
+
+	require.Eventually(t, func() bool { return len(exporter.GetSpans()) == 1 }, time.Second, 5*time.Millisecond)
+
+	// 之後結束的根 span 沿用已做出的決定
+	root.End()
+	assert.Len(t, exporter.GetSpans(), 2)
+}
+
+func TestTailSamplerMaxTraces(t *testing.T) {
+	tracer, _, exporter := newTailSampled(t, TailSamplingOptions{Ratio: 0, MaxTraces: 1})
+
+	// 兩條追蹤的根 span 都未結束，超過緩存上限時提前對最早的追蹤做決定
+	ctx, first := tracer.Start(context.Background(), "first")
+	_, child := tracer.Start(ctx, "child")
+	child.SetStatus(codes.Error, "failed")
+	child.End()
+
+	ctx, second := tracer.Start(context.Background(), "second")
+	_, other := tracer.Start(ctx, "child")
+	other.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, first.SpanContext().TraceID(), spans[0].SpanContext.TraceID())
+	first.End()
+	second.End()
+}
+
+func TestTailSamplerShutdownFlushes(t *testing.T) {
+	restoreGlobals(t)
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := Setup(context.Background(), Options{
+		ServiceName:  "checkout",
+		Exporter:     InMemory(exporter),
+		TailSampling: &TailSamplingOptions{Ratio: 0},
+	})
+	require.NoError(t, err)
+
+	ctx, root := provider.Tracer("test").Start(context.Background(), "root")
+	_, child := provider.Tracer("test").Start(ctx, "child")
+	child.RecordError(errors.New("boom"))
+	child.End()
+
+	// 根 span 未結束，Shutdown 時仍然對緩存的追蹤做決定並導出
+	require.NoError(t, provider.Shutdown(context.Background()))
+	assert.Len(t, exporter.GetSpans(), 1)
+	root.End()
+}
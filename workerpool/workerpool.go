@@ -0,0 +1,324 @@
+// Package workerpool runs tasks on a fixed number of goroutines, highest priority first.
+//
+// The queue is bounded: Submit waits for space (backpressure) and TrySubmit fails
+// with ErrQueueFull instead. Stop stops accepting tasks and lets the workers drain
+// the queue before returning.
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"example.com/m/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	ErrQueueFull = errors.New("workerpool: queue is full")
+	ErrStopped   = errors.New("workerpool: pool is stopped")
+)
+
+// Task results, used as the result label of the task duration metric
+const (
+	resultOK       = "ok"
+	resultError    = "error"
+	resultTimeout  = "timeout"
+	resultCanceled = "canceled"
+	resultPanic    = "panic"
+)
+
+// Options configures a Pool. Zero values use the defaults.
+type Options struct {
+	Name        string        // Value of the pool label on the metrics
+	Workers     int           // Number of goroutines running tasks, default runtime.NumCPU()
+	QueueSize   int           // Tasks that may wait for a worker, default 100
+	TaskTimeout time.Duration // Default timeout of each task, 0 means none
+	// Registerer, if not nil, registers the queue depth, wait time and task duration metrics
+	Registerer prometheus.Registerer
+}
+
+// Task is a unit of work. Run must return once its context is done.
+type Task struct {
+	Priority int           // Higher priorities run first
+	Timeout  time.Duration // Overrides Options.TaskTimeout if not zero
+	Run      func(ctx context.Context) error
+}
+
+// Handle reports the outcome of a submitted task
+type Handle struct {
+	done chan struct{}
+	err  error
+}
+
+// Done is closed once the task has finished or was dropped
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Err returns the error of the task after Done is closed: the error returned by Run,
+// context.DeadlineExceeded if it timed out, or ErrStopped if the pool stopped
+// before the task could run
+func (h *Handle) Err() error {
+	<-h.done
+	return h.err
+}
+
+// Wait waits for the task to finish and returns its error, or ctx.Err() if ctx is done first
+func (h *Handle) Wait(ctx context.Context) error {
+	select {
+	case <-h.done:
+		return h.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type job struct {
+	task      Task
+	ctx       context.Context // Context of the caller of Submit
+	submitted time.Time
+	handle    *Handle
+}
+
+// Pool is a fixed set of workers taking tasks from a bounded priority queue
+type Pool struct {
+	opts  Options
+	queue *api.ConcurrentPriorityQueue[*job] // Lower values first, FIFO among equal priorities
+
+	slots chan struct{} // One token per free place in the queue
+	ready chan struct{} // One token per queued job, closed by Stop
+
+	// ctx is cancelled when Stop gives up waiting, which cancels the running tasks
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	stopped bool
+	stop    chan struct{} // Closed by Stop to wake up blocked Submit calls
+
+	workers sync.WaitGroup
+
+	waitTime     prometheus.Histogram
+	taskDuration *prometheus.HistogramVec
+	rejected     prometheus.Counter
+}
+
+// New starts a pool with opts.Workers workers
+func New(opts Options) (*Pool, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	labels := prometheus.Labels{"pool": opts.Name}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		opts:   opts,
+		queue:  api.NewConcurrentPriorityQueue[*job](),
+		slots:  make(chan struct{}, opts.QueueSize),
+		ready:  make(chan struct{}, opts.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan struct{}),
+		waitTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "workerpool_queue_wait_seconds",
+			Help:        "Time tasks spent in the queue before a worker picked them up",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 4, 8),
+		}),
+		taskDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "workerpool_task_duration_seconds",
+			Help:        "Time workers spent running tasks by result",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}, []string{"result"}),
+		rejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "workerpool_tasks_rejected_total",
+			Help:        "Tasks rejected by TrySubmit because the queue was full",
+			ConstLabels: labels,
+		}),
+	}
+	for i := 0; i < opts.QueueSize; i++ {
+		p.slots <- struct{}{}
+	}
+
+	if opts.Registerer != nil {
+		depth := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "workerpool_queue_depth",
+			Help:        "Tasks waiting for a worker",
+			ConstLabels: labels,
+		}, func() float64 { return float64(p.queue.Len()) })
+		for _, c := range []prometheus.Collector{depth, p.waitTime, p.taskDuration, p.rejected} {
+			if err := opts.Registerer.Register(c); err != nil {
+				cancel()
+				return nil, err
+			}
+		}
+	}
+
+	p.workers.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go p.work()
+	}
+	return p, nil
+}
+
+// Submit queues a task, waiting for space while the queue is full. The task's
+// context is cancelled if ctx is cancelled, so a task that is no longer wanted
+// does not run.
+func (p *Pool) Submit(ctx context.Context, task Task) (*Handle, error) {
+	select {
+	case <-p.slots:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.stop:
+		return nil, ErrStopped
+	}
+	return p.enqueue(ctx, task)
+}
+
+// TrySubmit queues a task or returns ErrQueueFull at once if the queue is full
+func (p *Pool) TrySubmit(ctx context.Context, task Task) (*Handle, error) {
+	select {
+	case <-p.slots:
+	default:
+		p.mu.Lock()
+		stopped := p.stopped
+		p.mu.Unlock()
+		if stopped {
+			return nil, ErrStopped
+		}
+		p.rejected.Inc()
+		return nil, ErrQueueFull
+	}
+	return p.enqueue(ctx, task)
+}
+
+// enqueue adds a job to the queue. The caller holds a slot.
+func (p *Pool) enqueue(ctx context.Context, task Task) (*Handle, error) {
+	if task.Run == nil {
+		p.slots <- struct{}{}
+		return nil, errors.New("workerpool: task has no Run function")
+	}
+	j := &job{task: task, ctx: ctx, submitted: time.Now(), handle: &Handle{done: make(chan struct{})}}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		p.slots <- struct{}{}
+		return nil, ErrStopped
+	}
+	p.queue.Enqueue(j, -task.Priority)
+	// Never blocks: there are at most QueueSize jobs in the queue
+	p.ready <- struct{}{}
+	return j.handle, nil
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+	for range p.ready {
+		j, ok := p.queue.Dequeue()
+		if !ok {
+			continue
+		}
+		p.slots <- struct{}{}
+
+		p.waitTime.Observe(time.Since(j.submitted).Seconds())
+		if p.ctx.Err() != nil {
+			j.finish(ErrStopped)
+			continue
+		}
+		p.run(j)
+	}
+}
+
+// run runs a job with its timeout, turning panics into errors
+func (p *Pool) run(j *job) {
+	ctx, cancel := context.WithCancel(j.ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.ctx, cancel)
+	defer stop()
+	timeout := j.task.Timeout
+	if timeout == 0 {
+		timeout = p.opts.TaskTimeout
+	}
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	panicked := false
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				panicked = true
+				err = fmt.Errorf("workerpool: task panicked: %v", r)
+			}
+		}()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return j.task.Run(ctx)
+	}()
+
+	result := resultOK
+	switch {
+	case panicked:
+		result = resultPanic
+	case err == nil:
+	case errors.Is(ctx.Err(), context.DeadlineExceeded) && j.ctx.Err() == nil:
+		result = resultTimeout
+		err = context.DeadlineExceeded
+	case ctx.Err() != nil:
+		result = resultCanceled
+	default:
+		result = resultError
+	}
+	p.taskDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	j.finish(err)
+}
+
+func (j *job) finish(err error) {
+	j.handle.err = err
+	close(j.handle.done)
+}
+
+// Len returns the number of tasks waiting for a worker
+func (p *Pool) Len() int {
+	return p.queue.Len()
+}
+
+// Stop stops accepting tasks and waits until the workers have run every queued task.
+// If ctx is done first, the running tasks are cancelled, the remaining queued tasks
+// fail with ErrStopped and Stop returns ctx.Err() once the workers have exited.
+func (p *Pool) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.stop)
+		close(p.ready)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
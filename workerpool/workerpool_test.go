@@ -0,0 +1,226 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPool(t *testing.T, opts Options) *Pool {
+	t.Helper()
+	p, err := New(opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { p.Stop(context.Background()) })
+	return p
+}
+
+// block occupies one worker until the returned function is called
+func block(t *testing.T, p *Pool) func() {
+	t.Helper()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	_, err := p.Submit(context.Background(), Task{Priority: 100, Run: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}})
+	require.NoError(t, err)
+	<-started
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	t.Cleanup(unblock)
+	return unblock
+}
+
+func TestPriorityOrder(t *testing.T) {
+	p := newTestPool(t, Options{Workers: 1})
+	unblock := block(t, p)
+
+	var mu sync.Mutex
+	var order []int
+	var handles []*Handle
+	for _, priority := range []int{1, 5, 3, 0, 4, 3} {
+		h, err := p.Submit(context.Background(), Task{Priority: priority, Run: func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, priority)
+			return nil
+		}})
+		require.NoError(t, err)
+		handles = append(handles, h)
+	}
+	assert.Equal(t, 6, p.Len())
+
+	unblock()
+	for _, h := range handles {
+		require.NoError(t, h.Err())
+	}
+	assert.Equal(t, []int{5, 4, 3, 3, 1, 0}, order)
+}
+
+func TestTrySubmitRejectsWhenFull(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	p := newTestPool(t, Options{Name: "test", Workers: 1, QueueSize: 2, Registerer: reg})
+	unblock := block(t, p)
+
+	noop := Task{Run: func(ctx context.Context) error { return nil }}
+	for i := 0; i < 2; i++ {
+		_, err := p.TrySubmit(context.Background(), noop)
+		require.NoError(t, err)
+	}
+	_, err := p.TrySubmit(context.Background(), noop)
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	expected := `
+		# HELP workerpool_queue_depth Tasks waiting for a worker
+		# TYPE workerpool_queue_depth gauge
+		workerpool_queue_depth{pool="test"} 2
+		# HELP workerpool_tasks_rejected_total Tasks rejected by TrySubmit because the queue was full
+		# TYPE workerpool_tasks_rejected_total counter
+		workerpool_tasks_rejected_total{pool="test"} 1
+	`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "workerpool_queue_depth", "workerpool_tasks_rejected_total"))
+
+	unblock()
+	require.NoError(t, p.Stop(context.Background()))
+	// The blocking task and the two queued ones
+	assert.Equal(t, 3.0, sampleCount(t, p.taskDuration.WithLabelValues(resultOK)))
+	assert.Equal(t, 3.0, sampleCount(t, p.waitTime))
+}
+
+func TestSubmitWaitsForSpace(t *testing.T) {
+	p := newTestPool(t, Options{Workers: 1, QueueSize: 1})
+	unblock := block(t, p)
+	noop := Task{Run: func(ctx context.Context) error { return nil }}
+	_, err := p.Submit(context.Background(), noop)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = p.Submit(ctx, noop)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := p.Submit(context.Background(), noop)
+		submitted <- err
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("Submit returned while the queue was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unblock()
+	assert.NoError(t, <-submitted)
+}
+
+func TestTaskErrors(t *testing.T) {
+	p := newTestPool(t, Options{Workers: 2, TaskTimeout: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	h, err := p.Submit(ctx, Task{Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	require.NoError(t, err)
+	assert.ErrorIs(t, h.Err(), context.DeadlineExceeded)
+
+	// A task's own timeout overrides the pool default
+	h, err = p.Submit(ctx, Task{Timeout: time.Second, Run: func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	}})
+	require.NoError(t, err)
+	assert.NoError(t, h.Err())
+
+	boom := errors.New("boom")
+	h, err = p.Submit(ctx, Task{Run: func(ctx context.Context) error { return boom }})
+	require.NoError(t, err)
+	assert.ErrorIs(t, h.Err(), boom)
+
+	h, err = p.Submit(ctx, Task{Run: func(ctx context.Context) error { panic("oops") }})
+	require.NoError(t, err)
+	assert.ErrorContains(t, h.Err(), "task panicked: oops")
+
+	// A task whose submitter gave up does not run
+	cancelled, cancel := context.WithCancel(ctx)
+	unblock1, unblock2 := block(t, p), block(t, p)
+	var ran atomic.Bool
+	h, err = p.Submit(cancelled, Task{Run: func(ctx context.Context) error {
+		ran.Store(true)
+		return nil
+	}})
+	require.NoError(t, err)
+	cancel()
+	unblock1()
+	unblock2()
+	assert.ErrorIs(t, h.Err(), context.Canceled)
+	assert.False(t, ran.Load())
+
+	require.NoError(t, p.Stop(ctx))
+	for result, count := range map[string]float64{resultTimeout: 1, resultOK: 3, resultError: 1, resultPanic: 1, resultCanceled: 1} {
+		assert.Equal(t, count, sampleCount(t, p.taskDuration.WithLabelValues(result)), result)
+	}
+}
+
+func TestStopDrainsQueue(t *testing.T) {
+	p := newTestPool(t, Options{Workers: 2, QueueSize: 50})
+	var done atomic.Int64
+	for i := 0; i < 50; i++ {
+		_, err := p.Submit(context.Background(), Task{Run: func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			done.Add(1)
+			return nil
+		}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, p.Stop(context.Background()))
+	assert.Equal(t, int64(50), done.Load())
+
+	_, err := p.Submit(context.Background(), Task{Run: func(ctx context.Context) error { return nil }})
+	assert.ErrorIs(t, err, ErrStopped)
+	_, err = p.TrySubmit(context.Background(), Task{Run: func(ctx context.Context) error { return nil }})
+	assert.ErrorIs(t, err, ErrStopped)
+}
+
+func TestStopTimeoutCancelsTasks(t *testing.T) {
+	p := newTestPool(t, Options{Workers: 1})
+	started := make(chan struct{})
+	running, err := p.Submit(context.Background(), Task{Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	require.NoError(t, err)
+	<-started
+	queued, err := p.Submit(context.Background(), Task{Run: func(ctx context.Context) error { return nil }})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.Stop(ctx), context.DeadlineExceeded)
+	assert.ErrorIs(t, running.Err(), context.Canceled)
+	assert.ErrorIs(t, queued.Err(), ErrStopped)
+}
+
+// sampleCount returns the number of observations of a histogram
+func sampleCount(t *testing.T, h prometheus.Observer) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	require.NoError(t, h.(prometheus.Metric).Write(m))
+	return float64(m.GetHistogram().GetSampleCount())
+}